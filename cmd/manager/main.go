@@ -15,7 +15,11 @@ import (
 	"k8s.io/client-go/rest"
 
 	"github.com/agill17/s3-operator/pkg/apis"
+	agillv1beta1 "github.com/agill17/s3-operator/pkg/apis/agill/v1beta1"
 	"github.com/agill17/s3-operator/pkg/controller"
+	"github.com/agill17/s3-operator/pkg/controller/options"
+	"github.com/agill17/s3-operator/pkg/utils"
+	"github.com/agill17/s3-operator/pkg/webhook"
 	"github.com/agill17/s3-operator/version"
 
 	"github.com/operator-framework/operator-sdk/pkg/k8sutil"
@@ -63,6 +67,33 @@ func main() {
 	// controller-runtime)
 	pflag.CommandLine.AddGoFlagSet(flag.CommandLine)
 
+	useFIPSEndpoint := pflag.Bool("aws-use-fips-endpoint", false, "Use FIPS endpoints for S3, IAM, STS, Access Analyzer and CloudWatch, the S3 one is also published in the bucket k8s service")
+	useDualStackEndpoint := pflag.Bool("aws-use-dualstack-endpoint", false, "Use dual-stack (IPv4 and IPv6) S3 endpoints, also published in the bucket k8s service")
+	httpProxy := pflag.String("aws-http-proxy", "", "Proxy URL for all AWS API calls, e.g. http://proxy.example.com:3128")
+	noProxy := pflag.String("aws-no-proxy", "", "Comma separated hosts, domains or CIDRs that bypass --aws-http-proxy")
+	caBundle := pflag.String("aws-ca-bundle", "", "Path to a PEM CA bundle (mounted from a ConfigMap or Secret) trusted for AWS API calls")
+	awsMaxRetries := pflag.Int("aws-max-retries", utils.DEFAULT_AWS_MAX_RETRIES, "Retries per AWS API call before the reconcile is requeued with back-off")
+	iamQPS := pflag.Float64("aws-iam-qps", utils.DEFAULT_IAM_API_QPS, "IAM API calls per second per AWS account, 0 disables the limit")
+	iamBurst := pflag.Int("aws-iam-burst", utils.DEFAULT_IAM_API_BURST, "Burst of IAM API calls per AWS account")
+	s3QPS := pflag.Float64("aws-s3-qps", utils.DEFAULT_S3_API_QPS, "S3 API calls per second per AWS account, 0 disables the limit")
+	s3Burst := pflag.Int("aws-s3-burst", utils.DEFAULT_S3_API_BURST, "Burst of S3 API calls per AWS account")
+	maxConcurrentReconciles := pflag.Int("max-concurrent-reconciles", 1, "Number of CRs each controller reconciles in parallel")
+	defaultRegion := pflag.String("default-region", "", "Region for S3 CRs that omit it, namespace annotation s3.agill.apps/default-region takes precedence")
+	defaultBucketACL := pflag.String("default-bucket-acl", "private", "Bucket ACL for S3 CRs that omit it, namespace annotation s3.agill.apps/default-bucket-acl takes precedence")
+	defaultEnableVersioning := pflag.Bool("default-enable-versioning", false, "Enable versioning for S3 CRs that omit enableVersioning")
+	defaultEnableTransferAcceleration := pflag.Bool("default-enable-transfer-acceleration", false, "Enable transfer acceleration for S3 CRs that omit enableTransferAcceleration")
+	validatePolicies := pflag.Bool("validate-policies", false, "Validate bucket and user policies with IAM Access Analyzer before applying them, needs access-analyzer:ValidatePolicy")
+	baselineDenyInsecureTransport := pflag.Bool("baseline-deny-insecure-transport", false, "Add a statement denying requests without TLS to every bucket policy, namespace annotation s3.agill.apps/baseline-deny-insecure-transport takes precedence")
+	baselineRequireEncryption := pflag.Bool("baseline-require-encryption", false, "Add a statement denying PutObject without server side encryption to every bucket policy, namespace annotation s3.agill.apps/baseline-require-encryption takes precedence")
+	requirePublicBucketApproval := pflag.Bool("require-public-bucket-approval", true, "Keep public buckets (public ACLs, Principal \"*\" in the bucket policy) in AwaitingApproval until a BucketApproval references their spec hash")
+	scanInterval := pflag.Duration("scan-interval", time.Hour, "How often the security posture scanner checks every bucket and updates S3Findings, 0 disables it")
+	maxAccessKeyAgeDays := pflag.Int("max-access-key-age-days", 90, "Active access keys of bucket users older than this many days are reported by the scanner")
+	lockdownAdminARNs := pflag.StringSlice("lockdown-admin-arns", nil, "Comma separated IAM principal ARNs (wildcards allowed) that keep access to buckets locked down with the s3.agill.apps/lockdown annotation, the operator itself always does")
+	adoptUntaggedIAMUsers := pflag.Bool("adopt-untagged-iam-users", true, "Adopt existing IAM users without s3.agill.apps/owner-* tags and tag them for the CR, users created by versions that did not tag them are adopted this way. Defaults to false in the next release")
+	enableWebhooks := pflag.Bool("enable-webhooks", false, "Serve the admission webhooks, needs a serving certificate in --webhook-cert-dir")
+	webhookPort := pflag.Int("webhook-port", 9443, "Port the admission webhook server listens on")
+	webhookCertDir := pflag.String("webhook-cert-dir", "/tmp/k8s-webhook-server/serving-certs", "Directory holding tls.crt and tls.key for the admission webhook server")
+
 	pflag.Parse()
 
 	// Use a zap logr.Logger implementation. If none of the zap
@@ -77,6 +108,30 @@ func main() {
 
 	printVersion()
 
+	awsHTTPClient, err := utils.NewHTTPClient(utils.HTTPClientConfig{
+		ProxyURL:     *httpProxy,
+		NoProxy:      *noProxy,
+		CABundleFile: *caBundle,
+	})
+	if err != nil {
+		log.Error(err, "Invalid AWS http client configuration")
+		os.Exit(1)
+	}
+	awsClients, err := utils.NewClientPool(utils.ClientConfig{
+		UseFIPSEndpoint:      *useFIPSEndpoint,
+		UseDualStackEndpoint: *useDualStackEndpoint,
+		HTTPClient:           awsHTTPClient,
+		MaxRetries:           *awsMaxRetries,
+		RateLimits: map[string]utils.RateLimit{
+			utils.API_FAMILY_IAM: {QPS: *iamQPS, Burst: *iamBurst},
+			utils.API_FAMILY_S3:  {QPS: *s3QPS, Burst: *s3Burst},
+		},
+	})
+	if err != nil {
+		log.Error(err, "Failed to set up AWS session")
+		os.Exit(1)
+	}
+
 	// Get a config to talk to the apiserver
 	cfg, err := config.GetConfig()
 	if err != nil {
@@ -99,6 +154,8 @@ func main() {
 		Namespace:          "",
 		MetricsBindAddress: fmt.Sprintf("%s:%d", metricsHost, metricsPort),
 		SyncPeriod:         &syncPeriod,
+		Port:               *webhookPort,
+		CertDir:            *webhookCertDir,
 	})
 	if err != nil {
 		log.Error(err, "")
@@ -114,11 +171,41 @@ func main() {
 	}
 
 	// Setup all Controllers
-	if err := controller.AddToManager(mgr); err != nil {
+	log.Info(fmt.Sprintf("Each controller will reconcile up to %v custom resources in parallel", *maxConcurrentReconciles))
+	opts := options.Options{
+		MaxConcurrentReconciles: *maxConcurrentReconciles,
+		Clients:                 awsClients,
+		S3Defaults: options.S3Defaults{
+			Region:                     *defaultRegion,
+			BucketACL:                  *defaultBucketACL,
+			EnableVersioning:           *defaultEnableVersioning,
+			EnableTransferAcceleration: *defaultEnableTransferAcceleration,
+		},
+		ValidatePolicies:            *validatePolicies,
+		RequirePublicBucketApproval: *requirePublicBucketApproval,
+		ScanInterval:                *scanInterval,
+		MaxAccessKeyAge:             time.Duration(*maxAccessKeyAgeDays) * 24 * time.Hour,
+		LockdownAdminARNs:           *lockdownAdminARNs,
+		AdoptUntaggedIAMUsers:       *adoptUntaggedIAMUsers,
+		BaselinePolicy: agillv1beta1.BaselinePolicy{
+			DenyInsecureTransport: *baselineDenyInsecureTransport,
+			RequireEncryption:     *baselineRequireEncryption,
+		},
+	}
+	if err := controller.AddToManager(mgr, opts); err != nil {
 		log.Error(err, "")
 		os.Exit(1)
 	}
 
+	// Setup all admission webhooks
+	if *enableWebhooks {
+		log.Info(fmt.Sprintf("Serving admission webhooks on port %v", *webhookPort))
+		if err := webhook.AddToManager(mgr, opts); err != nil {
+			log.Error(err, "")
+			os.Exit(1)
+		}
+	}
+
 	// Add the Metrics Service
 	addMetrics(ctx, cfg, "s3-operator")
 
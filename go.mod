@@ -5,8 +5,12 @@ go 1.13
 require (
 	github.com/aws/aws-sdk-go v1.29.3
 	github.com/davecgh/go-spew v1.1.1
+	github.com/go-logr/logr v0.1.0
 	github.com/operator-framework/operator-sdk v0.15.2
+	github.com/prometheus/client_golang v1.2.1
 	github.com/spf13/pflag v1.0.5
+	golang.org/x/net v0.0.0-20200202094626-16171245cfb2
+	golang.org/x/time v0.0.0-20191024005414-555d28b269f0
 	k8s.io/api v0.0.0
 	k8s.io/apimachinery v0.0.0
 	k8s.io/client-go v12.0.0+incompatible
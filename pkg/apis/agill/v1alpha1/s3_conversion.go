@@ -0,0 +1,128 @@
+package v1alpha1
+
+import (
+	"encoding/json"
+
+	"github.com/agill17/s3-operator/pkg/apis/agill/v1beta1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"sigs.k8s.io/controller-runtime/pkg/conversion"
+)
+
+// V1BETA1_SPEC_ANNOTATION holds the v1beta1 spec when v1alpha1 cannot express it ( e.g: versioning Unmanaged ),
+// so a v1beta1 -> v1alpha1 -> v1beta1 round trip is lossless
+const V1BETA1_SPEC_ANNOTATION = "s3.agill.apps/v1beta1-spec"
+
+// V1BETA1_STATUS_ANNOTATION holds the v1beta1 status when v1alpha1 cannot express it ( e.g: status.lockdown, status.consumers ),
+// so a status written through v1alpha1 keeps what is needed to lift a lockdown or delete consumer users
+const V1BETA1_STATUS_ANNOTATION = "s3.agill.apps/v1beta1-status"
+
+// ConvertTo converts this S3 to the hub version ( v1beta1 )
+func (src *S3) ConvertTo(dstRaw conversion.Hub) error {
+	dst := dstRaw.(*v1beta1.S3)
+	dst.ObjectMeta = *src.ObjectMeta.DeepCopy()
+
+	// start from the preserved v1beta1 spec, every field v1alpha1 can express wins over it
+	preserved, hasPreserved := dst.GetAnnotations()[V1BETA1_SPEC_ANNOTATION]
+	if hasPreserved {
+		if err := json.Unmarshal([]byte(preserved), &dst.Spec); err != nil {
+			return err
+		}
+		delete(dst.Annotations, V1BETA1_SPEC_ANNOTATION)
+	}
+
+	dst.Spec.Region = src.Spec.Region
+	dst.Spec.BucketName = src.Spec.BucketName
+	dst.Spec.BucketNamePrefix = src.Spec.BucketNamePrefix
+	dst.Spec.BucketACL = src.Spec.BucketACL
+	dst.Spec.ObjectLockEnabled = src.Spec.EnableObjectLock
+	dst.Spec.Versioning = toFeatureState(src.Spec.EnableVersioning, dst.Spec.Versioning, hasPreserved)
+	dst.Spec.TransferAcceleration = toFeatureState(src.Spec.EnableTransferAcceleration, dst.Spec.TransferAcceleration, hasPreserved)
+	dst.Spec.BucketPolicy = src.Spec.BucketPolicy
+	dst.Spec.IAMUser.Username = src.Spec.IAMUserSpec.Username
+
+	// same for status, the fields v1alpha1 has win
+	if preservedStatus, ok := dst.GetAnnotations()[V1BETA1_STATUS_ANNOTATION]; ok {
+		if err := json.Unmarshal([]byte(preservedStatus), &dst.Status); err != nil {
+			return err
+		}
+		delete(dst.Annotations, V1BETA1_STATUS_ANNOTATION)
+	}
+
+	dst.Status.Phase = v1beta1.Phase(src.Status.Status)
+	dst.Status.BucketName = src.Status.BucketName
+	dst.Status.Region = src.Status.Region
+	dst.Status.ObjectLockEnabled = src.Status.ObjectLockEnabled
+	dst.Status.BucketNameAttempt = src.Status.BucketNameAttempt
+	dst.Status.Conditions = nil
+	for _, c := range src.Status.Conditions {
+		dst.Status.Conditions = append(dst.Status.Conditions, v1beta1.Condition(c))
+	}
+	return nil
+}
+
+// ConvertFrom converts the hub version ( v1beta1 ) to this S3
+func (dst *S3) ConvertFrom(srcRaw conversion.Hub) error {
+	src := srcRaw.(*v1beta1.S3)
+	dst.ObjectMeta = *src.ObjectMeta.DeepCopy()
+
+	dst.Spec.Region = src.Spec.Region
+	dst.Spec.BucketName = src.Spec.BucketName
+	dst.Spec.BucketNamePrefix = src.Spec.BucketNamePrefix
+	dst.Spec.BucketACL = src.Spec.BucketACL
+	dst.Spec.EnableObjectLock = src.Spec.ObjectLockEnabled
+	dst.Spec.EnableVersioning = src.Spec.Versioning == v1beta1.FEATURE_ENABLED
+	dst.Spec.EnableTransferAcceleration = src.Spec.TransferAcceleration == v1beta1.FEATURE_ENABLED
+	dst.Spec.BucketPolicy = src.Spec.BucketPolicy
+	dst.Spec.IAMUserSpec.Username = src.Spec.IAMUser.Username
+
+	dst.Status.Status = string(src.Status.Phase)
+	dst.Status.BucketName = src.Status.BucketName
+	dst.Status.Region = src.Status.Region
+	dst.Status.ObjectLockEnabled = src.Status.ObjectLockEnabled
+	dst.Status.BucketNameAttempt = src.Status.BucketNameAttempt
+	dst.Status.Conditions = nil
+	for _, c := range src.Status.Conditions {
+		dst.Status.Conditions = append(dst.Status.Conditions, Condition(c))
+	}
+
+	// keep the v1beta1 spec and status around only if converting back would not reproduce them
+	roundTrip := &v1beta1.S3{}
+	if err := dst.ConvertTo(roundTrip); err != nil {
+		return err
+	}
+	if !equality.Semantic.DeepEqual(roundTrip.Spec, src.Spec) {
+		if err := dst.preserve(V1BETA1_SPEC_ANNOTATION, src.Spec); err != nil {
+			return err
+		}
+	}
+	if !equality.Semantic.DeepEqual(roundTrip.Status, src.Status) {
+		if err := dst.preserve(V1BETA1_STATUS_ANNOTATION, src.Status); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// preserve stores the JSON of a v1beta1 field in the annotation
+func (dst *S3) preserve(annotation string, v interface{}) error {
+	preserved, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	if dst.Annotations == nil {
+		dst.Annotations = map[string]string{}
+	}
+	dst.Annotations[annotation] = string(preserved)
+	return nil
+}
+
+// toFeatureState maps a v1alpha1 bool, false means Suspended unless the preserved v1beta1 spec had it unmanaged
+func toFeatureState(enabled bool, preserved v1beta1.FeatureState, hasPreserved bool) v1beta1.FeatureState {
+	if enabled {
+		return v1beta1.FEATURE_ENABLED
+	}
+	if hasPreserved && preserved != v1beta1.FEATURE_ENABLED {
+		return preserved
+	}
+	return v1beta1.FEATURE_SUSPENDED
+}
@@ -0,0 +1,195 @@
+package v1alpha1
+
+import (
+	"testing"
+
+	"github.com/agill17/s3-operator/pkg/apis/agill/v1beta1"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/diff"
+)
+
+// json only keeps seconds, conditions and lockdowns read back from an annotation lose anything finer
+var since = metav1.Unix(1700000000, 0)
+
+func v1beta1S3(mutate func(s *v1beta1.S3)) *v1beta1.S3 {
+	s := &v1beta1.S3{
+		ObjectMeta: metav1.ObjectMeta{Name: "bucket", Namespace: "team-a", Labels: map[string]string{"team": "a"}},
+		Spec: v1beta1.S3Spec{
+			Region:               "us-east-1",
+			BucketName:           "my-bucket",
+			BucketACL:            "private",
+			Versioning:           v1beta1.FEATURE_ENABLED,
+			TransferAcceleration: v1beta1.FEATURE_SUSPENDED,
+			IAMUser:              v1beta1.BucketIAMUser{Username: "team-a.bucket.s3"},
+		},
+		Status: v1beta1.S3Status{
+			Phase:      v1beta1.PHASE_READY,
+			BucketName: "my-bucket",
+			Region:     "us-east-1",
+			Conditions: []v1beta1.Condition{
+				{Type: "Ready", Status: v1.ConditionTrue, ObservedGeneration: 2, LastTransitionTime: since, Reason: "Reconciled"},
+			},
+		},
+	}
+	mutate(s)
+	return s
+}
+
+func TestConvertRoundTrip(t *testing.T) {
+	tests := []struct {
+		name string
+		src  *v1beta1.S3
+		// fields v1alpha1 has no place for are kept in annotations
+		wantAnnotations []string
+	}{
+		{name: "expressible in v1alpha1", src: v1beta1S3(func(s *v1beta1.S3) {})},
+		{
+			name: "generated name",
+			src: v1beta1S3(func(s *v1beta1.S3) {
+				s.Spec.BucketName, s.Spec.BucketNamePrefix = "", "logs"
+				s.Status.BucketName, s.Status.BucketNameAttempt = "logs-team-a-0123456789", 1
+			}),
+		},
+		{
+			name: "unmanaged features",
+			src: v1beta1S3(func(s *v1beta1.S3) {
+				s.Spec.Versioning, s.Spec.TransferAcceleration = v1beta1.FEATURE_UNMANAGED, ""
+			}),
+			wantAnnotations: []string{V1BETA1_SPEC_ANNOTATION},
+		},
+		{
+			name: "v1beta1 only spec",
+			src: v1beta1S3(func(s *v1beta1.S3) {
+				s.Spec.IAMUser.AccessLevel = v1beta1.ACCESS_LEVEL_READ_ONLY
+				s.Spec.Consumers = []v1beta1.BucketConsumer{{Name: "a", Prefix: "tenant-a/"}}
+				s.Spec.CrossAccountAccess = []v1beta1.CrossAccountAccess{{Principal: "123456789012", Prefix: "shared/"}}
+				s.Spec.NetworkRestrictions = &v1beta1.NetworkRestrictions{VPCEndpointIDs: []string{"vpce-1a2b3c4d"}}
+			}),
+			wantAnnotations: []string{V1BETA1_SPEC_ANNOTATION},
+		},
+		{
+			name: "v1beta1 only status",
+			src: v1beta1S3(func(s *v1beta1.S3) {
+				s.Status.Consumers = []v1beta1.ConsumerStatus{{Name: "a"}}
+				s.Status.ApprovalSpecHash = "abc"
+				s.Status.Lockdown = &v1beta1.LockdownStatus{
+					Reason:                "INC-1",
+					Since:                 since,
+					DeactivatedAccessKeys: []v1beta1.LockedAccessKey{{Username: "team-a.bucket.s3", AccessKeyID: "AKIA"}},
+					PreviousBucketPolicy:  `{"Statement":[]}`,
+				}
+				s.Status.SizeBytes, s.Status.SizeCheckedAt = 1024, &since
+			}),
+			wantAnnotations: []string{V1BETA1_STATUS_ANNOTATION},
+		},
+		{
+			name: "both",
+			src: v1beta1S3(func(s *v1beta1.S3) {
+				s.Spec.Versioning = v1beta1.FEATURE_UNMANAGED
+				s.Status.Lockdown = &v1beta1.LockdownStatus{Reason: "INC-1", Since: since}
+			}),
+			wantAnnotations: []string{V1BETA1_SPEC_ANNOTATION, V1BETA1_STATUS_ANNOTATION},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			spoke := &S3{}
+			if err := spoke.ConvertFrom(tt.src.DeepCopy()); err != nil {
+				t.Fatalf("ConvertFrom() error = %v", err)
+			}
+			for _, annotation := range []string{V1BETA1_SPEC_ANNOTATION, V1BETA1_STATUS_ANNOTATION} {
+				_, got := spoke.GetAnnotations()[annotation]
+				want := false
+				for _, a := range tt.wantAnnotations {
+					want = want || a == annotation
+				}
+				if got != want {
+					t.Errorf("ConvertFrom() annotation %v set = %v, want %v", annotation, got, want)
+				}
+			}
+
+			hub := &v1beta1.S3{}
+			if err := spoke.ConvertTo(hub); err != nil {
+				t.Fatalf("ConvertTo() error = %v", err)
+			}
+			if !equality.Semantic.DeepEqual(hub, tt.src) {
+				t.Errorf("round trip changed the object: %v", diff.ObjectReflectDiff(tt.src, hub))
+			}
+		})
+	}
+}
+
+func TestConvertV1alpha1RoundTrip(t *testing.T) {
+	src := &S3{
+		ObjectMeta: metav1.ObjectMeta{Name: "bucket", Namespace: "team-a"},
+		Spec: S3Spec{
+			IAMUserSpec:      BucketIAMUser{Username: "team-a-bucket"},
+			Region:           "eu-west-1",
+			BucketName:       "my-bucket",
+			BucketACL:        "private",
+			EnableObjectLock: true,
+			EnableVersioning: true,
+			BucketPolicy:     `{"Statement":[]}`,
+		},
+		Status: S3Status{
+			Status:            "Ready",
+			BucketName:        "my-bucket",
+			Region:            "eu-west-1",
+			ObjectLockEnabled: true,
+			Conditions:        []Condition{{Type: "Ready", Status: v1.ConditionTrue, LastTransitionTime: since}},
+		},
+	}
+
+	hub := &v1beta1.S3{}
+	if err := src.DeepCopy().ConvertTo(hub); err != nil {
+		t.Fatalf("ConvertTo() error = %v", err)
+	}
+	if hub.Spec.Versioning != v1beta1.FEATURE_ENABLED || hub.Spec.TransferAcceleration != v1beta1.FEATURE_SUSPENDED {
+		t.Errorf("ConvertTo() versioning = %q, transferAcceleration = %q", hub.Spec.Versioning, hub.Spec.TransferAcceleration)
+	}
+
+	spoke := &S3{}
+	if err := spoke.ConvertFrom(hub); err != nil {
+		t.Fatalf("ConvertFrom() error = %v", err)
+	}
+	if !equality.Semantic.DeepEqual(spoke, src) {
+		t.Errorf("round trip changed the object: %v", diff.ObjectReflectDiff(src, spoke))
+	}
+}
+
+// a status written through v1alpha1, e.g: by an old client, must not drop what only v1beta1 can hold
+func TestConvertStatusUpdateThroughV1alpha1(t *testing.T) {
+	lockdown := &v1beta1.LockdownStatus{Reason: "INC-1", Since: since, PreviousBucketPolicy: `{"Statement":[]}`}
+	src := v1beta1S3(func(s *v1beta1.S3) {
+		s.Spec.Versioning = v1beta1.FEATURE_UNMANAGED
+		s.Status.Consumers = []v1beta1.ConsumerStatus{{Name: "a"}}
+		s.Status.Lockdown = lockdown
+	})
+
+	spoke := &S3{}
+	if err := spoke.ConvertFrom(src.DeepCopy()); err != nil {
+		t.Fatalf("ConvertFrom() error = %v", err)
+	}
+	spoke.Status.Status = string(v1beta1.PHASE_STALLED)
+	spoke.Status.Conditions = []Condition{{Type: "Stalled", Status: v1.ConditionTrue, LastTransitionTime: since, Reason: "AccessDenied"}}
+	spoke.Spec.EnableVersioning = true
+
+	hub := &v1beta1.S3{}
+	if err := spoke.ConvertTo(hub); err != nil {
+		t.Fatalf("ConvertTo() error = %v", err)
+	}
+	if hub.Status.Phase != v1beta1.PHASE_STALLED || len(hub.Status.Conditions) != 1 || hub.Status.Conditions[0].Type != "Stalled" {
+		t.Errorf("ConvertTo() did not take the v1alpha1 status, phase = %v, conditions = %v", hub.Status.Phase, hub.Status.Conditions)
+	}
+	if hub.Spec.Versioning != v1beta1.FEATURE_ENABLED {
+		t.Errorf("ConvertTo() versioning = %q, want the v1alpha1 value", hub.Spec.Versioning)
+	}
+	if !equality.Semantic.DeepEqual(hub.Status.Lockdown, lockdown) || len(hub.Status.Consumers) != 1 {
+		t.Errorf("ConvertTo() lost the v1beta1 status, lockdown = %v, consumers = %v", hub.Status.Lockdown, hub.Status.Consumers)
+	}
+	if _, ok := hub.GetAnnotations()[V1BETA1_STATUS_ANNOTATION]; ok {
+		t.Errorf("ConvertTo() left the %v annotation on the hub", V1BETA1_STATUS_ANNOTATION)
+	}
+}
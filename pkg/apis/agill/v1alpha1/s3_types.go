@@ -8,15 +8,24 @@ import (
 // NOTE: json tags are required.  Any new fields you add must have json tags for the fields to be serialized.
 
 // S3Spec defines the desired state of S3
+// +kubebuilder:validation:XValidation:rule="!has(oldSelf.bucketName) || (has(self.bucketName) && self.bucketName == oldSelf.bucketName)",message="bucketName is immutable once set"
+// +kubebuilder:validation:XValidation:rule="self.region == oldSelf.region",message="region is immutable"
+// +kubebuilder:validation:XValidation:rule="(has(self.enableObjectLock) && self.enableObjectLock) == (has(oldSelf.enableObjectLock) && oldSelf.enableObjectLock)",message="enableObjectLock is immutable"
 type S3Spec struct {
 	// +optional
-	IAMUserSpec IAMUser `json:"iamUser"`
+	IAMUserSpec BucketIAMUser `json:"iamUser"`
 
 	// +kubebuilder:validation:Required
 	Region string `json:"region,required"`
 
-	// +kubebuilder:validation:Required
-	BucketName string `json:"bucketName,required"`
+	// Globally unique bucket name. When omitted, a name is generated from bucketNamePrefix,
+	// the namespace and a hash, and recorded in status.bucketName.
+	// +optional
+	BucketName string `json:"bucketName,omitempty"`
+
+	// Prefix of the generated bucket name, used only when bucketName is omitted. Defaults to the CR name.
+	// +optional
+	BucketNamePrefix string `json:"bucketNamePrefix,omitempty"`
 
 	// The canned ACL to apply to the bucket.
 	// +kubebuilder:validation:Required
@@ -39,13 +48,33 @@ type S3Spec struct {
 	BucketPolicy string `json:"bucketPolicy,omitempty"`
 }
 
-type IAMUser struct {
+// BucketIAMUser is the IAM user created for the bucket
+type BucketIAMUser struct {
 	Username string `json:"username"`
 }
 
 // S3Status defines the observed state of S3
 type S3Status struct {
 	Status string `json:"status"`
+
+	// Name of the bucket managed for this CR, set once the bucket is created or adopted
+	// +optional
+	BucketName string `json:"bucketName,omitempty"`
+
+	// Region the bucket was created in
+	// +optional
+	Region string `json:"region,omitempty"`
+
+	// Whether the bucket was created with object lock enabled
+	// +optional
+	ObjectLockEnabled bool `json:"objectLockEnabled,omitempty"`
+
+	// Bumped every time a generated bucket name turns out to be taken
+	// +optional
+	BucketNameAttempt int `json:"bucketNameAttempt,omitempty"`
+
+	// +optional
+	Conditions []Condition `json:"conditions,omitempty"`
 }
 
 // +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
@@ -53,7 +82,7 @@ type S3Status struct {
 // S3 is the Schema for the s3s API
 // +kubebuilder:subresource:status
 // +kubebuilder:resource:path=s3s,scope=Namespaced
-// +kubebuilder:printcolumn:name="bucket-name",type=string,JSONPath=`.spec.bucketName`
+// +kubebuilder:printcolumn:name="bucket-name",type=string,JSONPath=`.status.bucketName`
 // +kubebuilder:printcolumn:name="IAM-User",type=string,JSONPath=`.spec.iamUser.username`
 // +kubebuilder:printcolumn:name="Status",type=string,JSONPath=`.status.status`
 // +kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
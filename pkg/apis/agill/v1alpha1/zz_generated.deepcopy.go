@@ -9,17 +9,34 @@ import (
 )
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *IAMUser) DeepCopyInto(out *IAMUser) {
+func (in *BucketIAMUser) DeepCopyInto(out *BucketIAMUser) {
 	*out = *in
 	return
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new IAMUser.
-func (in *IAMUser) DeepCopy() *IAMUser {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new BucketIAMUser.
+func (in *BucketIAMUser) DeepCopy() *BucketIAMUser {
 	if in == nil {
 		return nil
 	}
-	out := new(IAMUser)
+	out := new(BucketIAMUser)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *Condition) DeepCopyInto(out *Condition) {
+	*out = *in
+	in.LastTransitionTime.DeepCopyInto(&out.LastTransitionTime)
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Condition.
+func (in *Condition) DeepCopy() *Condition {
+	if in == nil {
+		return nil
+	}
+	out := new(Condition)
 	in.DeepCopyInto(out)
 	return out
 }
@@ -30,7 +47,7 @@ func (in *S3) DeepCopyInto(out *S3) {
 	out.TypeMeta = in.TypeMeta
 	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
 	out.Spec = in.Spec
-	out.Status = in.Status
+	in.Status.DeepCopyInto(&out.Status)
 	return
 }
 
@@ -105,6 +122,13 @@ func (in *S3Spec) DeepCopy() *S3Spec {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *S3Status) DeepCopyInto(out *S3Status) {
 	*out = *in
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 	return
 }
 
@@ -0,0 +1,70 @@
+package v1beta1
+
+// bucketActions are granted on the bucket ARN, objectActions on <bucket ARN>/*
+type accessLevelActions struct {
+	bucketActions []string
+	objectActions []string
+}
+
+var (
+	readOnlyActions = accessLevelActions{
+		bucketActions: []string{"s3:GetBucketLocation", "s3:ListBucket", "s3:ListBucketVersions"},
+		objectActions: []string{"s3:GetObject", "s3:GetObjectTagging", "s3:GetObjectVersion", "s3:GetObjectVersionTagging"},
+	}
+	writeOnlyActions = accessLevelActions{
+		bucketActions: []string{"s3:GetBucketLocation", "s3:ListBucketMultipartUploads"},
+		objectActions: []string{"s3:AbortMultipartUpload", "s3:ListMultipartUploadParts", "s3:PutObject", "s3:PutObjectTagging"},
+	}
+	readWriteActions = accessLevelActions{
+		bucketActions: []string{"s3:GetBucketLocation", "s3:ListBucket", "s3:ListBucketMultipartUploads", "s3:ListBucketVersions"},
+		objectActions: []string{"s3:AbortMultipartUpload", "s3:DeleteObject", "s3:DeleteObjectTagging", "s3:DeleteObjectVersion",
+			"s3:GetObject", "s3:GetObjectTagging", "s3:GetObjectVersion", "s3:GetObjectVersionTagging",
+			"s3:ListMultipartUploadParts", "s3:PutObject", "s3:PutObjectTagging"},
+	}
+	adminActions = accessLevelActions{
+		bucketActions: []string{"s3:*"},
+		objectActions: []string{"s3:*"},
+	}
+)
+
+// actionsForAccessLevel returns the curated action sets of a level, custom actions apply to the bucket and its objects alike
+func actionsForAccessLevel(level AccessLevel, customActions []string) accessLevelActions {
+	switch level {
+	case ACCESS_LEVEL_READ_ONLY:
+		return readOnlyActions
+	case ACCESS_LEVEL_WRITE_ONLY:
+		return writeOnlyActions
+	case ACCESS_LEVEL_READ_WRITE:
+		return readWriteActions
+	case ACCESS_LEVEL_CUSTOM:
+		return accessLevelActions{bucketActions: customActions, objectActions: customActions}
+	default:
+		// omitted keeps the access buckets always had
+		return adminActions
+	}
+}
+
+// prefixScopedBucketActions keeps the bucket actions a consumer may get: listing, which s3:prefix conditions can limit,
+// and GetBucketLocation. s3:* expands to those.
+func prefixScopedBucketActions(bucketActions []string) (listActions, locationActions []string) {
+	list, location := map[string]bool{}, false
+	for _, action := range bucketActions {
+		switch action {
+		case "s3:*":
+			list["s3:ListBucket"], list["s3:ListBucketVersions"], location = true, true, true
+		case "s3:ListBucket", "s3:ListBucketVersions":
+			list[action] = true
+		case "s3:GetBucketLocation":
+			location = true
+		}
+	}
+	for _, action := range []string{"s3:ListBucket", "s3:ListBucketVersions"} {
+		if list[action] {
+			listActions = append(listActions, action)
+		}
+	}
+	if location {
+		locationActions = []string{"s3:GetBucketLocation"}
+	}
+	return listActions, locationActions
+}
@@ -0,0 +1,61 @@
+package v1beta1
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestPrefixScopedBucketActions(t *testing.T) {
+	tests := []struct {
+		name          string
+		bucketActions []string
+		wantList      []string
+		wantLocation  []string
+	}{
+		{name: "none", bucketActions: nil},
+		{
+			name:          "admin",
+			bucketActions: adminActions.bucketActions,
+			wantList:      []string{"s3:ListBucket", "s3:ListBucketVersions"},
+			wantLocation:  []string{"s3:GetBucketLocation"},
+		},
+		{
+			name:          "read only",
+			bucketActions: readOnlyActions.bucketActions,
+			wantList:      []string{"s3:ListBucket", "s3:ListBucketVersions"},
+			wantLocation:  []string{"s3:GetBucketLocation"},
+		},
+		{
+			// multipart uploads of other prefixes cannot be hidden, so they are dropped
+			name:          "write only",
+			bucketActions: writeOnlyActions.bucketActions,
+			wantLocation:  []string{"s3:GetBucketLocation"},
+		},
+		{
+			name:          "read write",
+			bucketActions: readWriteActions.bucketActions,
+			wantList:      []string{"s3:ListBucket", "s3:ListBucketVersions"},
+			wantLocation:  []string{"s3:GetBucketLocation"},
+		},
+		{
+			name:          "custom, ordered and deduplicated",
+			bucketActions: []string{"s3:ListBucketVersions", "s3:PutBucketPolicy", "s3:ListBucket", "s3:ListBucketVersions"},
+			wantList:      []string{"s3:ListBucket", "s3:ListBucketVersions"},
+		},
+		{
+			name:          "bucket wide actions only",
+			bucketActions: []string{"s3:DeleteBucket", "s3:PutBucketPolicy", "s3:Get*"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotList, gotLocation := prefixScopedBucketActions(tt.bucketActions)
+			if !reflect.DeepEqual(gotList, tt.wantList) {
+				t.Errorf("prefixScopedBucketActions() list = %v, want %v", gotList, tt.wantList)
+			}
+			if !reflect.DeepEqual(gotLocation, tt.wantLocation) {
+				t.Errorf("prefixScopedBucketActions() location = %v, want %v", gotLocation, tt.wantLocation)
+			}
+		})
+	}
+}
@@ -0,0 +1,51 @@
+package v1beta1
+
+import (
+	"github.com/agill17/s3-operator/pkg/utils"
+)
+
+// Sids of the baseline statements, stable so PutBucketPolicy replaces them in place
+const (
+	BASELINE_DENY_INSECURE_TRANSPORT_SID = GENERATED_SID_PREFIX + "BaselineDenyInsecureTransport"
+	BASELINE_DENY_UNENCRYPTED_PUT_SID    = GENERATED_SID_PREFIX + "BaselineDenyUnencryptedPut"
+)
+
+// server side encryption values accepted by the encryption baseline
+var baselineEncryptionAlgorithms = []string{"AES256", "aws:kms", "aws:kms:dsse"}
+
+// BaselinePolicy are bucket policy statements enforced by the operator on every bucket.
+// They are configured per operator and namespace, never in the CR, so bucket owners cannot remove them.
+// +k8s:deepcopy-gen=false
+type BaselinePolicy struct {
+	// deny every request not sent over TLS
+	DenyInsecureTransport bool
+	// deny PutObject calls without a server side encryption header
+	RequireEncryption bool
+}
+
+// bucketPolicyStatements returns the enabled baseline statements, Deny always wins over any Allow in bucketPolicy
+func (b BaselinePolicy) bucketPolicyStatements(region, bucketName string) []PolicyStatement {
+	var statements []PolicyStatement
+	if b.DenyInsecureTransport {
+		statements = append(statements, PolicyStatement{
+			SID:       BASELINE_DENY_INSECURE_TRANSPORT_SID,
+			Effect:    "Deny",
+			Principal: &Principal{Wildcard: true},
+			Action:    []string{"s3:*"},
+			Resource:  []string{utils.S3BucketARN(region, bucketName), utils.S3ObjectsARN(region, bucketName)},
+			Condition: PolicyCondition{"Bool": {"aws:SecureTransport": {"false"}}},
+		})
+	}
+	if b.RequireEncryption {
+		// negated operators also match requests without the header
+		statements = append(statements, PolicyStatement{
+			SID:       BASELINE_DENY_UNENCRYPTED_PUT_SID,
+			Effect:    "Deny",
+			Principal: &Principal{Wildcard: true},
+			Action:    []string{"s3:PutObject"},
+			Resource:  []string{utils.S3ObjectsARN(region, bucketName)},
+			Condition: PolicyCondition{"StringNotEquals": {"s3:x-amz-server-side-encryption": baselineEncryptionAlgorithms}},
+		})
+	}
+	return statements
+}
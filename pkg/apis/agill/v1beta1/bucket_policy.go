@@ -0,0 +1,49 @@
+package v1beta1
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+// generatedBucketPolicyStatements returns the statements the operator adds to spec.bucketPolicy
+func (s S3) generatedBucketPolicyStatements(baseline BaselinePolicy, operatorUserID string) []PolicyStatement {
+	statements := baseline.bucketPolicyStatements(s.GetRegion(), s.GetBucketName())
+	for i, c := range s.Spec.CrossAccountAccess {
+		statements = append(statements, c.bucketPolicyStatements(i, s.GetRegion(), s.GetBucketName())...)
+	}
+	return append(statements, s.Spec.NetworkRestrictions.bucketPolicyDenyStatement(s.GetRegion(), s.GetBucketName(), operatorUserID)...)
+}
+
+// DesiredBucketPolicy merges the generated statements into the rendered bucketPolicy ( or bucketPolicyFrom ), so PutBucketPolicy
+// stays the only writer of the bucket policy. Returns the rendered policy untouched when nothing is generated and empty when
+// there is no policy at all. baseline is resolved by the reconciler from the operator and namespace settings,
+// operatorUserID is the aws:userid pattern of the operator, exempted from the network Deny.
+func (s S3) DesiredBucketPolicy(rendered string, baseline BaselinePolicy, operatorUserID string) (string, error) {
+	generated := s.generatedBucketPolicyStatements(baseline, operatorUserID)
+	if len(generated) == 0 {
+		return rendered, nil
+	}
+
+	doc := &PolicyDocument{Version: "2012-10-17"}
+	if rendered != "" {
+		var err error
+		if doc, err = ParsePolicyDocument(rendered); err != nil {
+			return "", err
+		}
+	}
+
+	// generated Sids are reserved, anything using them in bucketPolicy is replaced
+	statements := make([]PolicyStatement, 0, len(doc.Statements)+len(generated))
+	for _, statement := range doc.Statements {
+		if !strings.HasPrefix(statement.SID, GENERATED_SID_PREFIX) {
+			statements = append(statements, statement)
+		}
+	}
+	doc.Statements = append(statements, generated...)
+
+	policy, err := json.Marshal(doc)
+	if err != nil {
+		return "", err
+	}
+	return string(policy), nil
+}
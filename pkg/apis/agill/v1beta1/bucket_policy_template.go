@@ -0,0 +1,77 @@
+package v1beta1
+
+import (
+	"bytes"
+	"strings"
+	"text/template"
+
+	"github.com/agill17/s3-operator/pkg/utils"
+)
+
+// placeholders used to render bucketPolicy at admission, before the account and user are known
+const (
+	placeholderAccountID  = "000000000000"
+	placeholderIAMUserARN = "arn:aws:iam::000000000000:user/placeholder"
+)
+
+// BucketPolicyTemplateData are the variables available in bucketPolicy templates
+type BucketPolicyTemplateData struct {
+	// final bucket name, generated names included
+	BucketName string
+	// e.g: arn:aws:s3:::my-bucket, within the partition of the bucket region
+	BucketARN string
+	// ARN of spec.iamUser or of the user of the referenced IAMUser CR, empty for buckets with consumers only
+	IAMUserARN string
+	// AWS account of the operator credentials
+	AccountID string
+	Region    string
+	Namespace string
+}
+
+// BucketPolicyTemplateData builds the template variables of the bucket, accountID and iamUserARN are looked up by the caller
+func (s S3) BucketPolicyTemplateData(accountID, iamUserARN string) BucketPolicyTemplateData {
+	return BucketPolicyTemplateData{
+		BucketName: s.GetBucketName(),
+		BucketARN:  utils.S3BucketARN(s.GetRegion(), s.GetBucketName()),
+		IAMUserARN: iamUserARN,
+		AccountID:  accountID,
+		Region:     s.GetRegion(),
+		Namespace:  s.GetNamespace(),
+	}
+}
+
+// IsBucketPolicyTemplate is true when the policy uses template actions, plain policies are used as they are
+func IsBucketPolicyTemplate(policy string) bool {
+	return strings.Contains(policy, "{{")
+}
+
+// renderBucketPolicyPreview renders spec.bucketPolicy for admission checks. The account and user are only known to
+// the reconciler, placeholders are enough to check the result is a policy.
+func (s S3) renderBucketPolicyPreview() (string, error) {
+	return RenderBucketPolicy(s.Spec.BucketPolicy, s.BucketPolicyTemplateData(placeholderAccountID, placeholderIAMUserARN))
+}
+
+// RenderedBucketPolicyPreview returns spec.bucketPolicy rendered with placeholders, empty when it cannot be rendered
+func (s S3) RenderedBucketPolicyPreview() string {
+	rendered, err := s.renderBucketPolicyPreview()
+	if err != nil {
+		return ""
+	}
+	return rendered
+}
+
+// RenderBucketPolicy executes a bucketPolicy template, unknown variables and syntax errors are returned as errors
+func RenderBucketPolicy(policy string, data BucketPolicyTemplateData) (string, error) {
+	if !IsBucketPolicyTemplate(policy) {
+		return policy, nil
+	}
+	tmpl, err := template.New("bucketPolicy").Parse(policy)
+	if err != nil {
+		return "", err
+	}
+	var rendered bytes.Buffer
+	if err := tmpl.Execute(&rendered, data); err != nil {
+		return "", err
+	}
+	return rendered.String(), nil
+}
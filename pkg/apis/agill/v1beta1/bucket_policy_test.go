@@ -0,0 +1,98 @@
+package v1beta1
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDesiredBucketPolicy(t *testing.T) {
+	userPolicy := `{"Version":"2012-10-17","Statement":[` +
+		`{"Sid":"PublicRead","Effect":"Allow","Principal":"*","Action":"s3:GetObject","Resource":"arn:aws:s3:::my-bucket/*"},` +
+		`{"Sid":"S3OperatorBaselineDenyInsecureTransport","Effect":"Allow","Principal":"*","Action":"s3:*","Resource":"arn:aws:s3:::my-bucket/*"}]}`
+	bothBaselines := BaselinePolicy{DenyInsecureTransport: true, RequireEncryption: true}
+
+	tests := []struct {
+		name     string
+		mutate   func(s *S3)
+		rendered string
+		baseline BaselinePolicy
+		// Sids of the resulting policy, nil when the rendered policy is returned untouched
+		wantSIDs []string
+		wantErr  bool
+	}{
+		{name: "no policy", mutate: func(s *S3) {}},
+		{name: "nothing generated", mutate: func(s *S3) {}, rendered: userPolicy},
+		{
+			// without EnforceInBucketPolicy networks only restrict the generated credentials
+			name: "network restricted in the user policy only",
+			mutate: func(s *S3) {
+				s.Spec.NetworkRestrictions = &NetworkRestrictions{VPCIDs: []string{"vpc-1a2b3c4d"}}
+			},
+			rendered: userPolicy,
+		},
+		{
+			name:     "baseline only",
+			mutate:   func(s *S3) {},
+			baseline: bothBaselines,
+			wantSIDs: []string{BASELINE_DENY_INSECURE_TRANSPORT_SID, BASELINE_DENY_UNENCRYPTED_PUT_SID},
+		},
+		{
+			// reserved Sids in bucketPolicy are replaced, bucket owners cannot weaken the baseline with them
+			name:     "baseline merged into the rendered policy",
+			mutate:   func(s *S3) {},
+			rendered: userPolicy,
+			baseline: BaselinePolicy{DenyInsecureTransport: true},
+			wantSIDs: []string{"PublicRead", BASELINE_DENY_INSECURE_TRANSPORT_SID},
+		},
+		{
+			name: "every generated statement",
+			mutate: func(s *S3) {
+				s.Spec.CrossAccountAccess = []CrossAccountAccess{{Principal: "123456789012"}}
+				s.Spec.NetworkRestrictions = &NetworkRestrictions{VPCIDs: []string{"vpc-1a2b3c4d"}, EnforceInBucketPolicy: true}
+			},
+			rendered: userPolicy,
+			baseline: bothBaselines,
+			wantSIDs: []string{
+				"PublicRead",
+				BASELINE_DENY_INSECURE_TRANSPORT_SID, BASELINE_DENY_UNENCRYPTED_PUT_SID,
+				"S3OperatorCrossAccount0Bucket", "S3OperatorCrossAccount0Objects",
+				NETWORK_DENY_SID,
+			},
+		},
+		{name: "invalid rendered policy", mutate: func(s *S3) {}, rendered: `{"Statement":`, baseline: bothBaselines, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := validS3()
+			tt.mutate(&s)
+			got, err := s.DesiredBucketPolicy(tt.rendered, tt.baseline, "AROAEXAMPLE:*")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("DesiredBucketPolicy() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if tt.wantSIDs == nil {
+				if got != tt.rendered {
+					t.Errorf("DesiredBucketPolicy() = %v, want the rendered policy untouched", got)
+				}
+				return
+			}
+
+			doc, err := ParsePolicyDocument(got)
+			if err != nil {
+				t.Fatalf("DesiredBucketPolicy() = %v, not a policy document: %v", got, err)
+			}
+			var sids []string
+			for _, statement := range doc.Statements {
+				sids = append(sids, statement.SID)
+			}
+			if !reflect.DeepEqual(sids, tt.wantSIDs) {
+				t.Errorf("DesiredBucketPolicy() Sids = %v, want %v", sids, tt.wantSIDs)
+			}
+			if doc.Version != "2012-10-17" {
+				t.Errorf("DesiredBucketPolicy() Version = %q", doc.Version)
+			}
+		})
+	}
+}
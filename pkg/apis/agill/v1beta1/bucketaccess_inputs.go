@@ -0,0 +1,107 @@
+package v1beta1
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/agill17/s3-operator/pkg/utils"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/iam"
+)
+
+// GetAllowedAccessLevels returns the levels requests may ask for, ReadOnly when omitted
+func (g BucketAccessGrant) GetAllowedAccessLevels() []AccessLevel {
+	if len(g.Spec.AllowedAccessLevels) == 0 {
+		return []AccessLevel{ACCESS_LEVEL_READ_ONLY}
+	}
+	return g.Spec.AllowedAccessLevels
+}
+
+// Allows returns why the grant does not cover the request, empty when it does
+func (g BucketAccessGrant) Allows(r BucketAccessRequest) string {
+	if g.GetNamespace() != r.Spec.BucketRef.Namespace || g.Spec.BucketRef.Name != r.Spec.BucketRef.Name {
+		return "grant is for a different bucket"
+	}
+
+	namespaceAllowed := false
+	for _, ns := range g.Spec.Namespaces {
+		if ns == r.GetNamespace() {
+			namespaceAllowed = true
+			break
+		}
+	}
+	if !namespaceAllowed {
+		return fmt.Sprintf("namespace %v is not allowed", r.GetNamespace())
+	}
+
+	levelAllowed := false
+	for _, level := range g.GetAllowedAccessLevels() {
+		if level == r.GetAccessLevel() && level != ACCESS_LEVEL_CUSTOM {
+			levelAllowed = true
+			break
+		}
+	}
+	if !levelAllowed {
+		return fmt.Sprintf("access level %v is not allowed", r.GetAccessLevel())
+	}
+
+	if r.Spec.Prefix != "" && !strings.HasPrefix(r.Spec.Prefix, g.Spec.Prefix) {
+		return fmt.Sprintf("prefix %v is outside of the granted prefix %v", r.Spec.Prefix, g.Spec.Prefix)
+	}
+	return ""
+}
+
+func (r BucketAccessRequest) GetAccessLevel() AccessLevel {
+	if r.Spec.AccessLevel != "" {
+		return r.Spec.AccessLevel
+	}
+	return ACCESS_LEVEL_READ_ONLY
+}
+
+// GetUsername returns the user holding the credentials: the name recorded in status once created, otherwise <namespace>.<name>.access
+func (r BucketAccessRequest) GetUsername() string {
+	if r.Status.Username != "" {
+		return r.Status.Username
+	}
+	return utils.DefaultIAMUsername(r.GetNamespace(), r.GetName(), utils.IAM_USERNAME_SUFFIX_ACCESS)
+}
+
+// IAMUserOwner tags the user of the CR
+func (r BucketAccessRequest) IAMUserOwner() utils.IAMUserOwner {
+	return utils.IAMUserOwner{Kind: "BucketAccessRequest", Namespace: r.GetNamespace(), Name: r.GetName(), UID: string(r.GetUID())}
+}
+
+func (r BucketAccessRequest) GetSecretName() string {
+	if r.Spec.SecretName != "" {
+		return r.Spec.SecretName
+	}
+	return fmt.Sprintf("%v-bucket-access", r.GetName())
+}
+
+// GetPolicyInput builds the inline policy for the requested bucket, limited to the request prefix or else the grant prefix
+func (r BucketAccessRequest) GetPolicyInput(bucket S3, grant BucketAccessGrant) (*iam.PutUserPolicyInput, error) {
+	username := r.GetUsername()
+	policyName := bucket.GetPolicyName(username)
+	prefix := r.Spec.Prefix
+	if prefix == "" {
+		prefix = grant.Spec.Prefix
+	}
+
+	var policyDoc string
+	var err error
+	if prefix == "" {
+		policyDoc, err = DesiredRestrictedPolicyDocForBucket(policyName, bucket.GetRegion(), bucket.GetBucketName(), r.GetAccessLevel(), nil,
+			bucket.Spec.NetworkRestrictions)
+	} else {
+		policyDoc, err = DesiredPrefixPolicyDocForBucket(policyName, bucket.GetRegion(), bucket.GetBucketName(), prefix, r.GetAccessLevel(), nil,
+			bucket.Spec.NetworkRestrictions)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &iam.PutUserPolicyInput{
+		PolicyDocument: aws.String(policyDoc),
+		PolicyName:     aws.String(policyName),
+		UserName:       aws.String(username),
+	}, nil
+}
@@ -0,0 +1,141 @@
+package v1beta1
+
+import (
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// the request waits for the bucket to be created
+	PHASE_PENDING Phase = "Pending"
+	// no grant allows the request, its credentials were deleted
+	PHASE_REVOKED Phase = "Revoked"
+)
+
+// BucketAccessGrantSpec defines which namespaces may request access to a bucket
+type BucketAccessGrantSpec struct {
+	// S3 CR in the namespace of the grant
+	// +kubebuilder:validation:Required
+	BucketRef v1.LocalObjectReference `json:"bucketRef"`
+
+	// Namespaces allowed to create a BucketAccessRequest for the bucket
+	// +kubebuilder:validation:MinItems=1
+	Namespaces []string `json:"namespaces"`
+
+	// Access levels requests may ask for, defaults to ReadOnly. Custom cannot be granted.
+	// +optional
+	AllowedAccessLevels []AccessLevel `json:"allowedAccessLevels,omitempty"`
+
+	// Key prefix requests are confined to, must end with a slash. Omitted allows the whole bucket.
+	// +optional
+	// +kubebuilder:validation:Pattern=`^([^/*?][^*?]*/)?$`
+	Prefix string `json:"prefix,omitempty"`
+}
+
+// BucketAccessGrantStatus defines the observed state of BucketAccessGrant
+type BucketAccessGrantStatus struct {
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// BucketAccessGrant is the consent of the bucket owning namespace for other namespaces to request access to a bucket.
+// Deleting it, or removing a namespace from it, revokes the credentials of the matching requests.
+// +kubebuilder:resource:path=bucketaccessgrants,scope=Namespaced
+// +kubebuilder:printcolumn:name="Bucket",type=string,JSONPath=`.spec.bucketRef.name`
+// +kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
+type BucketAccessGrant struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+	Spec              BucketAccessGrantSpec   `json:"spec,omitempty"`
+	Status            BucketAccessGrantStatus `json:"status,omitempty"`
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// BucketAccessGrantList contains a list of BucketAccessGrant
+type BucketAccessGrantList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []BucketAccessGrant `json:"items"`
+}
+
+// BucketReference points to an S3 CR in any namespace
+type BucketReference struct {
+	// +kubebuilder:validation:Required
+	Name string `json:"name"`
+	// +kubebuilder:validation:Required
+	Namespace string `json:"namespace"`
+}
+
+// BucketAccessRequestSpec defines the access a namespace asks for
+// +kubebuilder:validation:XValidation:rule="self.bucketRef == oldSelf.bucketRef",message="bucketRef is immutable"
+type BucketAccessRequestSpec struct {
+	// +kubebuilder:validation:Required
+	BucketRef BucketReference `json:"bucketRef"`
+
+	// Defaults to ReadOnly, must be allowed by a grant
+	// +optional
+	// +kubebuilder:validation:Enum=ReadOnly;ReadWrite;WriteOnly;Admin
+	AccessLevel AccessLevel `json:"accessLevel,omitempty"`
+
+	// Key prefix to limit access to, must be within the prefix of the grant. Defaults to the prefix of the grant.
+	// +optional
+	// +kubebuilder:validation:Pattern=`^([^/*?][^*?]*/)?$`
+	Prefix string `json:"prefix,omitempty"`
+
+	// Name of the k8s secret holding the access keys, defaults to <name>-bucket-access
+	// +optional
+	SecretName string `json:"secretName,omitempty"`
+}
+
+// BucketAccessRequestStatus defines the observed state of BucketAccessRequest
+type BucketAccessRequestStatus struct {
+	// +optional
+	Phase Phase `json:"phase,omitempty"`
+
+	// Grant that allowed the request
+	// +optional
+	Grant string `json:"grant,omitempty"`
+
+	// IAM user holding the credentials, set once the user is created
+	// +optional
+	Username string `json:"username,omitempty"`
+
+	// Bucket and region the credentials were issued for
+	// +optional
+	BucketName string `json:"bucketName,omitempty"`
+	// +optional
+	Region string `json:"region,omitempty"`
+
+	// +optional
+	Conditions []Condition `json:"conditions,omitempty"`
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// BucketAccessRequest asks for credentials to a bucket in another namespace, a grant in that namespace has to allow it
+// +kubebuilder:subresource:status
+// +kubebuilder:resource:path=bucketaccessrequests,scope=Namespaced
+// +kubebuilder:printcolumn:name="Bucket-Namespace",type=string,JSONPath=`.spec.bucketRef.namespace`
+// +kubebuilder:printcolumn:name="Bucket",type=string,JSONPath=`.spec.bucketRef.name`
+// +kubebuilder:printcolumn:name="Phase",type=string,JSONPath=`.status.phase`
+// +kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
+type BucketAccessRequest struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+	Spec              BucketAccessRequestSpec   `json:"spec,omitempty"`
+	Status            BucketAccessRequestStatus `json:"status,omitempty"`
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// BucketAccessRequestList contains a list of BucketAccessRequest
+type BucketAccessRequestList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []BucketAccessRequest `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&BucketAccessGrant{}, &BucketAccessGrantList{}, &BucketAccessRequest{}, &BucketAccessRequestList{})
+}
@@ -0,0 +1,42 @@
+package v1beta1
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+)
+
+// length of the hex encoded approval spec hash
+const APPROVAL_SPEC_HASH_LENGTH = 16
+
+// canned ACLs that make a bucket public
+var publicBucketACLs = map[string]bool{"public-read": true, "public-read-write": true}
+
+// NeedsApproval is true for public ACLs and bucket policies allowing Principal "*", bucketPolicy is the policy about to be put
+func (s S3) NeedsApproval(bucketPolicy string) bool {
+	if publicBucketACLs[s.Spec.BucketACL] {
+		return true
+	}
+	if bucketPolicy == "" {
+		return false
+	}
+	// a malformed policy fails on PutBucketPolicy, treat it as public until then
+	doc, err := ParsePolicyDocument(bucketPolicy)
+	return err != nil || doc.HasPublicPrincipal()
+}
+
+// ApprovalSpecHash hashes the spec together with the bucket policy about to be put, so changes to a bucketPolicyFrom
+// ConfigMap invalidate an approval as well
+func (s S3) ApprovalSpecHash(bucketPolicy string) (string, error) {
+	spec, err := json.Marshal(s.Spec)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(append(append(spec, '\n'), bucketPolicy...))
+	return hex.EncodeToString(sum[:])[:APPROVAL_SPEC_HASH_LENGTH], nil
+}
+
+// Approves is true when the approval references the CR and its current hash
+func (a BucketApproval) Approves(s S3, specHash string) bool {
+	return a.Spec.BucketRef.Namespace == s.GetNamespace() && a.Spec.BucketRef.Name == s.GetName() && a.Spec.SpecHash == specHash
+}
@@ -0,0 +1,138 @@
+package v1beta1
+
+import (
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestNeedsApproval(t *testing.T) {
+	tests := []struct {
+		name         string
+		acl          string
+		bucketPolicy string
+		want         bool
+	}{
+		{name: "private", acl: "private", want: false},
+		{name: "authenticated read", acl: "authenticated-read", want: false},
+		{name: "public read", acl: "public-read", want: true},
+		{name: "public read write", acl: "public-read-write", want: true},
+		{
+			name:         "account principal",
+			acl:          "private",
+			bucketPolicy: `{"Statement":[{"Effect":"Allow","Principal":{"AWS":"arn:aws:iam::123456789012:root"},"Action":"s3:GetObject","Resource":"arn:aws:s3:::my-bucket/*"}]}`,
+			want:         false,
+		},
+		{
+			name:         "wildcard principal",
+			acl:          "private",
+			bucketPolicy: `{"Statement":[{"Effect":"Allow","Principal":"*","Action":"s3:GetObject","Resource":"arn:aws:s3:::my-bucket/*"}]}`,
+			want:         true,
+		},
+		{
+			name:         "aws wildcard principal in a list",
+			acl:          "private",
+			bucketPolicy: `{"Statement":{"Effect":"Allow","Principal":{"AWS":["arn:aws:iam::123456789012:root","*"]},"Action":"s3:GetObject","Resource":"arn:aws:s3:::my-bucket/*"}}`,
+			want:         true,
+		},
+		{
+			// the baseline and network statements deny everyone, that is not public
+			name:         "deny for every principal",
+			acl:          "private",
+			bucketPolicy: `{"Statement":[{"Effect":"Deny","Principal":"*","Action":"s3:*","Resource":"arn:aws:s3:::my-bucket/*","Condition":{"Bool":{"aws:SecureTransport":false}}}]}`,
+			want:         false,
+		},
+		{name: "malformed policy", acl: "private", bucketPolicy: `{"Statement":`, want: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := validS3()
+			s.Spec.BucketACL = tt.acl
+			if got := s.NeedsApproval(tt.bucketPolicy); got != tt.want {
+				t.Errorf("NeedsApproval() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestApprovalSpecHash(t *testing.T) {
+	const policy = `{"Statement":[{"Effect":"Allow","Principal":"*","Action":"s3:GetObject","Resource":"arn:aws:s3:::my-bucket/*"}]}`
+	base := validS3()
+	base.Spec.BucketACL = "public-read"
+	baseHash, err := base.ApprovalSpecHash(policy)
+	if err != nil {
+		t.Fatalf("ApprovalSpecHash() error = %v", err)
+	}
+	if len(baseHash) != APPROVAL_SPEC_HASH_LENGTH {
+		t.Errorf("ApprovalSpecHash() = %v, want %v chars", baseHash, APPROVAL_SPEC_HASH_LENGTH)
+	}
+
+	tests := []struct {
+		name         string
+		mutate       func(s *S3)
+		bucketPolicy string
+		wantSame     bool
+	}{
+		{name: "unchanged", mutate: func(s *S3) {}, bucketPolicy: policy, wantSame: true},
+		{
+			// only the spec and the policy are approved
+			name: "metadata and status",
+			mutate: func(s *S3) {
+				s.Labels = map[string]string{"team": "a"}
+				s.Status.Phase = PHASE_READY
+				s.Status.ApprovalSpecHash = "0123456789abcdef"
+			},
+			bucketPolicy: policy,
+			wantSame:     true,
+		},
+		{name: "spec change", mutate: func(s *S3) { s.Spec.BucketACL = "public-read-write" }, bucketPolicy: policy},
+		{name: "other region", mutate: func(s *S3) { s.Spec.Region = "eu-west-1" }, bucketPolicy: policy},
+		{
+			// e.g: the bucketPolicyFrom ConfigMap changed
+			name:         "bucket policy change",
+			mutate:       func(s *S3) {},
+			bucketPolicy: `{"Statement":[{"Effect":"Allow","Principal":"*","Action":"s3:*","Resource":"arn:aws:s3:::my-bucket/*"}]}`,
+		},
+		{name: "no bucket policy", mutate: func(s *S3) {}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := *base.DeepCopy()
+			tt.mutate(&s)
+			got, err := s.ApprovalSpecHash(tt.bucketPolicy)
+			if err != nil {
+				t.Fatalf("ApprovalSpecHash() error = %v", err)
+			}
+			if (got == baseHash) != tt.wantSame {
+				t.Errorf("ApprovalSpecHash() = %v, base %v, want the same hash: %v", got, baseHash, tt.wantSame)
+			}
+		})
+	}
+}
+
+func TestApproves(t *testing.T) {
+	s := validS3()
+	approval := func(namespace, name, hash string) BucketApproval {
+		return BucketApproval{
+			ObjectMeta: metav1.ObjectMeta{Name: "approval"},
+			Spec:       BucketApprovalSpec{BucketRef: BucketReference{Namespace: namespace, Name: name}, SpecHash: hash},
+		}
+	}
+	tests := []struct {
+		name     string
+		approval BucketApproval
+		want     bool
+	}{
+		{name: "matching", approval: approval("team-a", "bucket", "hash"), want: true},
+		{name: "stale hash", approval: approval("team-a", "bucket", "other"), want: false},
+		{name: "other bucket", approval: approval("team-a", "other", "hash"), want: false},
+		{name: "other namespace", approval: approval("team-b", "bucket", "hash"), want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.approval.Approves(s, "hash"); got != tt.want {
+				t.Errorf("Approves() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,54 @@
+package v1beta1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// the bucket is public and no BucketApproval references its current spec hash
+	PHASE_AWAITING_APPROVAL Phase = "AwaitingApproval"
+)
+
+// BucketApprovalSpec approves one version of a public bucket
+type BucketApprovalSpec struct {
+	// +kubebuilder:validation:Required
+	BucketRef BucketReference `json:"bucketRef"`
+
+	// status.approvalSpecHash of the S3 CR being approved, any later change to the spec or bucket policy needs a new approval
+	// +kubebuilder:validation:Required
+	// +kubebuilder:validation:MinLength=1
+	SpecHash string `json:"specHash"`
+}
+
+// BucketApprovalStatus defines the observed state of BucketApproval
+type BucketApprovalStatus struct {
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// BucketApproval is the sign off of a platform admin for a public bucket ( public ACL or Principal "*" in its policy ).
+// Cluster scoped, so bucket owners cannot approve their own buckets.
+// +kubebuilder:resource:path=bucketapprovals,scope=Cluster
+// +kubebuilder:printcolumn:name="Namespace",type=string,JSONPath=`.spec.bucketRef.namespace`
+// +kubebuilder:printcolumn:name="Bucket",type=string,JSONPath=`.spec.bucketRef.name`
+// +kubebuilder:printcolumn:name="Spec-Hash",type=string,JSONPath=`.spec.specHash`
+// +kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
+type BucketApproval struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+	Spec              BucketApprovalSpec   `json:"spec,omitempty"`
+	Status            BucketApprovalStatus `json:"status,omitempty"`
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// BucketApprovalList contains a list of BucketApproval
+type BucketApprovalList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []BucketApproval `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&BucketApproval{}, &BucketApprovalList{})
+}
@@ -0,0 +1,143 @@
+package v1beta1
+
+import (
+	"time"
+
+	"github.com/agill17/s3-operator/pkg/utils"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// condition types set by the operator
+const (
+	// the bucket and everything around it matches the spec
+	CONDITION_READY = "Ready"
+	// the last reconcile failed with an error that retrying cannot fix, the CR waits for a spec change or DEFAULT_STALLED_RETRY_AFTER
+	CONDITION_STALLED = "Stalled"
+	// Access Analyzer reported security warnings for the bucket or user policies that were not acknowledged
+	CONDITION_POLICY_WARNINGS = "PolicyWarnings"
+	// the CR violates an S3Guardrail selecting its namespace, it is not reconciled until it complies
+	CONDITION_GUARDRAIL_VIOLATION = "GuardrailViolation"
+	// creating the bucket or IAM users of the CR would exceed a quota of its namespace
+	CONDITION_QUOTA_EXCEEDED = "QuotaExceeded"
+)
+
+// Condition describes one aspect of the observed state of a CR
+type Condition struct {
+	Type   string             `json:"type"`
+	Status v1.ConditionStatus `json:"status"`
+	// generation of the CR the condition was computed for
+	// +optional
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+	// +optional
+	LastTransitionTime metav1.Time `json:"lastTransitionTime,omitempty"`
+	// +optional
+	Reason string `json:"reason,omitempty"`
+	// +optional
+	Message string `json:"message,omitempty"`
+}
+
+// GetCondition returns the condition of the given type, nil if it was never set
+func GetCondition(conditions []Condition, conditionType string) *Condition {
+	for i := range conditions {
+		if conditions[i].Type == conditionType {
+			return &conditions[i]
+		}
+	}
+	return nil
+}
+
+// SetCondition adds or updates the condition, LastTransitionTime only moves when the status flips.
+// Returns true if anything changed.
+func SetCondition(conditions *[]Condition, c Condition) bool {
+	existing := GetCondition(*conditions, c.Type)
+	if existing == nil {
+		c.LastTransitionTime = metav1.Now()
+		*conditions = append(*conditions, c)
+		return true
+	}
+	if existing.Status == c.Status && existing.Reason == c.Reason &&
+		existing.Message == c.Message && existing.ObservedGeneration == c.ObservedGeneration {
+		return false
+	}
+	if existing.Status != c.Status {
+		existing.LastTransitionTime = metav1.Now()
+	}
+	existing.Status = c.Status
+	existing.Reason = c.Reason
+	existing.Message = c.Message
+	existing.ObservedGeneration = c.ObservedGeneration
+	return true
+}
+
+// IsConditionTrue returns true if the condition is set and true
+func IsConditionTrue(conditions []Condition, conditionType string) bool {
+	c := GetCondition(conditions, conditionType)
+	return c != nil && c.Status == v1.ConditionTrue
+}
+
+// StalledRetryAfter returns how long a CR stalled on a terminal error for its current generation waits before it is
+// retried anyway, zero when it is not stalled
+func StalledRetryAfter(conditions []Condition, generation int64) time.Duration {
+	c := GetCondition(conditions, CONDITION_STALLED)
+	if c == nil || c.Status != v1.ConditionTrue || c.ObservedGeneration != generation {
+		return 0
+	}
+	if retryAfter := utils.DEFAULT_STALLED_RETRY_AFTER - time.Since(c.LastTransitionTime.Time); retryAfter > 0 {
+		return retryAfter
+	}
+	return 0
+}
+
+// IsStalled returns true if the CR stalled on a terminal error for its current generation and is not due for a retry
+func (s S3) IsStalled() bool {
+	return StalledRetryAfter(s.Status.Conditions, s.GetGeneration()) > 0
+}
+
+// IsStalled returns true if the CR stalled on a terminal error for its current generation and is not due for a retry
+func (u IAMUser) IsStalled() bool {
+	return StalledRetryAfter(u.Status.Conditions, u.GetGeneration()) > 0
+}
+
+// IsStalled returns true if the CR stalled on a terminal error for its current generation and is not due for a retry
+func (r BucketAccessRequest) IsStalled() bool {
+	return StalledRetryAfter(r.Status.Conditions, r.GetGeneration()) > 0
+}
+
+// GetPhase, SetPhase and GetConditions let the shared reconcile status helpers report on every kind
+
+func (s *S3) GetPhase() Phase {
+	return s.Status.Phase
+}
+
+func (s *S3) SetPhase(phase Phase) {
+	s.Status.Phase = phase
+}
+
+func (s *S3) GetConditions() *[]Condition {
+	return &s.Status.Conditions
+}
+
+func (u *IAMUser) GetPhase() Phase {
+	return u.Status.Phase
+}
+
+func (u *IAMUser) SetPhase(phase Phase) {
+	u.Status.Phase = phase
+}
+
+func (u *IAMUser) GetConditions() *[]Condition {
+	return &u.Status.Conditions
+}
+
+func (r *BucketAccessRequest) GetPhase() Phase {
+	return r.Status.Phase
+}
+
+func (r *BucketAccessRequest) SetPhase(phase Phase) {
+	r.Status.Phase = phase
+}
+
+func (r *BucketAccessRequest) GetConditions() *[]Condition {
+	return &r.Status.Conditions
+}
@@ -0,0 +1,81 @@
+package v1beta1
+
+import (
+	"fmt"
+	"regexp"
+
+	"github.com/agill17/s3-operator/pkg/utils"
+)
+
+var accountIDRegex = regexp.MustCompile(`^\d{12}$`)
+
+func (c CrossAccountAccess) GetAccessLevel() AccessLevel {
+	if c.AccessLevel != "" {
+		return c.AccessLevel
+	}
+	return ACCESS_LEVEL_READ_ONLY
+}
+
+// principalARN turns an account ID into its root ARN in the partition of the region, role ARNs are used as they are
+func (c CrossAccountAccess) principalARN(region string) string {
+	if accountIDRegex.MatchString(c.Principal) {
+		return fmt.Sprintf("arn:%v:iam::%v:root", utils.PartitionForRegion(region).ID(), c.Principal)
+	}
+	return c.Principal
+}
+
+// bucketPolicyStatements grants the principal the actions of its access level, listing is limited with s3:prefix
+// conditions and object actions to the ARNs under the prefix when a prefix is set. Sids are derived from the list index.
+func (c CrossAccountAccess) bucketPolicyStatements(index int, region, bucketName string) []PolicyStatement {
+	actions := actionsForAccessLevel(c.GetAccessLevel(), nil)
+	principal := &Principal{Values: map[string]StringOrSlice{"AWS": {c.principalARN(region)}}}
+	sid := fmt.Sprintf("%vCrossAccount%v", GENERATED_SID_PREFIX, index)
+
+	if c.Prefix == "" {
+		return []PolicyStatement{
+			{
+				SID:       sid + "Bucket",
+				Effect:    "Allow",
+				Principal: principal,
+				Action:    actions.bucketActions,
+				Resource:  []string{utils.S3BucketARN(region, bucketName)},
+			},
+			{
+				SID:       sid + "Objects",
+				Effect:    "Allow",
+				Principal: principal,
+				Action:    actions.objectActions,
+				Resource:  []string{utils.S3ObjectsARN(region, bucketName)},
+			},
+		}
+	}
+
+	var statements []PolicyStatement
+	listActions, locationActions := prefixScopedBucketActions(actions.bucketActions)
+	if len(listActions) > 0 {
+		statements = append(statements, PolicyStatement{
+			SID:       sid + "ListPrefix",
+			Effect:    "Allow",
+			Principal: principal,
+			Action:    listActions,
+			Resource:  []string{utils.S3BucketARN(region, bucketName)},
+			Condition: PolicyCondition{"StringLike": {"s3:prefix": {c.Prefix, c.Prefix + "*"}}},
+		})
+	}
+	if len(locationActions) > 0 {
+		statements = append(statements, PolicyStatement{
+			SID:       sid + "BucketLocation",
+			Effect:    "Allow",
+			Principal: principal,
+			Action:    locationActions,
+			Resource:  []string{utils.S3BucketARN(region, bucketName)},
+		})
+	}
+	return append(statements, PolicyStatement{
+		SID:       sid + "PrefixObjects",
+		Effect:    "Allow",
+		Principal: principal,
+		Action:    actions.objectActions,
+		Resource:  []string{utils.S3PrefixObjectsARN(region, bucketName, c.Prefix)},
+	})
+}
@@ -0,0 +1,103 @@
+package v1beta1
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCrossAccountBucketPolicyStatements(t *testing.T) {
+	account := &Principal{Values: map[string]StringOrSlice{"AWS": {"arn:aws:iam::123456789012:root"}}}
+	role := &Principal{Values: map[string]StringOrSlice{"AWS": {"arn:aws:iam::123456789012:role/reader"}}}
+	tests := []struct {
+		name   string
+		access CrossAccountAccess
+		region string
+		want   []PolicyStatement
+	}{
+		{
+			name:   "account defaults to read only on the whole bucket",
+			access: CrossAccountAccess{Principal: "123456789012"},
+			region: "us-east-1",
+			want: []PolicyStatement{
+				{
+					SID: "S3OperatorCrossAccount1Bucket", Effect: "Allow", Principal: account,
+					Action:   readOnlyActions.bucketActions,
+					Resource: []string{"arn:aws:s3:::my-bucket"},
+				},
+				{
+					SID: "S3OperatorCrossAccount1Objects", Effect: "Allow", Principal: account,
+					Action:   readOnlyActions.objectActions,
+					Resource: []string{"arn:aws:s3:::my-bucket/*"},
+				},
+			},
+		},
+		{
+			name:   "account root in the partition of the region",
+			access: CrossAccountAccess{Principal: "123456789012", AccessLevel: ACCESS_LEVEL_WRITE_ONLY},
+			region: "cn-north-1",
+			want: []PolicyStatement{
+				{
+					SID: "S3OperatorCrossAccount1Bucket", Effect: "Allow",
+					Principal: &Principal{Values: map[string]StringOrSlice{"AWS": {"arn:aws-cn:iam::123456789012:root"}}},
+					Action:    writeOnlyActions.bucketActions,
+					Resource:  []string{"arn:aws-cn:s3:::my-bucket"},
+				},
+				{
+					SID: "S3OperatorCrossAccount1Objects", Effect: "Allow",
+					Principal: &Principal{Values: map[string]StringOrSlice{"AWS": {"arn:aws-cn:iam::123456789012:root"}}},
+					Action:    writeOnlyActions.objectActions,
+					Resource:  []string{"arn:aws-cn:s3:::my-bucket/*"},
+				},
+			},
+		},
+		{
+			name:   "role limited to a prefix",
+			access: CrossAccountAccess{Principal: "arn:aws:iam::123456789012:role/reader", AccessLevel: ACCESS_LEVEL_READ_WRITE, Prefix: "shared/"},
+			region: "us-east-1",
+			want: []PolicyStatement{
+				{
+					SID: "S3OperatorCrossAccount1ListPrefix", Effect: "Allow", Principal: role,
+					Action:    []string{"s3:ListBucket", "s3:ListBucketVersions"},
+					Resource:  []string{"arn:aws:s3:::my-bucket"},
+					Condition: PolicyCondition{"StringLike": {"s3:prefix": {"shared/", "shared/*"}}},
+				},
+				{
+					SID: "S3OperatorCrossAccount1BucketLocation", Effect: "Allow", Principal: role,
+					Action:   []string{"s3:GetBucketLocation"},
+					Resource: []string{"arn:aws:s3:::my-bucket"},
+				},
+				{
+					SID: "S3OperatorCrossAccount1PrefixObjects", Effect: "Allow", Principal: role,
+					Action:   readWriteActions.objectActions,
+					Resource: []string{"arn:aws:s3:::my-bucket/shared/*"},
+				},
+			},
+		},
+		{
+			// nothing to list, no listing statement
+			name:   "write only limited to a prefix",
+			access: CrossAccountAccess{Principal: "123456789012", AccessLevel: ACCESS_LEVEL_WRITE_ONLY, Prefix: "drop/"},
+			region: "us-east-1",
+			want: []PolicyStatement{
+				{
+					SID: "S3OperatorCrossAccount1BucketLocation", Effect: "Allow", Principal: account,
+					Action:   []string{"s3:GetBucketLocation"},
+					Resource: []string{"arn:aws:s3:::my-bucket"},
+				},
+				{
+					SID: "S3OperatorCrossAccount1PrefixObjects", Effect: "Allow", Principal: account,
+					Action:   writeOnlyActions.objectActions,
+					Resource: []string{"arn:aws:s3:::my-bucket/drop/*"},
+				},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.access.bucketPolicyStatements(1, tt.region, "my-bucket")
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("bucketPolicyStatements() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,83 @@
+package v1beta1
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/agill17/s3-operator/pkg/utils"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/endpoints"
+	"github.com/aws/aws-sdk-go/service/iam"
+)
+
+// GetUsername returns the IAM user this CR manages: the name recorded in status once the user exists,
+// otherwise spec.username, otherwise <namespace>.<name>.iamuser
+func (u IAMUser) GetUsername() string {
+	if u.Status.Username != "" {
+		return u.Status.Username
+	}
+	if u.Spec.Username != "" {
+		return u.Spec.Username
+	}
+	return utils.DefaultIAMUsername(u.GetNamespace(), u.GetName(), utils.IAM_USERNAME_SUFFIX_IAMUSER)
+}
+
+// IAMUserOwner tags the user of the CR
+func (u IAMUser) IAMUserOwner() utils.IAMUserOwner {
+	return utils.IAMUserOwner{Kind: "IAMUser", Namespace: u.GetNamespace(), Name: u.GetName(), UID: string(u.GetUID())}
+}
+
+func (u IAMUser) GetRegion() string {
+	if u.Spec.Region != "" {
+		return u.Spec.Region
+	}
+	return endpoints.UsEast1RegionID
+}
+
+func (u IAMUser) GetPath() string {
+	if u.Spec.Path != "" {
+		return u.Spec.Path
+	}
+	return "/"
+}
+
+func (u IAMUser) GetSecretName() string {
+	if u.Spec.SecretName != "" {
+		return u.Spec.SecretName
+	}
+	return fmt.Sprintf("%v-iamuser-secret", u.GetName())
+}
+
+// GetTags returns the spec tags sorted by key, so inputs built from them are stable
+func (u IAMUser) GetTags() []*iam.Tag {
+	keys := make([]string, 0, len(u.Spec.Tags))
+	for k := range u.Spec.Tags {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	tags := make([]*iam.Tag, 0, len(keys))
+	for _, k := range keys {
+		tags = append(tags, &iam.Tag{Key: aws.String(k), Value: aws.String(u.Spec.Tags[k])})
+	}
+	return tags
+}
+
+func (u IAMUser) CreateIAMUserIn() *iam.CreateUserInput {
+	in := &iam.CreateUserInput{
+		UserName: aws.String(u.GetUsername()),
+		Path:     aws.String(u.GetPath()),
+	}
+	if tags := u.GetTags(); len(tags) > 0 {
+		in.Tags = tags
+	}
+	return in
+}
+
+// ChangedImmutableFields lists the spec fields that no longer match the user recorded in status
+func (u IAMUser) ChangedImmutableFields() []string {
+	var changed []string
+	if u.Status.Username != "" && u.Spec.Username != "" && u.Spec.Username != u.Status.Username {
+		changed = append(changed, "username")
+	}
+	return changed
+}
@@ -0,0 +1,88 @@
+package v1beta1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// IAMUserSpec defines the desired state of IAMUser
+type IAMUserSpec struct {
+	// IAM username, defaults to <namespace>.<name>.iamuser. Cannot be changed once the user exists.
+	// +optional
+	// +kubebuilder:validation:Pattern=`^[\w+=,.@-]{1,64}$`
+	Username string `json:"username,omitempty"`
+
+	// Region used to reach IAM, ARNs use the partition of this region
+	// +optional
+	// +kubebuilder:default:=us-east-1
+	Region string `json:"region,omitempty"`
+
+	// IAM path of the user, defaults to /
+	// +optional
+	// +kubebuilder:validation:Pattern=`^/(.*/)?$`
+	Path string `json:"path,omitempty"`
+
+	// +optional
+	Tags map[string]string `json:"tags,omitempty"`
+
+	// ARNs of managed policies attached to the user
+	// +optional
+	ManagedPolicyARNs []string `json:"managedPolicyARNs,omitempty"`
+
+	// Inline policies put on the user, keyed by policy name, each value is a policy document
+	// +optional
+	InlinePolicies map[string]string `json:"inlinePolicies,omitempty"`
+
+	// Name of the k8s secret holding the access keys, defaults to <name>-iamuser-secret
+	// +optional
+	SecretName string `json:"secretName,omitempty"`
+}
+
+// IAMUserStatus defines the observed state of IAMUser
+type IAMUserStatus struct {
+	// +optional
+	Phase Phase `json:"phase,omitempty"`
+
+	// Name of the IAM user, set once the user is created or adopted
+	// +optional
+	Username string `json:"username,omitempty"`
+
+	// +optional
+	ARN string `json:"arn,omitempty"`
+
+	// Managed policies attached and inline policies put by this CR, anything else on the user is left alone
+	// +optional
+	ManagedPolicyARNs []string `json:"managedPolicyARNs,omitempty"`
+	// +optional
+	InlinePolicies []string `json:"inlinePolicies,omitempty"`
+
+	// +optional
+	Conditions []Condition `json:"conditions,omitempty"`
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// IAMUser is the Schema for the iamusers API, an IAM user with access keys that buckets can reference
+// +kubebuilder:subresource:status
+// +kubebuilder:resource:path=iamusers,scope=Namespaced
+// +kubebuilder:printcolumn:name="Username",type=string,JSONPath=`.status.username`
+// +kubebuilder:printcolumn:name="Phase",type=string,JSONPath=`.status.phase`
+// +kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
+type IAMUser struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+	Spec              IAMUserSpec   `json:"spec,omitempty"`
+	Status            IAMUserStatus `json:"status,omitempty"`
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// IAMUserList contains a list of IAMUser
+type IAMUserList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []IAMUser `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&IAMUser{}, &IAMUserList{})
+}
@@ -0,0 +1,83 @@
+package v1beta1
+
+import (
+	"encoding/json"
+	"strings"
+
+	"github.com/agill17/s3-operator/pkg/utils"
+)
+
+const (
+	LOCKDOWN_SID = GENERATED_SID_PREFIX + "Lockdown"
+
+	// the bucket and its credentials are locked down, nothing else is reconciled until the annotation is removed
+	PHASE_LOCKED_DOWN Phase = "LockedDown"
+)
+
+// LockdownReason returns the s3.agill.apps/lockdown annotation, empty when the bucket is not locked down
+func (s S3) LockdownReason() string {
+	return strings.TrimSpace(s.GetAnnotations()[utils.LOCKDOWN_ANNOTATION])
+}
+
+// LockdownUsernames are the users whose access keys a lockdown deactivates: the bucket user and the consumers.
+// Users of referenced IAMUser CRs may be used for other buckets, the lockdown statement keeps them out of this one.
+func (s S3) LockdownUsernames() []string {
+	var usernames []string
+	if s.GetUsername() != "" && !s.UsesIAMUserRef() {
+		usernames = append(usernames, s.GetUsername())
+	}
+	for _, c := range s.Status.Consumers {
+		usernames = append(usernames, c.Username)
+	}
+	return usernames
+}
+
+// LockdownBucketPolicy adds a statement denying every principal but the operator ( operatorUserID, see OperatorExemption )
+// and adminARNs all actions on the bucket to policy, the bucket policy in place before the lockdown.
+// Nothing but the statement is changed, so removing it restores policy.
+func (s S3) LockdownBucketPolicy(policy, operatorUserID string, adminARNs []string) (string, error) {
+	doc := &PolicyDocument{Version: "2012-10-17"}
+	if policy != "" {
+		var err error
+		if doc, err = ParsePolicyDocument(policy); err != nil {
+			return "", err
+		}
+	}
+
+	statements := make([]PolicyStatement, 0, len(doc.Statements)+1)
+	for _, statement := range doc.Statements {
+		if statement.SID != LOCKDOWN_SID {
+			statements = append(statements, statement)
+		}
+	}
+	doc.Statements = append(statements, PolicyStatement{
+		SID:       LOCKDOWN_SID,
+		Effect:    "Deny",
+		Principal: &Principal{Wildcard: true},
+		Action:    StringOrSlice{"s3:*"},
+		Resource:  StringOrSlice{utils.S3BucketARN(s.GetRegion(), s.GetBucketName()), utils.S3ObjectsARN(s.GetRegion(), s.GetBucketName())},
+		Condition: lockdownCondition(operatorUserID, adminARNs),
+	})
+
+	locked, err := json.Marshal(doc)
+	if err != nil {
+		return "", err
+	}
+	return string(locked), nil
+}
+
+// OperatorExemption is the condition a Deny statement for Principal "*" needs to leave the operator alone, keys of a
+// condition block are ANDed. Matched by aws:userid, the unique ID of the operator role ( AROAEXAMPLE:* ) or user:
+// role ARNs as matched by aws:PrincipalArn contain the role path, which session ARNs do not tell.
+func OperatorExemption(operatorUserID string) map[string]StringOrSlice {
+	return map[string]StringOrSlice{"aws:userid": {operatorUserID}}
+}
+
+// lockdownCondition exempts the operator and the admins, every other principal is denied
+func lockdownCondition(operatorUserID string, adminARNs []string) PolicyCondition {
+	condition := PolicyCondition{"StringNotLike": OperatorExemption(operatorUserID)}
+	if len(adminARNs) > 0 {
+		condition["ArnNotLike"] = map[string]StringOrSlice{"aws:PrincipalArn": adminARNs}
+	}
+	return condition
+}
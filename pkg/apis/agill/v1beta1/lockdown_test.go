@@ -0,0 +1,123 @@
+package v1beta1
+
+import (
+	"reflect"
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+)
+
+func TestLockdownBucketPolicy(t *testing.T) {
+	const userPolicy = `{"Version":"2012-10-17","Statement":[{"Sid":"PublicRead","Effect":"Allow","Principal":"*","Action":"s3:GetObject","Resource":"arn:aws:s3:::my-bucket/*"}]}`
+	lockdown := func(region string, condition PolicyCondition) PolicyStatement {
+		partition := map[string]string{"us-east-1": "aws", "us-gov-west-1": "aws-us-gov"}[region]
+		return PolicyStatement{
+			SID:       LOCKDOWN_SID,
+			Effect:    "Deny",
+			Principal: &Principal{Wildcard: true},
+			Action:    StringOrSlice{"s3:*"},
+			Resource:  StringOrSlice{"arn:" + partition + ":s3:::my-bucket", "arn:" + partition + ":s3:::my-bucket/*"},
+			Condition: condition,
+		}
+	}
+	operatorOnly := PolicyCondition{"StringNotLike": {"aws:userid": {"AROAEXAMPLE:*"}}}
+	publicRead := PolicyStatement{
+		SID: "PublicRead", Effect: "Allow", Principal: &Principal{Wildcard: true},
+		Action: StringOrSlice{"s3:GetObject"}, Resource: StringOrSlice{"arn:aws:s3:::my-bucket/*"},
+	}
+
+	tests := []struct {
+		name      string
+		mutate    func(s *S3)
+		policy    string
+		adminARNs []string
+		want      []PolicyStatement
+		wantErr   bool
+	}{
+		{name: "no bucket policy", mutate: func(s *S3) {}, want: []PolicyStatement{lockdown("us-east-1", operatorOnly)}},
+		{
+			name:   "added to the bucket policy",
+			mutate: func(s *S3) {},
+			policy: userPolicy,
+			want:   []PolicyStatement{publicRead, lockdown("us-east-1", operatorOnly)},
+		},
+		{
+			name:      "admins exempted",
+			mutate:    func(s *S3) {},
+			adminARNs: []string{"arn:aws:iam::123456789012:role/incident-response"},
+			want: []PolicyStatement{lockdown("us-east-1", PolicyCondition{
+				"StringNotLike": {"aws:userid": {"AROAEXAMPLE:*"}},
+				"ArnNotLike":    {"aws:PrincipalArn": {"arn:aws:iam::123456789012:role/incident-response"}},
+			})},
+		},
+		{
+			// the bucket lives where it was created, spec.region may say otherwise
+			name:   "region of the created bucket",
+			mutate: func(s *S3) { s.Status.Region, s.Status.BucketName = "us-gov-west-1", "my-bucket" },
+			want:   []PolicyStatement{lockdown("us-gov-west-1", operatorOnly)},
+		},
+		{name: "invalid bucket policy", mutate: func(s *S3) {}, policy: `{"Statement":`, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := validS3()
+			tt.mutate(&s)
+			got, err := s.LockdownBucketPolicy(tt.policy, "AROAEXAMPLE:*", tt.adminARNs)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("LockdownBucketPolicy() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			doc, err := ParsePolicyDocument(got)
+			if err != nil {
+				t.Fatalf("LockdownBucketPolicy() = %v, not a policy document: %v", got, err)
+			}
+			if !reflect.DeepEqual(doc.Statements, tt.want) {
+				t.Errorf("LockdownBucketPolicy() statements = %+v, want %+v", doc.Statements, tt.want)
+			}
+
+			// locking down again, e.g: after the reason changed, replaces the statement
+			again, err := s.LockdownBucketPolicy(got, "AROAEXAMPLE:*", tt.adminARNs)
+			if err != nil || again != got {
+				t.Errorf("LockdownBucketPolicy() is not idempotent, got %v, %v", again, err)
+			}
+		})
+	}
+}
+
+func TestLockdownUsernames(t *testing.T) {
+	consumers := []ConsumerStatus{{Name: "a", Username: "team-a.bucket.a.consumer"}}
+	tests := []struct {
+		name   string
+		mutate func(s *S3)
+		want   []string
+	}{
+		{name: "bucket user", mutate: func(s *S3) {}, want: []string{"team-a.bucket.s3"}},
+		{
+			// consumers removed from the spec still have their keys until they are deleted
+			name:   "consumers from status",
+			mutate: func(s *S3) { s.Status.Consumers = consumers },
+			want:   []string{"team-a.bucket.s3", "team-a.bucket.a.consumer"},
+		},
+		{
+			// the referenced user may have access to other buckets, the lockdown statement keeps it out of this one
+			name: "iamUserRef",
+			mutate: func(s *S3) {
+				s.Spec.IAMUser.Username = ""
+				s.Spec.IAMUserRef = &v1.LocalObjectReference{Name: "reader"}
+				s.Status.Consumers = consumers
+			},
+			want: []string{"team-a.bucket.a.consumer"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := validS3()
+			tt.mutate(&s)
+			if got := s.LockdownUsernames(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("LockdownUsernames() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,88 @@
+package v1beta1
+
+import (
+	"github.com/agill17/s3-operator/pkg/utils"
+)
+
+const (
+	// Sid prefix of the statements the operator adds to the bucket policy, reserved in spec.bucketPolicy
+	GENERATED_SID_PREFIX = "S3Operator"
+
+	NETWORK_DENY_SID = GENERATED_SID_PREFIX + "DenyOutsideNetwork"
+)
+
+// object and listing actions denied from other networks by the bucket policy, bucket configuration is left to IAM
+var networkRestrictedDataActions = []string{
+	"s3:AbortMultipartUpload",
+	"s3:DeleteObject*",
+	"s3:GetObject*",
+	"s3:ListBucket*",
+	"s3:ListMultipartUploadParts",
+	"s3:PutObject*",
+}
+
+// IsEmpty is true when no network is listed, nothing is restricted then
+func (n *NetworkRestrictions) IsEmpty() bool {
+	return n == nil || len(n.SourceIPs)+len(n.VPCEndpointIDs)+len(n.VPCIDs) == 0
+}
+
+// denyConditions matches requests from none of the networks. Keys in a condition block are ANDed,
+// so a deny with every negated key only applies when no network matches. Calls AWS services make on behalf
+// of the principal ( e.g: KMS, CloudFormation ) do not come from the network and are left alone.
+func (n NetworkRestrictions) denyConditions() map[string]map[string][]string {
+	conditions := map[string]map[string][]string{
+		"Bool": {"aws:ViaAWSService": {"false"}},
+	}
+	if len(n.SourceIPs) > 0 {
+		conditions["NotIpAddress"] = map[string][]string{"aws:SourceIp": n.SourceIPs}
+	}
+	notEquals := map[string][]string{}
+	if len(n.VPCEndpointIDs) > 0 {
+		notEquals["aws:SourceVpce"] = n.VPCEndpointIDs
+	}
+	if len(n.VPCIDs) > 0 {
+		notEquals["aws:SourceVpc"] = n.VPCIDs
+	}
+	if len(notEquals) > 0 {
+		conditions["StringNotEquals"] = notEquals
+	}
+	return conditions
+}
+
+// userPolicyDenyStatement denies the generated credentials every action on the bucket from other networks
+func (n *NetworkRestrictions) userPolicyDenyStatement(region, bucketName string) []userPolicyStatement {
+	if n.IsEmpty() {
+		return nil
+	}
+	return []userPolicyStatement{{
+		SID:       "DenyOutsideNetwork",
+		Effect:    "Deny",
+		Action:    []string{"s3:*"},
+		Resource:  []string{utils.S3BucketARN(region, bucketName), utils.S3ObjectsARN(region, bucketName)},
+		Condition: n.denyConditions(),
+	}}
+}
+
+// bucketPolicyDenyStatement denies every principal but the operator object access from other networks.
+// The operator empties the bucket on delete from wherever it runs, see OperatorExemption.
+func (n *NetworkRestrictions) bucketPolicyDenyStatement(region, bucketName, operatorUserID string) []PolicyStatement {
+	if n.IsEmpty() || !n.EnforceInBucketPolicy {
+		return nil
+	}
+	condition := PolicyCondition{}
+	for operator, keys := range n.denyConditions() {
+		condition[operator] = map[string]StringOrSlice{}
+		for key, values := range keys {
+			condition[operator][key] = values
+		}
+	}
+	condition["StringNotLike"] = OperatorExemption(operatorUserID)
+	return []PolicyStatement{{
+		SID:       NETWORK_DENY_SID,
+		Effect:    "Deny",
+		Principal: &Principal{Wildcard: true},
+		Action:    networkRestrictedDataActions,
+		Resource:  []string{utils.S3BucketARN(region, bucketName), utils.S3ObjectsARN(region, bucketName)},
+		Condition: condition,
+	}}
+}
@@ -0,0 +1,114 @@
+package v1beta1
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestNetworkRestrictionsDenyConditions(t *testing.T) {
+	viaService := map[string][]string{"aws:ViaAWSService": {"false"}}
+	tests := []struct {
+		name    string
+		network NetworkRestrictions
+		want    map[string]map[string][]string
+	}{
+		{
+			name:    "source IPs",
+			network: NetworkRestrictions{SourceIPs: []string{"203.0.113.0/24", "198.51.100.7/32"}},
+			want: map[string]map[string][]string{
+				"Bool":         viaService,
+				"NotIpAddress": {"aws:SourceIp": {"203.0.113.0/24", "198.51.100.7/32"}},
+			},
+		},
+		{
+			name:    "vpc endpoints",
+			network: NetworkRestrictions{VPCEndpointIDs: []string{"vpce-1a2b3c4d"}},
+			want: map[string]map[string][]string{
+				"Bool":            viaService,
+				"StringNotEquals": {"aws:SourceVpce": {"vpce-1a2b3c4d"}},
+			},
+		},
+		{
+			name:    "vpcs",
+			network: NetworkRestrictions{VPCIDs: []string{"vpc-1a2b3c4d"}},
+			want: map[string]map[string][]string{
+				"Bool":            viaService,
+				"StringNotEquals": {"aws:SourceVpc": {"vpc-1a2b3c4d"}},
+			},
+		},
+		{
+			// keys are ANDed, the deny only applies when no network matches
+			name: "all networks",
+			network: NetworkRestrictions{
+				SourceIPs:      []string{"203.0.113.0/24"},
+				VPCEndpointIDs: []string{"vpce-1a2b3c4d"},
+				VPCIDs:         []string{"vpc-1a2b3c4d"},
+			},
+			want: map[string]map[string][]string{
+				"Bool":         viaService,
+				"NotIpAddress": {"aws:SourceIp": {"203.0.113.0/24"}},
+				"StringNotEquals": {
+					"aws:SourceVpce": {"vpce-1a2b3c4d"},
+					"aws:SourceVpc":  {"vpc-1a2b3c4d"},
+				},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.network.denyConditions(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("denyConditions() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNetworkRestrictionsDenyStatements(t *testing.T) {
+	tests := []struct {
+		name       string
+		network    *NetworkRestrictions
+		wantUser   bool
+		wantBucket bool
+	}{
+		{name: "not restricted", network: nil},
+		{name: "no networks", network: &NetworkRestrictions{EnforceInBucketPolicy: true}},
+		{name: "user policy only", network: &NetworkRestrictions{VPCIDs: []string{"vpc-1a2b3c4d"}}, wantUser: true},
+		{
+			name:       "enforced in the bucket policy",
+			network:    &NetworkRestrictions{VPCIDs: []string{"vpc-1a2b3c4d"}, EnforceInBucketPolicy: true},
+			wantUser:   true,
+			wantBucket: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			user := tt.network.userPolicyDenyStatement("us-gov-west-1", "my-bucket")
+			if got := len(user) == 1; got != tt.wantUser {
+				t.Fatalf("userPolicyDenyStatement() = %v, want a statement: %v", user, tt.wantUser)
+			}
+			if tt.wantUser && !reflect.DeepEqual(user[0].Resource, []string{"arn:aws-us-gov:s3:::my-bucket", "arn:aws-us-gov:s3:::my-bucket/*"}) {
+				t.Errorf("userPolicyDenyStatement() resources = %v", user[0].Resource)
+			}
+
+			bucket := tt.network.bucketPolicyDenyStatement("us-gov-west-1", "my-bucket", "AROAEXAMPLE:*")
+			if got := len(bucket) == 1; got != tt.wantBucket {
+				t.Fatalf("bucketPolicyDenyStatement() = %v, want a statement: %v", bucket, tt.wantBucket)
+			}
+			if !tt.wantBucket {
+				return
+			}
+			statement := bucket[0]
+			if statement.SID != NETWORK_DENY_SID || statement.Effect != "Deny" || !statement.Principal.Wildcard {
+				t.Errorf("bucketPolicyDenyStatement() = %+v, want a Deny for every principal", statement)
+			}
+			wantCondition := PolicyCondition{
+				"Bool":            {"aws:ViaAWSService": {"false"}},
+				"StringNotEquals": {"aws:SourceVpc": {"vpc-1a2b3c4d"}},
+				"StringNotLike":   {"aws:userid": {"AROAEXAMPLE:*"}},
+			}
+			if !reflect.DeepEqual(statement.Condition, wantCondition) {
+				t.Errorf("bucketPolicyDenyStatement() condition = %v, want %v", statement.Condition, wantCondition)
+			}
+		})
+	}
+}
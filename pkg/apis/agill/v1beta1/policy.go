@@ -0,0 +1,156 @@
+package v1beta1
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+)
+
+// PolicyDocument is an IAM / bucket policy, it accepts every shape AWS accepts
+// ( single statement object, string or list for Action/Resource, "*" or a map for Principal )
+// +k8s:deepcopy-gen=false
+type PolicyDocument struct {
+	Version    string            `json:"Version,omitempty"`
+	ID         string            `json:"Id,omitempty"`
+	Statements []PolicyStatement `json:"Statement"`
+}
+
+// +k8s:deepcopy-gen=false
+type PolicyStatement struct {
+	SID          string          `json:"Sid,omitempty"`
+	Effect       string          `json:"Effect"`
+	Principal    *Principal      `json:"Principal,omitempty"`
+	NotPrincipal *Principal      `json:"NotPrincipal,omitempty"`
+	Action       StringOrSlice   `json:"Action,omitempty"`
+	NotAction    StringOrSlice   `json:"NotAction,omitempty"`
+	Resource     StringOrSlice   `json:"Resource,omitempty"`
+	NotResource  StringOrSlice   `json:"NotResource,omitempty"`
+	Condition    PolicyCondition `json:"Condition,omitempty"`
+}
+
+// PolicyCondition is operator -> condition key -> values, e.g: {"StringLike": {"s3:prefix": ["team-a/*"]}}
+// +k8s:deepcopy-gen=false
+type PolicyCondition map[string]map[string]StringOrSlice
+
+// StringOrSlice unmarshals from a json string or a list of strings. Condition values may also be json booleans or numbers,
+// e.g: {"Bool": {"aws:SecureTransport": false}}, those are kept as their string form which AWS accepts as well.
+// +k8s:deepcopy-gen=false
+type StringOrSlice []string
+
+func (s *StringOrSlice) UnmarshalJSON(data []byte) error {
+	var list []json.RawMessage
+	if err := json.Unmarshal(data, &list); err != nil {
+		single, errSingle := scalarString(data)
+		if errSingle != nil {
+			return errSingle
+		}
+		*s = StringOrSlice{single}
+		return nil
+	}
+	values := make(StringOrSlice, 0, len(list))
+	for _, raw := range list {
+		value, err := scalarString(raw)
+		if err != nil {
+			return err
+		}
+		values = append(values, value)
+	}
+	*s = values
+	return nil
+}
+
+// scalarString reads a json string, boolean or number as a string
+func scalarString(data []byte) (string, error) {
+	var value interface{}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	if err := decoder.Decode(&value); err != nil {
+		return "", fmt.Errorf("expected a string or a list of strings: %v", err)
+	}
+	switch v := value.(type) {
+	case string:
+		return v, nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	case json.Number:
+		return v.String(), nil
+	}
+	return "", fmt.Errorf("expected a string or a list of strings, got %s", data)
+}
+
+// Principal is either the "*" wildcard or a map like {"AWS": ["arn:..."], "Service": "..."}
+// +k8s:deepcopy-gen=false
+type Principal struct {
+	Wildcard bool
+	Values   map[string]StringOrSlice
+}
+
+func (p *Principal) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		if single != "*" {
+			return fmt.Errorf(`principal string must be "*", got %q`, single)
+		}
+		p.Wildcard = true
+		return nil
+	}
+	return json.Unmarshal(data, &p.Values)
+}
+
+func (p Principal) MarshalJSON() ([]byte, error) {
+	if p.Wildcard {
+		return json.Marshal("*")
+	}
+	return json.Marshal(p.Values)
+}
+
+// IsPublic is true for "*" and {"AWS": "*"}, which both grant access to everyone
+func (p *Principal) IsPublic() bool {
+	if p == nil {
+		return false
+	}
+	if p.Wildcard {
+		return true
+	}
+	for _, v := range p.Values["AWS"] {
+		if v == "*" {
+			return true
+		}
+	}
+	return false
+}
+
+// ParsePolicyDocument parses a policy json, a single statement object is accepted as well
+func ParsePolicyDocument(policy string) (*PolicyDocument, error) {
+	raw := struct {
+		Version   string          `json:"Version"`
+		ID        string          `json:"Id"`
+		Statement json.RawMessage `json:"Statement"`
+	}{}
+	if err := json.Unmarshal([]byte(policy), &raw); err != nil {
+		return nil, err
+	}
+	doc := &PolicyDocument{Version: raw.Version, ID: raw.ID}
+	if len(raw.Statement) == 0 {
+		return nil, fmt.Errorf("policy has no Statement")
+	}
+	if err := json.Unmarshal(raw.Statement, &doc.Statements); err != nil {
+		var single PolicyStatement
+		if errSingle := json.Unmarshal(raw.Statement, &single); errSingle != nil {
+			return nil, err
+		}
+		doc.Statements = []PolicyStatement{single}
+	}
+	return doc, nil
+}
+
+// HasPublicPrincipal is true if any Allow statement grants access to everyone
+func (d PolicyDocument) HasPublicPrincipal() bool {
+	for _, s := range d.Statements {
+		if s.Effect == "Allow" && s.Principal.IsPublic() {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,167 @@
+package v1beta1
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/agill17/s3-operator/pkg/utils"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+const (
+	// creating the bucket or IAM users of the CR would exceed a quota of its namespace
+	PHASE_QUOTA_EXCEEDED Phase = "QuotaExceeded"
+)
+
+// NamespaceQuota caps what the CRs of a namespace may create, read from the namespace annotations:
+//   - s3.agill.apps/quota-buckets: number of S3 CRs
+//   - s3.agill.apps/quota-iam-users: IAM users of S3 CRs ( spec.iamUser and consumers ), IAMUser CRs and BucketAccessRequests
+//   - s3.agill.apps/quota-bytes: bytes stored in the buckets of the namespace, e.g: 500Gi
+//
+// Omitted annotations are not limited.
+// +k8s:deepcopy-gen=false
+type NamespaceQuota struct {
+	Buckets  *int64
+	IAMUsers *int64
+	Bytes    *resource.Quantity
+}
+
+// QuotaUsage is what CRs count against a NamespaceQuota
+// +k8s:deepcopy-gen=false
+type QuotaUsage struct {
+	Buckets  int64
+	IAMUsers int64
+	Bytes    int64
+}
+
+// ParseNamespaceQuota reads the quota annotations of a namespace
+func ParseNamespaceQuota(annotations map[string]string) (NamespaceQuota, error) {
+	quota := NamespaceQuota{}
+	for annotation, value := range map[string]**int64{
+		"buckets":   &quota.Buckets,
+		"iam-users": &quota.IAMUsers,
+	} {
+		v, found := annotations[utils.NAMESPACE_QUOTA_ANNOTATION_PREFIX+annotation]
+		if !found {
+			continue
+		}
+		parsed, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || parsed < 0 {
+			return quota, fmt.Errorf("namespace annotation %v%v must be a non negative number, got %q",
+				utils.NAMESPACE_QUOTA_ANNOTATION_PREFIX, annotation, v)
+		}
+		*value = &parsed
+	}
+
+	if v, found := annotations[utils.NAMESPACE_QUOTA_ANNOTATION_PREFIX+"bytes"]; found {
+		parsed, err := resource.ParseQuantity(v)
+		if err != nil || parsed.Sign() < 0 {
+			return quota, fmt.Errorf("namespace annotation %vbytes must be a non negative quantity, e.g: 500Gi, got %q",
+				utils.NAMESPACE_QUOTA_ANNOTATION_PREFIX, v)
+		}
+		quota.Bytes = &parsed
+	}
+	return quota, nil
+}
+
+// IsEmpty is true when the namespace has no quota
+func (q NamespaceQuota) IsEmpty() bool {
+	return q.Buckets == nil && q.IAMUsers == nil && q.Bytes == nil
+}
+
+// Exceeded lists the quotas that adding requested to used would exceed. Stored bytes cannot be capped up front,
+// so new buckets are refused once the namespace reached its bytes quota.
+func (q NamespaceQuota) Exceeded(used, requested QuotaUsage) []string {
+	var exceeded []string
+	if q.Buckets != nil && requested.Buckets > 0 && used.Buckets+requested.Buckets > *q.Buckets {
+		exceeded = append(exceeded, fmt.Sprintf("buckets: used %v, requested %v, limited to %v",
+			used.Buckets, requested.Buckets, *q.Buckets))
+	}
+	if q.IAMUsers != nil && requested.IAMUsers > 0 && used.IAMUsers+requested.IAMUsers > *q.IAMUsers {
+		exceeded = append(exceeded, fmt.Sprintf("iam-users: used %v, requested %v, limited to %v",
+			used.IAMUsers, requested.IAMUsers, *q.IAMUsers))
+	}
+	if q.Bytes != nil && requested.Buckets > 0 && used.Bytes >= q.Bytes.Value() {
+		exceeded = append(exceeded, fmt.Sprintf("bytes: used %v, limited to %v",
+			resource.NewQuantity(used.Bytes, resource.BinarySI), q.Bytes))
+	}
+	return exceeded
+}
+
+// Add sums two usages
+func (u QuotaUsage) Add(other QuotaUsage) QuotaUsage {
+	return QuotaUsage{
+		Buckets:  u.Buckets + other.Buckets,
+		IAMUsers: u.IAMUsers + other.IAMUsers,
+		Bytes:    u.Bytes + other.Bytes,
+	}
+}
+
+// Sub returns what u needs on top of other, never below zero
+func (u QuotaUsage) Sub(other QuotaUsage) QuotaUsage {
+	sub := func(a, b int64) int64 {
+		if a < b {
+			return 0
+		}
+		return a - b
+	}
+	return QuotaUsage{
+		Buckets:  sub(u.Buckets, other.Buckets),
+		IAMUsers: sub(u.IAMUsers, other.IAMUsers),
+		Bytes:    sub(u.Bytes, other.Bytes),
+	}
+}
+
+// IsZero is true when nothing is used or requested
+func (u QuotaUsage) IsZero() bool {
+	return u == QuotaUsage{}
+}
+
+// QuotaUsage is what the CR counts against its namespace quota once reconciled: its bucket, spec.iamUser
+// ( users of referenced IAMUser CRs count for that CR ), one user per consumer and the last known bucket size
+func (s S3) QuotaUsage() QuotaUsage {
+	usage := QuotaUsage{Buckets: 1, IAMUsers: int64(len(s.Spec.Consumers)), Bytes: s.Status.SizeBytes}
+	if s.GetUsername() != "" && !s.UsesIAMUserRef() {
+		usage.IAMUsers++
+	}
+	return usage
+}
+
+// ProvisionedQuotaUsage is the part of QuotaUsage that already exists in AWS, only the rest is checked against the quota.
+// The bucket user is created right after the bucket, so it counts as provisioned along with it.
+func (s S3) ProvisionedQuotaUsage() QuotaUsage {
+	usage := QuotaUsage{IAMUsers: int64(len(s.Status.Consumers)), Bytes: s.Status.SizeBytes}
+	if s.Status.BucketName != "" {
+		usage.Buckets = 1
+		if s.GetUsername() != "" && !s.UsesIAMUserRef() {
+			usage.IAMUsers++
+		}
+	}
+	return usage
+}
+
+// QuotaUsage is the IAM user of the CR
+func (i IAMUser) QuotaUsage() QuotaUsage {
+	return QuotaUsage{IAMUsers: 1}
+}
+
+// ProvisionedQuotaUsage is the IAM user once it was created
+func (i IAMUser) ProvisionedQuotaUsage() QuotaUsage {
+	if i.Status.Username == "" {
+		return QuotaUsage{}
+	}
+	return i.QuotaUsage()
+}
+
+// QuotaUsage is the IAM user issued for the request
+func (b BucketAccessRequest) QuotaUsage() QuotaUsage {
+	return QuotaUsage{IAMUsers: 1}
+}
+
+// ProvisionedQuotaUsage is the IAM user once it was created
+func (b BucketAccessRequest) ProvisionedQuotaUsage() QuotaUsage {
+	if b.Status.Username == "" {
+		return QuotaUsage{}
+	}
+	return b.QuotaUsage()
+}
@@ -0,0 +1,204 @@
+package v1beta1
+
+import (
+	"reflect"
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+func int64Ptr(i int64) *int64 {
+	return &i
+}
+
+func resourcePtr(s string) *resource.Quantity {
+	q := resource.MustParse(s)
+	return &q
+}
+
+func TestParseNamespaceQuota(t *testing.T) {
+	tests := []struct {
+		name        string
+		annotations map[string]string
+		want        NamespaceQuota
+		wantErr     bool
+	}{
+		{name: "no quota", annotations: map[string]string{"other": "1"}, want: NamespaceQuota{}},
+		{
+			name: "every quota",
+			annotations: map[string]string{
+				"s3.agill.apps/quota-buckets":   "3",
+				"s3.agill.apps/quota-iam-users": "0",
+				"s3.agill.apps/quota-bytes":     "500Gi",
+			},
+			want: NamespaceQuota{Buckets: int64Ptr(3), IAMUsers: int64Ptr(0), Bytes: resourcePtr("500Gi")},
+		},
+		{name: "not a number", annotations: map[string]string{"s3.agill.apps/quota-buckets": "three"}, wantErr: true},
+		{name: "negative", annotations: map[string]string{"s3.agill.apps/quota-iam-users": "-1"}, wantErr: true},
+		{name: "invalid quantity", annotations: map[string]string{"s3.agill.apps/quota-bytes": "lots"}, wantErr: true},
+		{name: "negative quantity", annotations: map[string]string{"s3.agill.apps/quota-bytes": "-1Gi"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseNamespaceQuota(tt.annotations)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseNamespaceQuota() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if !reflect.DeepEqual(got.Buckets, tt.want.Buckets) || !reflect.DeepEqual(got.IAMUsers, tt.want.IAMUsers) {
+				t.Errorf("ParseNamespaceQuota() = %+v, want %+v", got, tt.want)
+			}
+			if (got.Bytes == nil) != (tt.want.Bytes == nil) || (got.Bytes != nil && got.Bytes.Cmp(*tt.want.Bytes) != 0) {
+				t.Errorf("ParseNamespaceQuota() bytes = %v, want %v", got.Bytes, tt.want.Bytes)
+			}
+			if got.IsEmpty() != (tt.want == NamespaceQuota{}) {
+				t.Errorf("ParseNamespaceQuota() IsEmpty() = %v", got.IsEmpty())
+			}
+		})
+	}
+}
+
+func TestNamespaceQuotaExceeded(t *testing.T) {
+	quota := NamespaceQuota{Buckets: int64Ptr(2), IAMUsers: int64Ptr(3), Bytes: resourcePtr("1Ki")}
+	tests := []struct {
+		name      string
+		quota     NamespaceQuota
+		used      QuotaUsage
+		requested QuotaUsage
+		want      []string
+	}{
+		{name: "no quota", quota: NamespaceQuota{}, used: QuotaUsage{Buckets: 100, IAMUsers: 100}, requested: QuotaUsage{Buckets: 1, IAMUsers: 1}},
+		{name: "fits exactly", quota: quota, used: QuotaUsage{Buckets: 1, IAMUsers: 1}, requested: QuotaUsage{Buckets: 1, IAMUsers: 2}},
+		{
+			name:      "buckets",
+			quota:     quota,
+			used:      QuotaUsage{Buckets: 2},
+			requested: QuotaUsage{Buckets: 1},
+			want:      []string{"buckets: used 2, requested 1, limited to 2"},
+		},
+		{
+			name:      "iam users",
+			quota:     quota,
+			used:      QuotaUsage{IAMUsers: 2},
+			requested: QuotaUsage{IAMUsers: 2},
+			want:      []string{"iam-users: used 2, requested 2, limited to 3"},
+		},
+		{
+			// a lowered quota does not block CRs that request nothing new
+			name:      "already over quota, nothing requested",
+			quota:     quota,
+			used:      QuotaUsage{Buckets: 5, IAMUsers: 5, Bytes: 4096},
+			requested: QuotaUsage{},
+		},
+		{
+			name:      "bytes reached blocks new buckets",
+			quota:     quota,
+			used:      QuotaUsage{Buckets: 1, Bytes: 1024},
+			requested: QuotaUsage{Buckets: 1},
+			want:      []string{"bytes: used 1Ki, limited to 1Ki"},
+		},
+		{
+			name:      "bytes reached does not block new users",
+			quota:     quota,
+			used:      QuotaUsage{Buckets: 1, Bytes: 4096},
+			requested: QuotaUsage{IAMUsers: 1},
+		},
+		{
+			name:      "everything",
+			quota:     quota,
+			used:      QuotaUsage{Buckets: 2, IAMUsers: 3, Bytes: 2048},
+			requested: QuotaUsage{Buckets: 1, IAMUsers: 1},
+			want: []string{
+				"buckets: used 2, requested 1, limited to 2",
+				"iam-users: used 3, requested 1, limited to 3",
+				"bytes: used 2Ki, limited to 1Ki",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.quota.Exceeded(tt.used, tt.requested); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Exceeded() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestQuotaUsageSub(t *testing.T) {
+	tests := []struct {
+		name  string
+		u     QuotaUsage
+		other QuotaUsage
+		want  QuotaUsage
+	}{
+		{name: "nothing provisioned", u: QuotaUsage{Buckets: 1, IAMUsers: 2}, want: QuotaUsage{Buckets: 1, IAMUsers: 2}},
+		{name: "partly provisioned", u: QuotaUsage{Buckets: 1, IAMUsers: 3}, other: QuotaUsage{Buckets: 1, IAMUsers: 1}, want: QuotaUsage{IAMUsers: 2}},
+		{
+			// e.g: a consumer was removed from the spec and its user is not deleted yet
+			name:  "more provisioned than requested",
+			u:     QuotaUsage{Buckets: 1, IAMUsers: 1},
+			other: QuotaUsage{Buckets: 1, IAMUsers: 3},
+			want:  QuotaUsage{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.u.Sub(tt.other); got != tt.want {
+				t.Errorf("Sub() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestS3QuotaUsage(t *testing.T) {
+	tests := []struct {
+		name            string
+		mutate          func(s *S3)
+		wantUsage       QuotaUsage
+		wantProvisioned QuotaUsage
+	}{
+		{name: "not created", mutate: func(s *S3) {}, wantUsage: QuotaUsage{Buckets: 1, IAMUsers: 1}},
+		{
+			name:            "created",
+			mutate:          func(s *S3) { s.Status.BucketName, s.Status.SizeBytes = "my-bucket", 1024 },
+			wantUsage:       QuotaUsage{Buckets: 1, IAMUsers: 1, Bytes: 1024},
+			wantProvisioned: QuotaUsage{Buckets: 1, IAMUsers: 1, Bytes: 1024},
+		},
+		{
+			// the referenced IAMUser CR counts its own user
+			name: "iamUserRef",
+			mutate: func(s *S3) {
+				s.Spec.IAMUser.Username = ""
+				s.Spec.IAMUserRef = &v1.LocalObjectReference{Name: "reader"}
+				s.Status.BucketName = "my-bucket"
+			},
+			wantUsage:       QuotaUsage{Buckets: 1},
+			wantProvisioned: QuotaUsage{Buckets: 1},
+		},
+		{
+			name: "consumers",
+			mutate: func(s *S3) {
+				s.Spec.Consumers = []BucketConsumer{{Name: "a", Prefix: "a/"}, {Name: "b", Prefix: "b/"}}
+				s.Status.BucketName = "my-bucket"
+				s.Status.Consumers = []ConsumerStatus{{Name: "a"}}
+			},
+			wantUsage:       QuotaUsage{Buckets: 1, IAMUsers: 3},
+			wantProvisioned: QuotaUsage{Buckets: 1, IAMUsers: 2},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := validS3()
+			tt.mutate(&s)
+			if got := s.QuotaUsage(); got != tt.wantUsage {
+				t.Errorf("QuotaUsage() = %+v, want %+v", got, tt.wantUsage)
+			}
+			if got := s.ProvisionedQuotaUsage(); got != tt.wantProvisioned {
+				t.Errorf("ProvisionedQuotaUsage() = %+v, want %+v", got, tt.wantProvisioned)
+			}
+		})
+	}
+}
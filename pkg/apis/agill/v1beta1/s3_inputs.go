@@ -0,0 +1,315 @@
+package v1beta1
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/agill17/s3-operator/pkg/utils"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/iam"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+type userPolicy struct {
+	Version    string                `json:"Version"`
+	ID         string                `json:"Id"`
+	Statements []userPolicyStatement `json:"Statement"`
+}
+
+type userPolicyStatement struct {
+	SID       string                         `json:"Sid"`
+	Effect    string                         `json:"Effect"`
+	Action    []string                       `json:"Action"`
+	Resource  []string                       `json:"Resource"`
+	Condition map[string]map[string][]string `json:"Condition,omitempty"`
+}
+
+// DesiredRestrictedPolicyDocForBucket builds the inline policy for the bucket, ARNs are scoped to the partition of the region
+func DesiredRestrictedPolicyDocForBucket(policyName, region, bucketName string, level AccessLevel, customActions []string,
+	network *NetworkRestrictions) (string, error) {
+	actions := actionsForAccessLevel(level, customActions)
+	userPolicy := userPolicy{
+		Version: "2012-10-17",
+		ID:      policyName,
+		Statements: []userPolicyStatement{
+			{
+				SID:      "1",
+				Effect:   "Allow",
+				Action:   actions.bucketActions,
+				Resource: []string{utils.S3BucketARN(region, bucketName)},
+			},
+			{
+				SID:      "2",
+				Effect:   "Allow",
+				Action:   actions.objectActions,
+				Resource: []string{utils.S3ObjectsARN(region, bucketName)},
+			},
+		},
+	}
+	userPolicy.Statements = append(userPolicy.Statements, network.userPolicyDenyStatement(region, bucketName)...)
+
+	policy, err := json.Marshal(userPolicy)
+	if err != nil {
+		return "", err
+	}
+
+	return string(policy), nil
+}
+
+// DesiredPrefixPolicyDocForBucket builds the inline policy of a consumer, listing is limited with s3:prefix conditions
+// and object actions to the ARNs under the prefix. Bucket wide actions other than listing and GetBucketLocation are never granted.
+func DesiredPrefixPolicyDocForBucket(policyName, region, bucketName, prefix string, level AccessLevel, customActions []string,
+	network *NetworkRestrictions) (string, error) {
+	actions := actionsForAccessLevel(level, customActions)
+	listActions, locationActions := prefixScopedBucketActions(actions.bucketActions)
+
+	userPolicy := userPolicy{
+		Version: "2012-10-17",
+		ID:      policyName,
+	}
+	if len(listActions) > 0 {
+		userPolicy.Statements = append(userPolicy.Statements, userPolicyStatement{
+			SID:      "ListPrefix",
+			Effect:   "Allow",
+			Action:   listActions,
+			Resource: []string{utils.S3BucketARN(region, bucketName)},
+			Condition: map[string]map[string][]string{
+				"StringLike": {"s3:prefix": {prefix, prefix + "*"}},
+			},
+		})
+	}
+	if len(locationActions) > 0 {
+		userPolicy.Statements = append(userPolicy.Statements, userPolicyStatement{
+			SID:      "BucketLocation",
+			Effect:   "Allow",
+			Action:   locationActions,
+			Resource: []string{utils.S3BucketARN(region, bucketName)},
+		})
+	}
+	userPolicy.Statements = append(userPolicy.Statements, userPolicyStatement{
+		SID:      "PrefixObjects",
+		Effect:   "Allow",
+		Action:   actions.objectActions,
+		Resource: []string{utils.S3PrefixObjectsARN(region, bucketName, prefix)},
+	})
+	userPolicy.Statements = append(userPolicy.Statements, network.userPolicyDenyStatement(region, bucketName)...)
+
+	policy, err := json.Marshal(userPolicy)
+	if err != nil {
+		return "", err
+	}
+
+	return string(policy), nil
+}
+
+func (s S3) CreateBucketIn() *s3.CreateBucketInput {
+	s3Input := &s3.CreateBucketInput{
+		Bucket: aws.String(s.GetBucketName()),
+	}
+	if s.GetRegion() != "us-east-1" {
+		s3Input.CreateBucketConfiguration = s.SetBucketLocation()
+	}
+
+	if s.Spec.ObjectLockEnabled {
+		s3Input.ObjectLockEnabledForBucket = aws.Bool(true)
+	}
+
+	return s3Input
+}
+
+func (s S3) PutBucketAclIn() *s3.PutBucketAclInput {
+	return &s3.PutBucketAclInput{
+		ACL:    aws.String(s.Spec.BucketACL),
+		Bucket: aws.String(s.GetBucketName()),
+	}
+}
+
+// PutBucketVersioningIn returns nil when versioning is Unmanaged
+func (s S3) PutBucketVersioningIn() *s3.PutBucketVersioningInput {
+	var status string
+	switch s.Spec.Versioning {
+	case FEATURE_ENABLED:
+		status = s3.BucketVersioningStatusEnabled
+	case FEATURE_SUSPENDED:
+		status = s3.BucketVersioningStatusSuspended
+	default:
+		return nil
+	}
+	return &s3.PutBucketVersioningInput{
+		Bucket: aws.String(s.GetBucketName()),
+		MFA:    nil,
+		VersioningConfiguration: &s3.VersioningConfiguration{
+			Status: aws.String(status),
+		},
+	}
+}
+
+// PutBucketAccelIn returns nil when transfer acceleration is Unmanaged
+func (s S3) PutBucketAccelIn() *s3.PutBucketAccelerateConfigurationInput {
+	var status string
+	switch s.Spec.TransferAcceleration {
+	case FEATURE_ENABLED:
+		status = s3.BucketAccelerateStatusEnabled
+	case FEATURE_SUSPENDED:
+		status = s3.BucketAccelerateStatusSuspended
+	default:
+		return nil
+	}
+	return &s3.PutBucketAccelerateConfigurationInput{
+		AccelerateConfiguration: &s3.AccelerateConfiguration{Status: aws.String(status)},
+		Bucket:                  aws.String(s.GetBucketName()),
+	}
+}
+
+func (s S3) DeleteBucketIn() *s3.DeleteBucketInput {
+	return &s3.DeleteBucketInput{Bucket: aws.String(s.GetBucketName())}
+}
+
+// PutBucketPolicyIn returns nil when there is no bucket policy to put, policy is the result of DesiredBucketPolicy
+func (s S3) PutBucketPolicyIn(policy string) *s3.PutBucketPolicyInput {
+	if policy == "" {
+		return nil
+	}
+	return &s3.PutBucketPolicyInput{
+		Bucket: aws.String(s.GetBucketName()),
+		Policy: aws.String(policy),
+	}
+}
+
+func (s S3) SetBucketLocation() *s3.CreateBucketConfiguration {
+	if s.GetRegion() != "" {
+		return &s3.CreateBucketConfiguration{LocationConstraint: aws.String(s.GetRegion())}
+	}
+	return nil
+}
+
+func (s S3) CreateIAMUserIn() *iam.CreateUserInput {
+	iamUserIn := &iam.CreateUserInput{
+		UserName: aws.String(s.Spec.IAMUser.Username),
+	}
+	return iamUserIn
+}
+
+// GetPolicyName returns the name of the bucket inline policy put on the given user
+func (s S3) GetPolicyName(username string) string {
+	return fmt.Sprintf("%v-%v-s3-restricted", username, s.GetBucketName())
+}
+
+// GetBucketName returns the bucket this CR manages: the name recorded in status once the bucket exists,
+// otherwise spec.bucketName, otherwise the generated name for the current attempt.
+func (s S3) GetBucketName() string {
+	if s.Status.BucketName != "" {
+		return s.Status.BucketName
+	}
+	if s.Spec.BucketName != "" {
+		return s.Spec.BucketName
+	}
+	prefix := s.Spec.BucketNamePrefix
+	if prefix == "" {
+		prefix = s.GetName()
+	}
+	return utils.GenerateBucketName(prefix, s.GetNamespace(), fmt.Sprintf("%v/%v", s.GetUID(), s.Status.BucketNameAttempt))
+}
+
+// GetRegion returns the region the bucket was created in, falling back to spec.region before it exists
+func (s S3) GetRegion() string {
+	if s.Status.Region != "" {
+		return s.Status.Region
+	}
+	return s.Spec.Region
+}
+
+// ChangedImmutableFields lists the spec fields that no longer match what was recorded when the bucket was created.
+// Admission rejects these changes already, this catches CRs updated while the webhook was not in place.
+func (s S3) ChangedImmutableFields() []string {
+	var changed []string
+	if s.Status.BucketName != "" && s.Spec.BucketName != "" && s.Spec.BucketName != s.Status.BucketName {
+		changed = append(changed, "bucketName")
+	}
+	// region and object lock are recorded together, CRs created before they were recorded have neither
+	if s.Status.Region != "" {
+		if s.Spec.Region != s.Status.Region {
+			changed = append(changed, "region")
+		}
+		if s.Spec.ObjectLockEnabled != s.Status.ObjectLockEnabled {
+			changed = append(changed, "objectLockEnabled")
+		}
+	}
+	return changed
+}
+
+// HasGeneratedBucketName is true when the operator picks the bucket name
+func (s S3) HasGeneratedBucketName() bool {
+	return s.Spec.BucketName == ""
+}
+
+func (s S3) GetUsername() string {
+	return s.Spec.IAMUser.Username
+}
+
+func (s S3) GetIAMK8SSecretName() string {
+	return fmt.Sprintf("%v-iam-secret", s.GetName())
+}
+
+// UsesIAMUserRef is true when access is granted to a user managed by an IAMUser CR
+func (s S3) UsesIAMUserRef() bool {
+	return s.Spec.IAMUserRef != nil && s.Spec.IAMUserRef.Name != ""
+}
+
+// GetRestrictedInlinePolicyInput builds the bucket inline policy for the given user,
+// spec.iamUser.username or the user of the referenced IAMUser CR
+func (s S3) GetRestrictedInlinePolicyInput(username string) (*iam.PutUserPolicyInput, error) {
+	policyName := s.GetPolicyName(username)
+	policyDoc, err := DesiredRestrictedPolicyDocForBucket(policyName, s.GetRegion(), s.GetBucketName(),
+		s.Spec.IAMUser.AccessLevel, s.Spec.IAMUser.CustomActions, s.Spec.NetworkRestrictions)
+	if err != nil {
+		return nil, err
+	}
+	return &iam.PutUserPolicyInput{
+		PolicyDocument: aws.String(policyDoc),
+		PolicyName:     aws.String(policyName),
+		UserName:       aws.String(username),
+	}, nil
+}
+
+func (s S3) GetConsumerUsername(c BucketConsumer) string {
+	if c.Username != "" {
+		return c.Username
+	}
+	// consumers created with the legacy <namespace>-<name>-<consumer> username keep their user
+	legacy := utils.LegacyIAMUsername(s.GetNamespace(), fmt.Sprintf("%v-%v", s.GetName(), c.Name))
+	for _, recorded := range s.Status.Consumers {
+		if recorded.Name == c.Name && recorded.Username == legacy {
+			return legacy
+		}
+	}
+	// consumer names cannot contain dots, so <cr name>.<consumer> is unique even though CR names can
+	return utils.DefaultIAMUsername(s.GetNamespace(), fmt.Sprintf("%v.%v", s.GetName(), c.Name), utils.IAM_USERNAME_SUFFIX_CONSUMER)
+}
+
+// IAMUserOwner tags the bucket and consumer users of the CR
+func (s S3) IAMUserOwner() utils.IAMUserOwner {
+	return utils.IAMUserOwner{Kind: "S3", Namespace: s.GetNamespace(), Name: s.GetName(), UID: string(s.GetUID())}
+}
+
+func (s S3) GetConsumerSecretName(c BucketConsumer) string {
+	if c.SecretName != "" {
+		return c.SecretName
+	}
+	return fmt.Sprintf("%v-%v-iam-secret", s.GetName(), c.Name)
+}
+
+// GetConsumerPolicyInput builds the inline policy of a consumer, limited to its prefix
+func (s S3) GetConsumerPolicyInput(c BucketConsumer) (*iam.PutUserPolicyInput, error) {
+	username := s.GetConsumerUsername(c)
+	policyName := s.GetPolicyName(username)
+	policyDoc, err := DesiredPrefixPolicyDocForBucket(policyName, s.GetRegion(), s.GetBucketName(), c.Prefix, c.AccessLevel, c.CustomActions,
+		s.Spec.NetworkRestrictions)
+	if err != nil {
+		return nil, err
+	}
+	return &iam.PutUserPolicyInput{
+		PolicyDocument: aws.String(policyDoc),
+		PolicyName:     aws.String(policyName),
+		UserName:       aws.String(username),
+	}, nil
+}
@@ -0,0 +1,301 @@
+package v1beta1
+
+import (
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// FeatureState is the desired state of a bucket feature that can be switched on and off
+// +kubebuilder:validation:Enum=Enabled;Suspended;Unmanaged
+type FeatureState string
+
+const (
+	FEATURE_ENABLED   FeatureState = "Enabled"
+	FEATURE_SUSPENDED FeatureState = "Suspended"
+	// the operator leaves the feature as it is on the bucket
+	FEATURE_UNMANAGED FeatureState = "Unmanaged"
+)
+
+// Phase is a one word summary of the CR state, details are in the conditions
+type Phase string
+
+const (
+	PHASE_READY    Phase = "Ready"
+	PHASE_DELETING Phase = "Deleting"
+	PHASE_STALLED  Phase = "Stalled"
+)
+
+// AccessLevel selects the actions the generated IAM policy grants on the bucket
+// +kubebuilder:validation:Enum=ReadOnly;ReadWrite;WriteOnly;Admin;Custom
+type AccessLevel string
+
+const (
+	ACCESS_LEVEL_READ_ONLY  AccessLevel = "ReadOnly"
+	ACCESS_LEVEL_READ_WRITE AccessLevel = "ReadWrite"
+	ACCESS_LEVEL_WRITE_ONLY AccessLevel = "WriteOnly"
+	// s3:* on the bucket and its objects, including bucket policy and deletion
+	ACCESS_LEVEL_ADMIN AccessLevel = "Admin"
+	// only the actions listed in customActions
+	ACCESS_LEVEL_CUSTOM AccessLevel = "Custom"
+)
+
+// S3Spec defines the desired state of S3
+// +kubebuilder:validation:XValidation:rule="!has(oldSelf.bucketName) || (has(self.bucketName) && self.bucketName == oldSelf.bucketName)",message="bucketName is immutable once set"
+// +kubebuilder:validation:XValidation:rule="self.region == oldSelf.region",message="region is immutable"
+// +kubebuilder:validation:XValidation:rule="(has(self.objectLockEnabled) && self.objectLockEnabled) == (has(oldSelf.objectLockEnabled) && oldSelf.objectLockEnabled)",message="objectLockEnabled is immutable"
+// +kubebuilder:validation:XValidation:rule="!(has(self.bucketPolicy) && has(self.bucketPolicyFrom))",message="bucketPolicy and bucketPolicyFrom are mutually exclusive"
+type S3Spec struct {
+	// +kubebuilder:validation:Required
+	Region string `json:"region"`
+
+	// Globally unique bucket name. When omitted, a name is generated from bucketNamePrefix,
+	// the namespace and a hash, and recorded in status.bucketName.
+	// +optional
+	BucketName string `json:"bucketName,omitempty"`
+
+	// Prefix of the generated bucket name, used only when bucketName is omitted. Defaults to the CR name.
+	// +optional
+	BucketNamePrefix string `json:"bucketNamePrefix,omitempty"`
+
+	// The canned ACL to apply to the bucket.
+	// +kubebuilder:validation:Required
+	// +kubebuilder:validation:Enum:=private;public-read;public-read-write;authenticated-read
+	BucketACL string `json:"bucketACL"`
+
+	// Create the bucket with S3 Object Lock, can only be set when the bucket is created.
+	// +optional
+	ObjectLockEnabled bool `json:"objectLockEnabled,omitempty"`
+
+	// Bucket versioning, Unmanaged ( or omitted ) leaves it as it is on the bucket.
+	// +optional
+	Versioning FeatureState `json:"versioning,omitempty"`
+
+	// Transfer acceleration, Unmanaged ( or omitted ) leaves it as it is on the bucket.
+	// +optional
+	TransferAcceleration FeatureState `json:"transferAcceleration,omitempty"`
+
+	// Bucket policy document, rendered as a Go template, e.g: "Resource": ["{{ .BucketARN }}/*"].
+	// See BucketPolicyTemplateData for the available variables.
+	// +optional
+	BucketPolicy string `json:"bucketPolicy,omitempty"`
+
+	// Reads the bucket policy template from a ConfigMap key instead of bucketPolicy
+	// +optional
+	BucketPolicyFrom *BucketPolicySource `json:"bucketPolicyFrom,omitempty"`
+
+	// Other AWS accounts or roles granted access, turned into bucket policy statements merged with bucketPolicy
+	// +optional
+	CrossAccountAccess []CrossAccountAccess `json:"crossAccountAccess,omitempty"`
+
+	// Networks the generated credentials can be used from, requests from anywhere else are denied
+	// +optional
+	NetworkRestrictions *NetworkRestrictions `json:"networkRestrictions,omitempty"`
+
+	// IAM user created for this bucket and the access it gets, only accessLevel and customActions
+	// can be used together with iamUserRef
+	// +optional
+	IAMUser BucketIAMUser `json:"iamUser,omitempty"`
+
+	// Tenants sharing the bucket, each gets its own IAM user, access keys secret and access limited to its prefix
+	// +optional
+	// +listType=map
+	// +listMapKey=name
+	Consumers []BucketConsumer `json:"consumers,omitempty"`
+
+	// IAMUser CR in the same namespace that is granted access to the bucket instead of creating a user for it.
+	// The user, its access keys and secret stay with the IAMUser CR, only the bucket inline policy is managed here.
+	// +optional
+	IAMUserRef *v1.LocalObjectReference `json:"iamUserRef,omitempty"`
+}
+
+// CrossAccountAccess grants another account or role access to the bucket through the bucket policy
+type CrossAccountAccess struct {
+	// 12 digit account ID or IAM role ARN, e.g: 123456789012 or arn:aws:iam::123456789012:role/reader
+	// +kubebuilder:validation:Required
+	Principal string `json:"principal"`
+
+	// Defaults to ReadOnly
+	// +optional
+	// +kubebuilder:validation:Enum=ReadOnly;ReadWrite;WriteOnly
+	AccessLevel AccessLevel `json:"accessLevel,omitempty"`
+
+	// Key prefix to limit access to, must end with a slash. Omitted allows the whole bucket.
+	// +optional
+	Prefix string `json:"prefix,omitempty"`
+}
+
+// NetworkRestrictions lists the networks requests may come from, a request matching any of them is allowed
+type NetworkRestrictions struct {
+	// CIDRs matched against aws:SourceIp, e.g: 203.0.113.0/24
+	// +optional
+	SourceIPs []string `json:"sourceIPs,omitempty"`
+
+	// VPC endpoint IDs matched against aws:SourceVpce, e.g: vpce-1a2b3c4d
+	// +optional
+	VPCEndpointIDs []string `json:"vpcEndpointIDs,omitempty"`
+
+	// VPC IDs matched against aws:SourceVpc, e.g: vpc-1a2b3c4d
+	// +optional
+	VPCIDs []string `json:"vpcIDs,omitempty"`
+
+	// Also add a deny statement to the bucket policy, so object access from other networks is denied to every principal
+	// but the operator, which empties the bucket on delete from wherever it runs. Bucket configuration calls are not restricted.
+	// +optional
+	EnforceInBucketPolicy bool `json:"enforceInBucketPolicy,omitempty"`
+}
+
+// BucketPolicySource references a bucket policy template stored outside the CR
+type BucketPolicySource struct {
+	// ConfigMap in the CR namespace, a missing ConfigMap or key means no bucketPolicy when optional is true
+	ConfigMapKeyRef v1.ConfigMapKeySelector `json:"configMapKeyRef"`
+}
+
+// BucketIAMUser is the IAM user created for the bucket
+// +kubebuilder:validation:XValidation:rule="has(self.accessLevel) && self.accessLevel == 'Custom' ? has(self.customActions) && size(self.customActions) > 0 : !has(self.customActions)",message="customActions must be set when accessLevel is Custom, and only then"
+type BucketIAMUser struct {
+	// Defaults to <namespace>.<name>.s3 when the defaulting webhook is enabled
+	// +optional
+	Username string `json:"username,omitempty"`
+
+	// Actions granted on the bucket, Admin ( or omitted ) grants s3:*
+	// +optional
+	AccessLevel AccessLevel `json:"accessLevel,omitempty"`
+
+	// S3 actions granted when accessLevel is Custom, e.g: s3:GetObject
+	// +optional
+	CustomActions []string `json:"customActions,omitempty"`
+}
+
+// BucketConsumer is a tenant of the bucket limited to a prefix
+// +kubebuilder:validation:XValidation:rule="has(self.accessLevel) && self.accessLevel == 'Custom' ? has(self.customActions) && size(self.customActions) > 0 : !has(self.customActions)",message="customActions must be set when accessLevel is Custom, and only then"
+type BucketConsumer struct {
+	// Unique within the CR, used to derive the username and secret name
+	// +kubebuilder:validation:Pattern=`^[a-z0-9]([-a-z0-9]*[a-z0-9])?$`
+	// +kubebuilder:validation:MaxLength=63
+	Name string `json:"name"`
+
+	// Defaults to <namespace>.<cr name>.<consumer name>.consumer. Changing it replaces the user and its access keys.
+	// +optional
+	Username string `json:"username,omitempty"`
+
+	// Key prefix the consumer is limited to, must end with a slash, e.g: tenant-a/
+	// +kubebuilder:validation:Required
+	Prefix string `json:"prefix"`
+
+	// Actions granted under the prefix, Admin ( or omitted ) grants s3:* on the objects under the prefix
+	// +optional
+	AccessLevel AccessLevel `json:"accessLevel,omitempty"`
+
+	// S3 actions granted when accessLevel is Custom
+	// +optional
+	CustomActions []string `json:"customActions,omitempty"`
+
+	// Name of the k8s secret holding the access keys, defaults to <cr name>-<consumer name>-iam-secret
+	// +optional
+	SecretName string `json:"secretName,omitempty"`
+}
+
+// ConsumerStatus records the user and secret created for a consumer, so they are cleaned up once it is removed
+type ConsumerStatus struct {
+	Name       string `json:"name"`
+	Username   string `json:"username"`
+	SecretName string `json:"secretName"`
+}
+
+// LockdownStatus records an incident lockdown of the bucket and its credentials
+type LockdownStatus struct {
+	// Value of the s3.agill.apps/lockdown annotation, e.g: the incident ticket
+	Reason string `json:"reason"`
+
+	// When the lockdown started
+	Since metav1.Time `json:"since"`
+
+	// Access keys that were active and got deactivated, only these are activated again
+	// +optional
+	DeactivatedAccessKeys []LockedAccessKey `json:"deactivatedAccessKeys,omitempty"`
+
+	// Bucket policy before the lockdown statement was added, empty when the bucket had none
+	// +optional
+	PreviousBucketPolicy string `json:"previousBucketPolicy,omitempty"`
+}
+
+// LockedAccessKey is an access key deactivated by a lockdown
+type LockedAccessKey struct {
+	Username    string `json:"username"`
+	AccessKeyID string `json:"accessKeyID"`
+}
+
+// S3Status defines the observed state of S3
+type S3Status struct {
+	// +optional
+	Phase Phase `json:"phase,omitempty"`
+
+	// Name of the bucket managed for this CR, set once the bucket is created or adopted
+	// +optional
+	BucketName string `json:"bucketName,omitempty"`
+
+	// Region the bucket was created in
+	// +optional
+	Region string `json:"region,omitempty"`
+
+	// Whether the bucket was created with object lock enabled
+	// +optional
+	ObjectLockEnabled bool `json:"objectLockEnabled,omitempty"`
+
+	// Bumped every time a generated bucket name turns out to be taken
+	// +optional
+	BucketNameAttempt int `json:"bucketNameAttempt,omitempty"`
+
+	// Consumers that have a user and secret
+	// +optional
+	Consumers []ConsumerStatus `json:"consumers,omitempty"`
+
+	// Hash a BucketApproval must reference for a public bucket to be reconciled, empty for private buckets
+	// +optional
+	ApprovalSpecHash string `json:"approvalSpecHash,omitempty"`
+
+	// Set while the s3.agill.apps/lockdown annotation is, holds what is restored when it is removed
+	// +optional
+	Lockdown *LockdownStatus `json:"lockdown,omitempty"`
+
+	// Bytes stored in the bucket as last reported by CloudWatch, only tracked in namespaces with a bytes quota
+	// +optional
+	SizeBytes int64 `json:"sizeBytes,omitempty"`
+
+	// When sizeBytes was last refreshed
+	// +optional
+	SizeCheckedAt *metav1.Time `json:"sizeCheckedAt,omitempty"`
+
+	// +optional
+	Conditions []Condition `json:"conditions,omitempty"`
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// S3 is the Schema for the s3s API
+// +kubebuilder:subresource:status
+// +kubebuilder:storageversion
+// +kubebuilder:resource:path=s3s,scope=Namespaced
+// +kubebuilder:printcolumn:name="bucket-name",type=string,JSONPath=`.status.bucketName`
+// +kubebuilder:printcolumn:name="IAM-User",type=string,JSONPath=`.spec.iamUser.username`
+// +kubebuilder:printcolumn:name="Phase",type=string,JSONPath=`.status.phase`
+// +kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
+type S3 struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+	Spec              S3Spec   `json:"spec,omitempty"`
+	Status            S3Status `json:"status,omitempty"`
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// S3List contains a list of S3
+type S3List struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []S3 `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&S3{}, &S3List{})
+}
@@ -0,0 +1,315 @@
+package v1beta1
+
+import (
+	"fmt"
+	"net"
+	"regexp"
+	"strings"
+
+	"github.com/agill17/s3-operator/pkg/utils"
+	apivalidation "k8s.io/apimachinery/pkg/api/validation"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+var (
+	// https://docs.aws.amazon.com/AmazonS3/latest/dev/BucketRestrictions.html
+	bucketNameRegex       = regexp.MustCompile(`^[a-z0-9][a-z0-9.-]{1,61}[a-z0-9]$`)
+	bucketNamePrefixRegex = regexp.MustCompile(`^[a-z0-9][a-z0-9-]*$`)
+	// https://docs.aws.amazon.com/IAM/latest/APIReference/API_CreateUser.html
+	iamUsernameRegex = regexp.MustCompile(`^[\w+=,.@-]{1,64}$`)
+	// s3:<Action>, wildcards allowed e.g: s3:Get*
+	s3ActionRegex      = regexp.MustCompile(`^s3:[A-Za-z*?]+$`)
+	vpcEndpointIDRegex = regexp.MustCompile(`^vpce-[0-9a-f]+$`)
+	vpcIDRegex         = regexp.MustCompile(`^vpc-[0-9a-f]+$`)
+	// account ID or role ARN in any partition
+	crossAccountPrincipalRegex = regexp.MustCompile(`^(\d{12}|arn:aws[a-z-]*:iam::\d{12}:role/[\w+=,.@/-]+)$`)
+)
+
+// ValidateS3 returns every problem in the spec that would only surface as a failing AWS call later
+func (s S3) ValidateS3() field.ErrorList {
+	allErrs := field.ErrorList{}
+	spec := field.NewPath("spec")
+
+	if s.Spec.BucketName != "" {
+		allErrs = append(allErrs, ValidateBucketName(s.Spec.BucketName, spec.Child("bucketName"))...)
+		if s.Spec.BucketNamePrefix != "" {
+			allErrs = append(allErrs, field.Forbidden(spec.Child("bucketNamePrefix"), "only used to generate a name when bucketName is omitted"))
+		}
+	} else if s.Spec.BucketNamePrefix != "" && !bucketNamePrefixRegex.MatchString(s.Spec.BucketNamePrefix) {
+		allErrs = append(allErrs, field.Invalid(spec.Child("bucketNamePrefix"), s.Spec.BucketNamePrefix,
+			"must start with a lowercase letter or number and contain only lowercase letters, numbers and hyphens"))
+	}
+
+	if !utils.IsValidRegion(s.Spec.Region) {
+		allErrs = append(allErrs, field.Invalid(spec.Child("region"), s.Spec.Region, "not a known AWS region"))
+	}
+
+	username := s.Spec.IAMUser.Username
+	if s.UsesIAMUserRef() {
+		if username != "" {
+			allErrs = append(allErrs, field.Forbidden(spec.Child("iamUser", "username"), "cannot be set together with iamUserRef"))
+		}
+	} else if username == "" {
+		if len(s.Spec.Consumers) == 0 {
+			allErrs = append(allErrs, field.Required(spec.Child("iamUser", "username"), "required unless iamUserRef or consumers are set"))
+		}
+	} else if !iamUsernameRegex.MatchString(username) {
+		allErrs = append(allErrs, field.Invalid(spec.Child("iamUser", "username"), username,
+			"must be 1-64 characters of letters, numbers and +=,.@_-"))
+	}
+
+	allErrs = append(allErrs, validateCustomActions(s.Spec.IAMUser.AccessLevel, s.Spec.IAMUser.CustomActions, spec.Child("iamUser", "customActions"))...)
+	allErrs = append(allErrs, s.validateConsumers(spec.Child("consumers"))...)
+
+	if s.Spec.ObjectLockEnabled && s.Spec.Versioning != FEATURE_ENABLED {
+		allErrs = append(allErrs, field.Invalid(spec.Child("versioning"), s.Spec.Versioning,
+			"must be Enabled when objectLockEnabled is true, object lock requires versioning"))
+	}
+
+	if s.Spec.TransferAcceleration == FEATURE_ENABLED && strings.Contains(s.Spec.BucketName, ".") {
+		allErrs = append(allErrs, field.Invalid(spec.Child("transferAcceleration"), s.Spec.TransferAcceleration,
+			"transfer acceleration is not supported for bucket names containing dots"))
+	}
+
+	if s.Spec.BucketPolicy != "" {
+		allErrs = append(allErrs, s.validateBucketPolicy(spec.Child("bucketPolicy"))...)
+	}
+	if s.Spec.BucketPolicyFrom != nil {
+		allErrs = append(allErrs, s.validateBucketPolicyFrom(spec.Child("bucketPolicyFrom"))...)
+	}
+
+	allErrs = append(allErrs, validateCrossAccountAccess(s.Spec.CrossAccountAccess, spec.Child("crossAccountAccess"))...)
+
+	if s.Spec.NetworkRestrictions != nil {
+		allErrs = append(allErrs, validateNetworkRestrictions(*s.Spec.NetworkRestrictions, spec.Child("networkRestrictions"))...)
+	}
+
+	return allErrs
+}
+
+// ValidateS3Update rejects changes to fields that are fixed once the bucket exists.
+// Changing them would make the operator create a second bucket and orphan the first.
+func (s S3) ValidateS3Update(old S3) field.ErrorList {
+	allErrs := s.ValidateS3()
+	spec := field.NewPath("spec")
+
+	// an omitted bucketName may be pinned to the generated name, anything else is a rename
+	if s.Spec.BucketName != old.Spec.BucketName &&
+		!(old.Spec.BucketName == "" && old.Status.BucketName != "" && s.Spec.BucketName == old.Status.BucketName) &&
+		!(old.Spec.BucketName == "" && old.Status.BucketName == "") {
+		allErrs = append(allErrs, field.Invalid(spec.Child("bucketName"), s.Spec.BucketName, apivalidation.FieldImmutableErrorMsg))
+	}
+	allErrs = append(allErrs, apivalidation.ValidateImmutableField(s.Spec.Region, old.Spec.Region, spec.Child("region"))...)
+	allErrs = append(allErrs, apivalidation.ValidateImmutableField(s.Spec.ObjectLockEnabled, old.Spec.ObjectLockEnabled, spec.Child("objectLockEnabled"))...)
+	return allErrs
+}
+
+// ValidateBucketName checks the S3 bucket naming rules
+func ValidateBucketName(name string, fldPath *field.Path) field.ErrorList {
+	allErrs := field.ErrorList{}
+	switch {
+	case !bucketNameRegex.MatchString(name):
+		allErrs = append(allErrs, field.Invalid(fldPath, name,
+			"must be 3-63 characters of lowercase letters, numbers, dots and hyphens, starting and ending with a letter or number"))
+	case strings.Contains(name, ".."):
+		allErrs = append(allErrs, field.Invalid(fldPath, name, "must not contain two adjacent periods"))
+	case net.ParseIP(name) != nil:
+		allErrs = append(allErrs, field.Invalid(fldPath, name, "must not be formatted as an IP address"))
+	case strings.HasPrefix(name, "xn--"):
+		allErrs = append(allErrs, field.Invalid(fldPath, name, "must not start with xn--"))
+	case strings.HasSuffix(name, "-s3alias"):
+		allErrs = append(allErrs, field.Invalid(fldPath, name, "must not end with -s3alias"))
+	}
+	return allErrs
+}
+
+func (s S3) validateBucketPolicy(fldPath *field.Path) field.ErrorList {
+	allErrs := field.ErrorList{}
+	rendered, err := s.renderBucketPolicyPreview()
+	if err != nil {
+		return append(allErrs, field.Invalid(fldPath, "", fmt.Sprintf("must be a valid template: %v", err)))
+	}
+	doc, err := ParsePolicyDocument(rendered)
+	if err != nil {
+		return append(allErrs, field.Invalid(fldPath, "", fmt.Sprintf("must be a valid policy document: %v", err)))
+	}
+
+	// generated names are unknown until the bucket exists
+	bucketName := s.Spec.BucketName
+	if bucketName == "" {
+		bucketName = s.Status.BucketName
+	}
+
+	for i, statement := range doc.Statements {
+		stmtPath := fldPath.Child("Statement").Index(i)
+		if strings.HasPrefix(statement.SID, GENERATED_SID_PREFIX) {
+			allErrs = append(allErrs, field.Invalid(stmtPath.Child("Sid"), statement.SID,
+				fmt.Sprintf("Sids starting with %v are reserved for statements generated by the operator", GENERATED_SID_PREFIX)))
+		}
+		if statement.Effect != "Allow" && statement.Effect != "Deny" {
+			allErrs = append(allErrs, field.NotSupported(stmtPath.Child("Effect"), statement.Effect, []string{"Allow", "Deny"}))
+		}
+		if statement.Principal == nil && statement.NotPrincipal == nil {
+			allErrs = append(allErrs, field.Required(stmtPath.Child("Principal"), "bucket policy statements need a Principal"))
+		}
+		if len(statement.Action) == 0 && len(statement.NotAction) == 0 {
+			allErrs = append(allErrs, field.Required(stmtPath.Child("Action"), ""))
+		}
+		if len(statement.Resource) == 0 && len(statement.NotResource) == 0 {
+			allErrs = append(allErrs, field.Required(stmtPath.Child("Resource"), ""))
+		}
+		if bucketName == "" {
+			continue
+		}
+		for j, resource := range statement.Resource {
+			if !isBucketResource(resource, bucketName) {
+				allErrs = append(allErrs, field.Invalid(stmtPath.Child("Resource").Index(j), resource,
+					fmt.Sprintf("must reference bucket %v, e.g: %v", bucketName, utils.S3ObjectsARN(s.GetRegion(), bucketName))))
+			}
+		}
+	}
+	return allErrs
+}
+
+// validateBucketPolicyFrom only checks the reference, the ConfigMap may not exist yet
+func (s S3) validateBucketPolicyFrom(fldPath *field.Path) field.ErrorList {
+	allErrs := field.ErrorList{}
+	if s.Spec.BucketPolicy != "" {
+		allErrs = append(allErrs, field.Forbidden(fldPath, "bucketPolicy and bucketPolicyFrom are mutually exclusive"))
+	}
+	refPath := fldPath.Child("configMapKeyRef")
+	if s.Spec.BucketPolicyFrom.ConfigMapKeyRef.Name == "" {
+		allErrs = append(allErrs, field.Required(refPath.Child("name"), ""))
+	}
+	if s.Spec.BucketPolicyFrom.ConfigMapKeyRef.Key == "" {
+		allErrs = append(allErrs, field.Required(refPath.Child("key"), ""))
+	}
+	return allErrs
+}
+
+// isBucketResource is true for the bucket ARN and object ARNs within it, in any partition
+func isBucketResource(resource, bucketName string) bool {
+	parts := strings.SplitN(resource, ":::", 2)
+	if len(parts) != 2 || !strings.HasPrefix(parts[0], "arn:aws") || !strings.HasSuffix(parts[0], ":s3") {
+		return false
+	}
+	return parts[1] == bucketName || strings.HasPrefix(parts[1], bucketName+"/")
+}
+
+// validateCustomActions requires customActions for the Custom access level only, each must be an s3 action
+func validateCustomActions(level AccessLevel, customActions []string, fldPath *field.Path) field.ErrorList {
+	allErrs := field.ErrorList{}
+	if level != ACCESS_LEVEL_CUSTOM {
+		if len(customActions) > 0 {
+			allErrs = append(allErrs, field.Forbidden(fldPath, "only used when accessLevel is Custom"))
+		}
+		return allErrs
+	}
+	if len(customActions) == 0 {
+		allErrs = append(allErrs, field.Required(fldPath, "required when accessLevel is Custom"))
+	}
+	for i, action := range customActions {
+		if !s3ActionRegex.MatchString(action) {
+			allErrs = append(allErrs, field.Invalid(fldPath.Index(i), action, "must be an s3 action, e.g: s3:GetObject"))
+		}
+	}
+	return allErrs
+}
+
+// validateConsumers checks each consumer gets a user of its own and a prefix no other consumer can read into
+func (s S3) validateConsumers(fldPath *field.Path) field.ErrorList {
+	allErrs := field.ErrorList{}
+	names, usernames, secretNames := map[string]bool{}, map[string]bool{s.Spec.IAMUser.Username: true}, map[string]bool{}
+	for i, c := range s.Spec.Consumers {
+		idxPath := fldPath.Index(i)
+		if names[c.Name] {
+			allErrs = append(allErrs, field.Duplicate(idxPath.Child("name"), c.Name))
+		}
+		names[c.Name] = true
+
+		username := s.GetConsumerUsername(c)
+		if !iamUsernameRegex.MatchString(username) {
+			allErrs = append(allErrs, field.Invalid(idxPath.Child("username"), username,
+				"must be 1-64 characters of letters, numbers and +=,.@_-"))
+		} else if usernames[username] {
+			allErrs = append(allErrs, field.Duplicate(idxPath.Child("username"), username))
+		}
+		usernames[username] = true
+
+		secretName := s.GetConsumerSecretName(c)
+		if secretNames[secretName] || secretName == s.GetIAMK8SSecretName() {
+			allErrs = append(allErrs, field.Duplicate(idxPath.Child("secretName"), secretName))
+		}
+		secretNames[secretName] = true
+
+		if c.Prefix == "" {
+			allErrs = append(allErrs, field.Required(idxPath.Child("prefix"), ""))
+		} else {
+			allErrs = append(allErrs, validatePrefix(c.Prefix, idxPath.Child("prefix"))...)
+		}
+
+		for _, other := range s.Spec.Consumers[:i] {
+			if other.Prefix != "" && c.Prefix != "" && (strings.HasPrefix(c.Prefix, other.Prefix) || strings.HasPrefix(other.Prefix, c.Prefix)) {
+				allErrs = append(allErrs, field.Invalid(idxPath.Child("prefix"), c.Prefix,
+					fmt.Sprintf("overlaps with the prefix of consumer %v", other.Name)))
+			}
+		}
+
+		allErrs = append(allErrs, validateCustomActions(c.AccessLevel, c.CustomActions, idxPath.Child("customActions"))...)
+	}
+	return allErrs
+}
+
+// validateNetworkRestrictions requires at least one network, an empty list would not restrict anything
+func validateNetworkRestrictions(n NetworkRestrictions, fldPath *field.Path) field.ErrorList {
+	allErrs := field.ErrorList{}
+	if n.IsEmpty() {
+		allErrs = append(allErrs, field.Required(fldPath, "list at least one of sourceIPs, vpcEndpointIDs or vpcIDs"))
+	}
+	for i, cidr := range n.SourceIPs {
+		if _, _, err := net.ParseCIDR(cidr); err != nil {
+			allErrs = append(allErrs, field.Invalid(fldPath.Child("sourceIPs").Index(i), cidr, "must be a CIDR, e.g: 203.0.113.0/24"))
+		}
+	}
+	for i, id := range n.VPCEndpointIDs {
+		if !vpcEndpointIDRegex.MatchString(id) {
+			allErrs = append(allErrs, field.Invalid(fldPath.Child("vpcEndpointIDs").Index(i), id, "must be a VPC endpoint ID, e.g: vpce-1a2b3c4d"))
+		}
+	}
+	for i, id := range n.VPCIDs {
+		if !vpcIDRegex.MatchString(id) {
+			allErrs = append(allErrs, field.Invalid(fldPath.Child("vpcIDs").Index(i), id, "must be a VPC ID, e.g: vpc-1a2b3c4d"))
+		}
+	}
+	return allErrs
+}
+
+func validatePrefix(prefix string, fldPath *field.Path) field.ErrorList {
+	allErrs := field.ErrorList{}
+	if strings.HasPrefix(prefix, "/") || !strings.HasSuffix(prefix, "/") || strings.ContainsAny(prefix, "*?") {
+		allErrs = append(allErrs, field.Invalid(fldPath, prefix,
+			"must end with / and must not start with / or contain wildcards, e.g: tenant-a/"))
+	}
+	return allErrs
+}
+
+func validateCrossAccountAccess(access []CrossAccountAccess, fldPath *field.Path) field.ErrorList {
+	allErrs := field.ErrorList{}
+	for i, c := range access {
+		idxPath := fldPath.Index(i)
+		if !crossAccountPrincipalRegex.MatchString(c.Principal) {
+			allErrs = append(allErrs, field.Invalid(idxPath.Child("principal"), c.Principal,
+				"must be a 12 digit account ID or an IAM role ARN, e.g: arn:aws:iam::123456789012:role/reader"))
+		}
+		switch c.GetAccessLevel() {
+		case ACCESS_LEVEL_READ_ONLY, ACCESS_LEVEL_READ_WRITE, ACCESS_LEVEL_WRITE_ONLY:
+		default:
+			allErrs = append(allErrs, field.NotSupported(idxPath.Child("accessLevel"), c.AccessLevel,
+				[]string{string(ACCESS_LEVEL_READ_ONLY), string(ACCESS_LEVEL_READ_WRITE), string(ACCESS_LEVEL_WRITE_ONLY)}))
+		}
+		if c.Prefix != "" {
+			allErrs = append(allErrs, validatePrefix(c.Prefix, idxPath.Child("prefix"))...)
+		}
+	}
+	return allErrs
+}
@@ -0,0 +1,303 @@
+package v1beta1
+
+import (
+	"reflect"
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+func validS3() S3 {
+	return S3{
+		ObjectMeta: metav1.ObjectMeta{Name: "bucket", Namespace: "team-a"},
+		Spec: S3Spec{
+			Region:     "us-east-1",
+			BucketName: "my-bucket",
+			BucketACL:  "private",
+			IAMUser:    BucketIAMUser{Username: "team-a.bucket.s3"},
+		},
+	}
+}
+
+// errorFields returns the field paths of errs, nil when there are none
+func errorFields(errs field.ErrorList) []string {
+	var fields []string
+	for _, err := range errs {
+		fields = append(fields, err.Field)
+	}
+	return fields
+}
+
+func TestValidateS3(t *testing.T) {
+	tests := []struct {
+		name       string
+		mutate     func(s *S3)
+		wantFields []string
+	}{
+		{name: "valid", mutate: func(s *S3) {}},
+		{name: "generated name", mutate: func(s *S3) { s.Spec.BucketName, s.Spec.BucketNamePrefix = "", "logs" }},
+		{
+			name:       "prefix together with bucketName",
+			mutate:     func(s *S3) { s.Spec.BucketNamePrefix = "logs" },
+			wantFields: []string{"spec.bucketNamePrefix"},
+		},
+		{
+			name:       "invalid prefix",
+			mutate:     func(s *S3) { s.Spec.BucketName, s.Spec.BucketNamePrefix = "", "-Logs" },
+			wantFields: []string{"spec.bucketNamePrefix"},
+		},
+		{name: "upper case bucket name", mutate: func(s *S3) { s.Spec.BucketName = "My_Bucket" }, wantFields: []string{"spec.bucketName"}},
+		{name: "adjacent periods", mutate: func(s *S3) { s.Spec.BucketName = "my..bucket" }, wantFields: []string{"spec.bucketName"}},
+		{name: "ip address", mutate: func(s *S3) { s.Spec.BucketName = "192.168.1.1" }, wantFields: []string{"spec.bucketName"}},
+		{name: "xn-- prefix", mutate: func(s *S3) { s.Spec.BucketName = "xn--bucket" }, wantFields: []string{"spec.bucketName"}},
+		{name: "-s3alias suffix", mutate: func(s *S3) { s.Spec.BucketName = "bucket-s3alias" }, wantFields: []string{"spec.bucketName"}},
+		{name: "unknown region", mutate: func(s *S3) { s.Spec.Region = "mars-north-1" }, wantFields: []string{"spec.region"}},
+		{name: "missing username", mutate: func(s *S3) { s.Spec.IAMUser.Username = "" }, wantFields: []string{"spec.iamUser.username"}},
+		{
+			name:       "invalid username",
+			mutate:     func(s *S3) { s.Spec.IAMUser.Username = "team a" },
+			wantFields: []string{"spec.iamUser.username"},
+		},
+		{
+			name: "username together with iamUserRef",
+			mutate: func(s *S3) {
+				s.Spec.IAMUserRef = &v1.LocalObjectReference{Name: "reader"}
+			},
+			wantFields: []string{"spec.iamUser.username"},
+		},
+		{
+			name: "iamUserRef",
+			mutate: func(s *S3) {
+				s.Spec.IAMUser.Username = ""
+				s.Spec.IAMUserRef = &v1.LocalObjectReference{Name: "reader"}
+			},
+		},
+		{
+			name:       "custom without actions",
+			mutate:     func(s *S3) { s.Spec.IAMUser.AccessLevel = ACCESS_LEVEL_CUSTOM },
+			wantFields: []string{"spec.iamUser.customActions"},
+		},
+		{
+			name:       "actions without custom",
+			mutate:     func(s *S3) { s.Spec.IAMUser.CustomActions = []string{"s3:GetObject"} },
+			wantFields: []string{"spec.iamUser.customActions"},
+		},
+		{
+			name: "custom with a non s3 action",
+			mutate: func(s *S3) {
+				s.Spec.IAMUser.AccessLevel = ACCESS_LEVEL_CUSTOM
+				s.Spec.IAMUser.CustomActions = []string{"s3:Get*", "iam:CreateUser"}
+			},
+			wantFields: []string{"spec.iamUser.customActions[1]"},
+		},
+		{
+			name:       "object lock without versioning",
+			mutate:     func(s *S3) { s.Spec.ObjectLockEnabled = true },
+			wantFields: []string{"spec.versioning"},
+		},
+		{
+			name: "object lock with versioning",
+			mutate: func(s *S3) {
+				s.Spec.ObjectLockEnabled = true
+				s.Spec.Versioning = FEATURE_ENABLED
+			},
+		},
+		{
+			name: "acceleration with dots in the name",
+			mutate: func(s *S3) {
+				s.Spec.BucketName = "my.bucket"
+				s.Spec.TransferAcceleration = FEATURE_ENABLED
+			},
+			wantFields: []string{"spec.transferAcceleration"},
+		},
+		{
+			name: "bucket policy",
+			mutate: func(s *S3) {
+				s.Spec.BucketPolicy = `{"Version":"2012-10-17","Statement":[{"Effect":"Allow","Principal":{"AWS":"arn:aws:iam::123456789012:root"},"Action":"s3:GetObject","Resource":"arn:aws:s3:::my-bucket/*"}]}`
+			},
+		},
+		{
+			name: "bucket policy template",
+			mutate: func(s *S3) {
+				s.Spec.BucketPolicy = `{"Statement":[{"Effect":"Allow","Principal":"*","Action":"s3:GetObject","Resource":"{{ .BucketARN }}/*"}]}`
+			},
+		},
+		{
+			name:       "bucket policy is not json",
+			mutate:     func(s *S3) { s.Spec.BucketPolicy = `{"Statement":` },
+			wantFields: []string{"spec.bucketPolicy"},
+		},
+		{
+			name: "bucket policy with a reserved sid, another bucket and no principal",
+			mutate: func(s *S3) {
+				s.Spec.BucketPolicy = `{"Statement":[{"Sid":"S3OperatorMine","Effect":"Allow","Action":"s3:GetObject","Resource":"arn:aws:s3:::other-bucket/*"}]}`
+			},
+			wantFields: []string{"spec.bucketPolicy.Statement[0].Sid", "spec.bucketPolicy.Statement[0].Principal", "spec.bucketPolicy.Statement[0].Resource[0]"},
+		},
+		{
+			name: "bucketPolicy together with bucketPolicyFrom",
+			mutate: func(s *S3) {
+				s.Spec.BucketPolicy = `{"Statement":[]}`
+				s.Spec.BucketPolicyFrom = &BucketPolicySource{ConfigMapKeyRef: v1.ConfigMapKeySelector{
+					LocalObjectReference: v1.LocalObjectReference{Name: "policies"},
+					Key:                  "bucket.json",
+				}}
+			},
+			wantFields: []string{"spec.bucketPolicyFrom"},
+		},
+		{
+			name:       "bucketPolicyFrom without name and key",
+			mutate:     func(s *S3) { s.Spec.BucketPolicyFrom = &BucketPolicySource{} },
+			wantFields: []string{"spec.bucketPolicyFrom.configMapKeyRef.name", "spec.bucketPolicyFrom.configMapKeyRef.key"},
+		},
+		{
+			name: "consumers instead of a username",
+			mutate: func(s *S3) {
+				s.Spec.IAMUser.Username = ""
+				s.Spec.Consumers = []BucketConsumer{{Name: "a", Prefix: "tenant-a/"}, {Name: "b", Prefix: "tenant-b/"}}
+			},
+		},
+		{
+			name: "overlapping consumer prefixes",
+			mutate: func(s *S3) {
+				s.Spec.Consumers = []BucketConsumer{{Name: "a", Prefix: "tenant/"}, {Name: "b", Prefix: "tenant/b/"}}
+			},
+			wantFields: []string{"spec.consumers[1].prefix"},
+		},
+		{
+			name: "duplicate consumers",
+			mutate: func(s *S3) {
+				s.Spec.Consumers = []BucketConsumer{{Name: "a", Prefix: "tenant-a/"}, {Name: "a", Prefix: "tenant-b/"}}
+			},
+			wantFields: []string{"spec.consumers[1].name", "spec.consumers[1].username", "spec.consumers[1].secretName"},
+		},
+		{
+			name: "consumer username taken by the bucket user",
+			mutate: func(s *S3) {
+				s.Spec.Consumers = []BucketConsumer{{Name: "a", Username: "team-a.bucket.s3", Prefix: "tenant-a/"}}
+			},
+			wantFields: []string{"spec.consumers[0].username"},
+		},
+		{
+			name: "consumer prefix without slash",
+			mutate: func(s *S3) {
+				s.Spec.Consumers = []BucketConsumer{{Name: "a", Prefix: "tenant-a"}, {Name: "b"}}
+			},
+			wantFields: []string{"spec.consumers[0].prefix", "spec.consumers[1].prefix"},
+		},
+		{
+			name:       "empty network restrictions",
+			mutate:     func(s *S3) { s.Spec.NetworkRestrictions = &NetworkRestrictions{} },
+			wantFields: []string{"spec.networkRestrictions"},
+		},
+		{
+			name: "invalid networks",
+			mutate: func(s *S3) {
+				s.Spec.NetworkRestrictions = &NetworkRestrictions{
+					SourceIPs:      []string{"203.0.113.0/24", "203.0.113.1"},
+					VPCEndpointIDs: []string{"vpc-1a2b3c4d"},
+					VPCIDs:         []string{"vpce-1a2b3c4d"},
+				}
+			},
+			wantFields: []string{"spec.networkRestrictions.sourceIPs[1]", "spec.networkRestrictions.vpcEndpointIDs[0]", "spec.networkRestrictions.vpcIDs[0]"},
+		},
+		{
+			name: "cross account access",
+			mutate: func(s *S3) {
+				s.Spec.CrossAccountAccess = []CrossAccountAccess{
+					{Principal: "123456789012"},
+					{Principal: "arn:aws-us-gov:iam::123456789012:role/reader", AccessLevel: ACCESS_LEVEL_READ_WRITE, Prefix: "shared/"},
+				}
+			},
+		},
+		{
+			name: "invalid cross account access",
+			mutate: func(s *S3) {
+				s.Spec.CrossAccountAccess = []CrossAccountAccess{
+					{Principal: "arn:aws:iam::123456789012:user/reader", AccessLevel: ACCESS_LEVEL_ADMIN, Prefix: "/shared"},
+				}
+			},
+			wantFields: []string{"spec.crossAccountAccess[0].principal", "spec.crossAccountAccess[0].accessLevel", "spec.crossAccountAccess[0].prefix"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := validS3()
+			tt.mutate(&s)
+			errs := s.ValidateS3()
+			if got := errorFields(errs); !reflect.DeepEqual(got, tt.wantFields) {
+				t.Errorf("ValidateS3() fields = %v, want %v, errors: %v", got, tt.wantFields, errs)
+			}
+		})
+	}
+}
+
+func TestValidateS3Update(t *testing.T) {
+	generated := func(statusName string) S3 {
+		s := validS3()
+		s.Spec.BucketName = ""
+		s.Status.BucketName = statusName
+		return s
+	}
+	tests := []struct {
+		name       string
+		old        S3
+		mutate     func(s *S3)
+		wantFields []string
+	}{
+		{name: "no change", old: validS3(), mutate: func(s *S3) {}},
+		{
+			name:   "mutable fields",
+			old:    validS3(),
+			mutate: func(s *S3) { s.Spec.Versioning, s.Spec.BucketACL = FEATURE_SUSPENDED, "public-read" },
+		},
+		{name: "rename", old: validS3(), mutate: func(s *S3) { s.Spec.BucketName = "other-bucket" }, wantFields: []string{"spec.bucketName"}},
+		{name: "drop the name", old: validS3(), mutate: func(s *S3) { s.Spec.BucketName = "" }, wantFields: []string{"spec.bucketName"}},
+		{
+			name:   "pin the generated name",
+			old:    generated("bucket-team-a-0123456789"),
+			mutate: func(s *S3) { s.Spec.BucketName = "bucket-team-a-0123456789" },
+		},
+		{
+			name:       "rename a generated bucket",
+			old:        generated("bucket-team-a-0123456789"),
+			mutate:     func(s *S3) { s.Spec.BucketName = "other-bucket" },
+			wantFields: []string{"spec.bucketName"},
+		},
+		{
+			// nothing is created yet, the name can still be picked
+			name:   "name a bucket before it is created",
+			old:    generated(""),
+			mutate: func(s *S3) { s.Spec.BucketName = "other-bucket" },
+		},
+		{name: "change region", old: validS3(), mutate: func(s *S3) { s.Spec.Region = "eu-west-1" }, wantFields: []string{"spec.region"}},
+		{
+			name: "enable object lock",
+			old:  validS3(),
+			mutate: func(s *S3) {
+				s.Spec.ObjectLockEnabled = true
+				s.Spec.Versioning = FEATURE_ENABLED
+			},
+			wantFields: []string{"spec.objectLockEnabled"},
+		},
+		{
+			// the new spec is validated as well
+			name:       "invalid new spec",
+			old:        validS3(),
+			mutate:     func(s *S3) { s.Spec.IAMUser.Username = "" },
+			wantFields: []string{"spec.iamUser.username"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := *tt.old.DeepCopy()
+			tt.mutate(&s)
+			errs := s.ValidateS3Update(tt.old)
+			if got := errorFields(errs); !reflect.DeepEqual(got, tt.wantFields) {
+				t.Errorf("ValidateS3Update() fields = %v, want %v, errors: %v", got, tt.wantFields, errs)
+			}
+		})
+	}
+}
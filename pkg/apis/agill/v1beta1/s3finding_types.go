@@ -0,0 +1,91 @@
+package v1beta1
+
+import (
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Severity of a finding, from most to least urgent
+// +kubebuilder:validation:Enum=Critical;High;Medium;Low
+type Severity string
+
+const (
+	SEVERITY_CRITICAL Severity = "Critical"
+	SEVERITY_HIGH     Severity = "High"
+	SEVERITY_MEDIUM   Severity = "Medium"
+	SEVERITY_LOW      Severity = "Low"
+)
+
+// checks run by the scanner against the live bucket configuration
+const (
+	// the bucket ACL grants access to AllUsers or AuthenticatedUsers
+	CHECK_PUBLIC_ACL = "PublicACL"
+	// the bucket policy has an Allow statement for Principal "*"
+	CHECK_PUBLIC_POLICY = "PublicPolicy"
+	// the bucket has no default encryption configured
+	CHECK_ENCRYPTION_MISSING = "EncryptionMissing"
+	// versioning is not enabled on the bucket
+	CHECK_VERSIONING_DISABLED = "VersioningDisabled"
+	// an active access key of a user managed for the bucket is older than the allowed age
+	CHECK_ACCESS_KEY_AGE = "AccessKeyAge"
+	// the bucket policy allows every action ( "*", "s3:*" ) in a statement not generated by the operator
+	CHECK_BROAD_POLICY = "BroadPolicy"
+)
+
+// S3FindingSpec is one failed check of a bucket, written by the scanner
+type S3FindingSpec struct {
+	// S3 CR of the bucket, in the namespace of the finding
+	BucketRef v1.LocalObjectReference `json:"bucketRef"`
+
+	// Name of the bucket in AWS
+	BucketName string `json:"bucketName"`
+
+	// Check that failed, e.g: PublicACL, PublicPolicy, EncryptionMissing, VersioningDisabled, AccessKeyAge, BroadPolicy
+	Check string `json:"check"`
+
+	Severity Severity `json:"severity"`
+
+	// What was found, e.g: the grantee or the statement
+	Message string `json:"message"`
+}
+
+// S3FindingStatus defines the observed state of S3Finding
+type S3FindingStatus struct {
+	// First scan that reported the finding
+	// +optional
+	FirstSeen *metav1.Time `json:"firstSeen,omitempty"`
+
+	// Last scan that reported the finding, refreshed at most once a day. Findings that are no longer reported are deleted
+	// +optional
+	LastSeen *metav1.Time `json:"lastSeen,omitempty"`
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// S3Finding is a security posture issue of a managed bucket, one per bucket and check.
+// Owned by the S3 CR and deleted once the check passes again.
+// +kubebuilder:subresource:status
+// +kubebuilder:resource:path=s3findings,scope=Namespaced
+// +kubebuilder:printcolumn:name="Bucket",type=string,JSONPath=`.spec.bucketRef.name`
+// +kubebuilder:printcolumn:name="Check",type=string,JSONPath=`.spec.check`
+// +kubebuilder:printcolumn:name="Severity",type=string,JSONPath=`.spec.severity`
+// +kubebuilder:printcolumn:name="Last-Seen",type=date,JSONPath=`.status.lastSeen`
+type S3Finding struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+	Spec              S3FindingSpec   `json:"spec,omitempty"`
+	Status            S3FindingStatus `json:"status,omitempty"`
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// S3FindingList contains a list of S3Finding
+type S3FindingList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []S3Finding `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&S3Finding{}, &S3FindingList{})
+}
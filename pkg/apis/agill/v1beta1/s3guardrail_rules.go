@@ -0,0 +1,70 @@
+package v1beta1
+
+import (
+	"fmt"
+	"strings"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+// Selects is true when the guardrail applies to a namespace with the given labels
+func (g S3Guardrail) Selects(namespaceLabels map[string]string) (bool, error) {
+	if g.Spec.NamespaceSelector == nil {
+		return true, nil
+	}
+	selector, err := metav1.LabelSelectorAsSelector(g.Spec.NamespaceSelector)
+	if err != nil {
+		return false, err
+	}
+	return selector.Matches(labels.Set(namespaceLabels)), nil
+}
+
+// GetBucketNamePrefix returns the required bucket name prefix for a namespace, empty when names are not restricted
+func (g S3Guardrail) GetBucketNamePrefix(namespace string) string {
+	return strings.Replace(g.Spec.BucketNamePrefix, "{namespace}", namespace, -1)
+}
+
+// Violations lists what the S3 CR requests that the guardrail forbids. bucketPolicy is checked for public principals:
+// the rendered spec.bucketPolicy at admission, the policy about to be put in Reconcile.
+func (g S3Guardrail) Violations(s S3, bucketPolicy string) field.ErrorList {
+	allErrs := field.ErrorList{}
+	spec := field.NewPath("spec")
+	detail := fmt.Sprintf("forbidden by S3Guardrail %v", g.GetName())
+
+	for _, acl := range g.Spec.ForbiddenBucketACLs {
+		if s.Spec.BucketACL == acl {
+			allErrs = append(allErrs, field.Invalid(spec.Child("bucketACL"), s.Spec.BucketACL, detail))
+		}
+	}
+
+	if len(g.Spec.AllowedRegions) > 0 {
+		allowed := false
+		for _, region := range g.Spec.AllowedRegions {
+			allowed = allowed || s.GetRegion() == region
+		}
+		if !allowed {
+			allErrs = append(allErrs, field.Invalid(spec.Child("region"), s.GetRegion(),
+				fmt.Sprintf("%v, allowed regions: %v", detail, strings.Join(g.Spec.AllowedRegions, ", "))))
+		}
+	}
+
+	if prefix := g.GetBucketNamePrefix(s.GetNamespace()); prefix != "" && !strings.HasPrefix(s.GetBucketName(), prefix) {
+		fldPath := spec.Child("bucketName")
+		if s.Spec.BucketName == "" {
+			fldPath = spec.Child("bucketNamePrefix")
+		}
+		allErrs = append(allErrs, field.Invalid(fldPath, s.GetBucketName(),
+			fmt.Sprintf("%v, bucket names must start with %v", detail, prefix)))
+	}
+
+	if g.Spec.DenyPublicPrincipal && bucketPolicy != "" {
+		// malformed policies are reported by the bucket policy validation
+		if doc, err := ParsePolicyDocument(bucketPolicy); err == nil && doc.HasPublicPrincipal() {
+			allErrs = append(allErrs, field.Forbidden(spec.Child("bucketPolicy"),
+				fmt.Sprintf(`%v, Allow statements must not use Principal "*"`, detail)))
+		}
+	}
+	return allErrs
+}
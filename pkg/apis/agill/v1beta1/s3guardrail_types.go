@@ -0,0 +1,65 @@
+package v1beta1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// S3GuardrailSpec restricts what S3 CRs in the selected namespaces may request
+type S3GuardrailSpec struct {
+	// Namespaces the guardrail applies to, omitted selects every namespace
+	// +optional
+	NamespaceSelector *metav1.LabelSelector `json:"namespaceSelector,omitempty"`
+
+	// Canned bucket ACLs that may not be used, e.g: public-read, public-read-write
+	// +optional
+	// +kubebuilder:validation:items:Enum:=private;public-read;public-read-write;authenticated-read
+	ForbiddenBucketACLs []string `json:"forbiddenBucketACLs,omitempty"`
+
+	// Regions buckets may be created in, omitted allows every region
+	// +optional
+	AllowedRegions []string `json:"allowedRegions,omitempty"`
+
+	// Bucket names, generated ones included, must start with this prefix. {namespace} is replaced with the CR namespace,
+	// e.g: {namespace}-
+	// +optional
+	BucketNamePrefix string `json:"bucketNamePrefix,omitempty"`
+
+	// Forces the baseline statement denying unencrypted PutObject on the selected buckets,
+	// namespace annotations cannot turn it off
+	// +optional
+	RequireEncryption bool `json:"requireEncryption,omitempty"`
+
+	// Rejects bucket policies with an Allow statement for Principal "*"
+	// +optional
+	DenyPublicPrincipal bool `json:"denyPublicPrincipal,omitempty"`
+}
+
+// S3GuardrailStatus defines the observed state of S3Guardrail, violations are reported on the S3 CRs
+type S3GuardrailStatus struct {
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// S3Guardrail is a cluster wide restriction on S3 CRs, enforced at admission and on every reconcile.
+// All guardrails selecting a namespace apply.
+// +kubebuilder:resource:path=s3guardrails,scope=Cluster
+// +kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
+type S3Guardrail struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+	Spec              S3GuardrailSpec   `json:"spec,omitempty"`
+	Status            S3GuardrailStatus `json:"status,omitempty"`
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// S3GuardrailList contains a list of S3Guardrail
+type S3GuardrailList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []S3Guardrail `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&S3Guardrail{}, &S3GuardrailList{})
+}
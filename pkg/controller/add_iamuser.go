@@ -0,0 +1,10 @@
+package controller
+
+import (
+	"github.com/agill17/s3-operator/pkg/controller/iamuser"
+)
+
+func init() {
+	// AddToManagerFuncs is a list of functions to create controllers and add them to a manager.
+	AddToManagerFuncs = append(AddToManagerFuncs, iamuser.Add)
+}
@@ -0,0 +1,10 @@
+package controller
+
+import (
+	"github.com/agill17/s3-operator/pkg/controller/scanner"
+)
+
+func init() {
+	// the scanner is not a controller, it is started by the manager next to them
+	AddToManagerFuncs = append(AddToManagerFuncs, scanner.Add)
+}
@@ -0,0 +1,218 @@
+package bucketaccess
+
+import (
+	"context"
+	agillv1beta1 "github.com/agill17/s3-operator/pkg/apis/agill/v1beta1"
+	customErrors "github.com/agill17/s3-operator/pkg/controller/errors"
+	"github.com/agill17/s3-operator/pkg/controller/options"
+	"github.com/agill17/s3-operator/pkg/controller/quotas"
+	"github.com/agill17/s3-operator/pkg/controller/status"
+	"github.com/agill17/s3-operator/pkg/utils"
+	"github.com/go-logr/logr"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+)
+
+const BUCKET_ACCESS_CONTROLLER = "bucketAccessController"
+
+// field index of the cache, the BucketAccessRequests for a bucket by <namespace>/<name> of the bucket
+const BUCKET_REF_INDEX = "spec.bucketRef"
+
+var log = logf.Log.WithName("controller_bucketaccess")
+
+// Add creates a new BucketAccessRequest Controller and adds it to the Manager. The Manager will set fields on the Controller
+// and Start it when the Manager is Started.
+func Add(mgr manager.Manager, opts options.Options) error {
+	return add(mgr, newReconciler(mgr, opts), opts)
+}
+
+// newReconciler returns a new reconcile.Reconciler
+func newReconciler(mgr manager.Manager, opts options.Options) reconcile.Reconciler {
+	return &ReconcileBucketAccessRequest{
+		client:   mgr.GetClient(),
+		scheme:   mgr.GetScheme(),
+		recorder: mgr.GetEventRecorderFor(BUCKET_ACCESS_CONTROLLER),
+		clients:  opts.Clients,
+
+		adoptUntagged: opts.AdoptUntaggedIAMUsers,
+	}
+}
+
+// add adds a new Controller to mgr with r as the reconcile.Reconciler
+func add(mgr manager.Manager, r reconcile.Reconciler, opts options.Options) error {
+	c, err := controller.New("bucketaccess-controller", mgr, controller.Options{
+		Reconciler:              r,
+		MaxConcurrentReconciles: opts.MaxConcurrentReconciles,
+	})
+	if err != nil {
+		return err
+	}
+
+	// Watch for changes to primary resource BucketAccessRequest
+	err = c.Watch(&source.Kind{Type: &agillv1beta1.BucketAccessRequest{}}, &handler.EnqueueRequestForObject{})
+	if err != nil {
+		return err
+	}
+
+	// watch the access keys secret
+	err = c.Watch(&source.Kind{Type: &v1.Secret{}}, &handler.EnqueueRequestForOwner{
+		OwnerType:    &agillv1beta1.BucketAccessRequest{},
+		IsController: true,
+	})
+	if err != nil {
+		return err
+	}
+
+	// grants and buckets decide whether requests get credentials, deleting either revokes them.
+	// The index saves listing every BucketAccessRequest in the cluster per grant or bucket event.
+	err = mgr.GetFieldIndexer().IndexField(&agillv1beta1.BucketAccessRequest{}, BUCKET_REF_INDEX, func(obj runtime.Object) []string {
+		accessRequest, ok := obj.(*agillv1beta1.BucketAccessRequest)
+		if !ok {
+			return nil
+		}
+		return []string{bucketRefKey(accessRequest.Spec.BucketRef.Namespace, accessRequest.Spec.BucketRef.Name)}
+	})
+	if err != nil {
+		return err
+	}
+	err = c.Watch(&source.Kind{Type: &agillv1beta1.BucketAccessGrant{}}, &handler.EnqueueRequestsFromMapFunc{
+		ToRequests: handler.ToRequestsFunc(func(obj handler.MapObject) []reconcile.Request {
+			grant, ok := obj.Object.(*agillv1beta1.BucketAccessGrant)
+			if !ok {
+				return nil
+			}
+			return requestsForBucket(mgr.GetClient(), grant.GetNamespace(), grant.Spec.BucketRef.Name)
+		}),
+	})
+	if err != nil {
+		return err
+	}
+
+	err = c.Watch(&source.Kind{Type: &agillv1beta1.S3{}}, &handler.EnqueueRequestsFromMapFunc{
+		ToRequests: handler.ToRequestsFunc(func(obj handler.MapObject) []reconcile.Request {
+			return requestsForBucket(mgr.GetClient(), obj.Meta.GetNamespace(), obj.Meta.GetName())
+		}),
+	})
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// blank assignment to verify that ReconcileBucketAccessRequest implements reconcile.Reconciler
+var _ reconcile.Reconciler = &ReconcileBucketAccessRequest{}
+
+// ReconcileBucketAccessRequest reconciles a BucketAccessRequest object
+// Reconciles run concurrently, anything specific to a single CR must stay local to Reconcile
+type ReconcileBucketAccessRequest struct {
+	client   client.Client
+	scheme   *runtime.Scheme
+	recorder record.EventRecorder
+	clients  *utils.ClientPool
+
+	// existing users without owner tags are adopted, see utils.IAMUserOwner
+	adoptUntagged bool
+}
+
+// Reconcile issues credentials for a BucketAccessRequest while a grant in the bucket namespace allows it,
+// and deletes them once no grant does
+func (r *ReconcileBucketAccessRequest) Reconcile(request reconcile.Request) (reconcile.Result, error) {
+	reqLogger := log.WithValues("Request.Namespace", request.Namespace, "Request.Name", request.Name)
+	reqLogger.Info("Reconciling BucketAccessRequest")
+
+	cr := &agillv1beta1.BucketAccessRequest{}
+	err := r.client.Get(context.TODO(), request.NamespacedName, cr)
+	if err != nil {
+		if errors.IsNotFound(err) {
+			return reconcile.Result{}, nil
+		}
+		return reconcile.Result{}, err
+	}
+
+	// terminal errors are not retried until the spec changes or the stall expires, deletes are always retried
+	if cr.GetDeletionTimestamp() == nil && cr.IsStalled() {
+		retryAfter := agillv1beta1.StalledRetryAfter(cr.Status.Conditions, cr.GetGeneration())
+		reqLogger.Info("CR stalled on a terminal error, waiting for a spec change", "retryAfter", retryAfter.String())
+		return reconcile.Result{RequeueAfter: retryAfter}, nil
+	}
+
+	result, err := r.reconcile(cr, reqLogger)
+	return status.HandleReconcileError(BUCKET_ACCESS_CONTROLLER, cr, result, err, r.client, r.recorder, reqLogger)
+}
+
+func (r *ReconcileBucketAccessRequest) reconcile(cr *agillv1beta1.BucketAccessRequest, reqLogger logr.Logger) (reconcile.Result, error) {
+	if errAddingFinalizer := utils.AddFinalizer(utils.BUCKET_ACCESS_FINALIZER, r.client, cr); errAddingFinalizer != nil {
+		reqLogger.Error(errAddingFinalizer, "Failed to add bucket access finalizer, requeue with exponential back-off")
+		return reconcile.Result{}, errAddingFinalizer
+	}
+
+	// handle delete
+	if cr.GetDeletionTimestamp() != nil {
+		if errSettingStatus := status.SetPhase(agillv1beta1.PHASE_DELETING, cr, r.client); errSettingStatus != nil {
+			return reconcile.Result{}, errSettingStatus
+		}
+		if errDeletingUser := r.deleteCredentials(cr); errDeletingUser != nil {
+			return reconcile.Result{}, errDeletingUser
+		}
+		if errRemovingFinalizers := utils.RemoveFinalizer(utils.BUCKET_ACCESS_FINALIZER, cr, r.client); errRemovingFinalizers != nil {
+			reqLogger.Error(errRemovingFinalizers, "Failed to remove bucket access finalizer, retrying..")
+			return reconcile.Result{}, errRemovingFinalizers
+		}
+
+		// do not requeue
+		return reconcile.Result{}, nil
+	}
+
+	// the bucket, grants and this request are watched, any change brings the request back here
+	bucket := &agillv1beta1.S3{}
+	bucketKey := types.NamespacedName{Name: cr.Spec.BucketRef.Name, Namespace: cr.Spec.BucketRef.Namespace}
+	if err := r.client.Get(context.TODO(), bucketKey, bucket); err != nil {
+		if errors.IsNotFound(err) {
+			return reconcile.Result{}, r.revoke(cr, "BucketNotFound", "S3 "+bucketKey.String()+" does not exist")
+		}
+		return reconcile.Result{}, err
+	}
+	if bucket.GetDeletionTimestamp() != nil {
+		return reconcile.Result{}, r.revoke(cr, "BucketDeleting", "S3 "+bucketKey.String()+" is being deleted")
+	}
+	if bucket.Status.BucketName == "" {
+		return reconcile.Result{}, setPending("BucketNotReady", "waiting for the bucket to be created", cr, r.client)
+	}
+
+	grant, reason, errFindingGrant := r.findGrant(cr)
+	if errFindingGrant != nil {
+		return reconcile.Result{}, errFindingGrant
+	}
+	if grant == nil {
+		return reconcile.Result{}, r.revoke(cr, "NotGranted", reason)
+	}
+
+	// checked again periodically, deleting other CRs of the namespace frees quota without touching this one
+	if blocked, errCheckingQuota := quotas.Enforce(r.client, r.recorder, cr); errCheckingQuota != nil || blocked {
+		return reconcile.Result{RequeueAfter: utils.DEFAULT_QUOTA_REQUEUE_AFTER}, errCheckingQuota
+	}
+
+	if errIssuing := r.issueCredentials(cr, bucket, grant); errIssuing != nil {
+		if _, ok := errIssuing.(customErrors.ErrorIAMK8SSecretNeedsUpdate); ok {
+			return reconcile.Result{Requeue: true}, nil
+		}
+		return reconcile.Result{}, errIssuing
+	}
+
+	if errSettingStatus := status.SetPhase(agillv1beta1.PHASE_READY, cr, r.client); errSettingStatus != nil {
+		return reconcile.Result{}, errSettingStatus
+	}
+
+	return reconcile.Result{}, nil
+}
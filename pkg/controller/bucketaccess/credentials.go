@@ -0,0 +1,119 @@
+package bucketaccess
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/agill17/s3-operator/pkg/apis/agill/v1beta1"
+	"github.com/agill17/s3-operator/pkg/controller/credentials"
+	"github.com/agill17/s3-operator/pkg/utils"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/iam"
+	v1 "k8s.io/api/core/v1"
+	apierror "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// findGrant returns the first grant, by name, in the bucket namespace that allows the request,
+// or why none does
+func (r ReconcileBucketAccessRequest) findGrant(cr *v1beta1.BucketAccessRequest) (*v1beta1.BucketAccessGrant, string, error) {
+	grants := &v1beta1.BucketAccessGrantList{}
+	if err := r.client.List(context.TODO(), grants, client.InNamespace(cr.Spec.BucketRef.Namespace)); err != nil {
+		return nil, "", err
+	}
+	sort.Slice(grants.Items, func(i, j int) bool { return grants.Items[i].GetName() < grants.Items[j].GetName() })
+
+	var reasons []string
+	for i, g := range grants.Items {
+		if g.Spec.BucketRef.Name != cr.Spec.BucketRef.Name {
+			continue
+		}
+		if reason := g.Allows(*cr); reason != "" {
+			reasons = append(reasons, fmt.Sprintf("%v: %v", g.GetName(), reason))
+			continue
+		}
+		return &grants.Items[i], "", nil
+	}
+	if len(reasons) == 0 {
+		return nil, fmt.Sprintf("no BucketAccessGrant in namespace %v for bucket %v", cr.Spec.BucketRef.Namespace, cr.Spec.BucketRef.Name), nil
+	}
+	return nil, strings.Join(reasons, ", "), nil
+}
+
+// issueCredentials creates the user, puts the bucket policy allowed by the grant and keeps the access keys secret in sync
+func (r ReconcileBucketAccessRequest) issueCredentials(cr *v1beta1.BucketAccessRequest, bucket *v1beta1.S3, grant *v1beta1.BucketAccessGrant) error {
+	iamClient, errGettingClient := r.clients.IAM(bucket.GetRegion())
+	if errGettingClient != nil {
+		return errGettingClient
+	}
+
+	// record the user before creating it, so revoking and deleting always find it
+	username := cr.GetUsername()
+	if cr.Status.Username != username || cr.Status.Grant != grant.GetName() ||
+		cr.Status.BucketName != bucket.GetBucketName() || cr.Status.Region != bucket.GetRegion() {
+		cr.Status.Username = username
+		cr.Status.Grant = grant.GetName()
+		cr.Status.BucketName = bucket.GetBucketName()
+		cr.Status.Region = bucket.GetRegion()
+		if err := utils.UpdateCrStatus(cr, r.client); err != nil {
+			return err
+		}
+	}
+
+	if err := utils.CreateIAMUser(&iam.CreateUserInput{UserName: aws.String(username)}, cr.IAMUserOwner(), r.adoptUntagged, iamClient); err != nil {
+		return err
+	}
+
+	policyIn, errBuildingPolicy := cr.GetPolicyInput(*bucket, *grant)
+	if errBuildingPolicy != nil {
+		return errBuildingPolicy
+	}
+	if err := credentials.PutUserPolicyIfChanged(policyIn, iamClient); err != nil {
+		return err
+	}
+
+	// the secret tells consumers where the bucket is, it may live in another namespace
+	bucketData := map[string][]byte{
+		"BUCKET_NAME": []byte(cr.Status.BucketName),
+		"AWS_REGION":  []byte(cr.Status.Region),
+	}
+	return credentials.SyncAccessKeys(cr, username, cr.GetSecretName(), bucketData, iamClient, r.client, r.scheme)
+}
+
+// revoke deletes the credentials of a request no grant allows anymore, the request stays and is granted again
+// once a grant allows it
+func (r ReconcileBucketAccessRequest) revoke(cr *v1beta1.BucketAccessRequest, reason, msg string) error {
+	if cr.Status.Username == "" {
+		return setPending(reason, msg, cr, r.client)
+	}
+
+	r.recorder.Eventf(cr, v1.EventTypeWarning, "REVOKED", "Deleting credentials: %v", msg)
+	if err := r.deleteCredentials(cr); err != nil {
+		return err
+	}
+	cr.Status.Username = ""
+	cr.Status.Grant = ""
+	return setRevoked(reason, msg, cr, r.client)
+}
+
+// deleteCredentials deletes the IAM user and the access keys secret
+func (r ReconcileBucketAccessRequest) deleteCredentials(cr *v1beta1.BucketAccessRequest) error {
+	if cr.Status.Username != "" {
+		iamClient, errGettingClient := r.clients.IAM(cr.Status.Region)
+		if errGettingClient != nil {
+			return errGettingClient
+		}
+		if err := credentials.DeleteIAMUser(cr, cr.Status.Username, cr.IAMUserOwner(), r.adoptUntagged, iamClient, r.recorder); err != nil {
+			return err
+		}
+	}
+
+	secret := &v1.Secret{ObjectMeta: metav1.ObjectMeta{Name: cr.GetSecretName(), Namespace: cr.GetNamespace()}}
+	if err := r.client.Delete(context.TODO(), secret); err != nil && !apierror.IsNotFound(err) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,54 @@
+package bucketaccess
+
+import (
+	"context"
+	"github.com/agill17/s3-operator/pkg/apis/agill/v1beta1"
+	"github.com/agill17/s3-operator/pkg/utils"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+func setPending(reason, msg string, cr *v1beta1.BucketAccessRequest, client client.Client) error {
+	return setNotReady(v1beta1.PHASE_PENDING, reason, msg, cr, client)
+}
+
+func setRevoked(reason, msg string, cr *v1beta1.BucketAccessRequest, client client.Client) error {
+	return setNotReady(v1beta1.PHASE_REVOKED, reason, msg, cr, client)
+}
+
+// setNotReady records why the request has no credentials, status is only updated when something changed
+func setNotReady(phase v1beta1.Phase, reason, msg string, cr *v1beta1.BucketAccessRequest, client client.Client) error {
+	conditionChanged := v1beta1.SetCondition(&cr.Status.Conditions, v1beta1.Condition{
+		Type:               v1beta1.CONDITION_READY,
+		Status:             v1.ConditionFalse,
+		ObservedGeneration: cr.GetGeneration(),
+		Reason:             reason,
+		Message:            msg,
+	})
+	if cr.Status.Phase != phase || conditionChanged {
+		cr.Status.Phase = phase
+		return utils.UpdateCrStatus(cr, client)
+	}
+	return nil
+}
+
+// requestsForBucket maps a bucket to the BucketAccessRequests for it, in any namespace
+func requestsForBucket(c client.Client, namespace, name string) []reconcile.Request {
+	accessRequests := &v1beta1.BucketAccessRequestList{}
+	if err := c.List(context.TODO(), accessRequests, client.MatchingField(BUCKET_REF_INDEX, bucketRefKey(namespace, name))); err != nil {
+		log.Error(err, "Failed to list BucketAccessRequests", "Bucket.Namespace", namespace, "Bucket.Name", name)
+		return nil
+	}
+	var requests []reconcile.Request
+	for _, a := range accessRequests.Items {
+		requests = append(requests, reconcile.Request{NamespacedName: types.NamespacedName{Name: a.GetName(), Namespace: a.GetNamespace()}})
+	}
+	return requests
+}
+
+// bucketRefKey is the BUCKET_REF_INDEX value of a bucket
+func bucketRefKey(namespace, name string) string {
+	return namespace + "/" + name
+}
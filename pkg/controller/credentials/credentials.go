@@ -0,0 +1,131 @@
+package credentials
+
+import (
+	"context"
+	"net/url"
+
+	customErrors "github.com/agill17/s3-operator/pkg/controller/errors"
+	"github.com/agill17/s3-operator/pkg/utils"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/iam"
+	"github.com/aws/aws-sdk-go/service/iam/iamiface"
+	v1 "k8s.io/api/core/v1"
+	apierror "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+// SyncAccessKeys keeps the access keys secret of an IAM user in sync with IAM, the secret is owned by owner and lives in its namespace.
+// extraData is added to the secret next to AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY, e.g: BUCKET_NAME
+//   - if secret is not found in namespace, create new access keys ( delete the rest of the access keys if any )
+//   - if secret is found, and access key does not match IAM access key, delete the secret and return ErrorIAMK8SSecretNeedsUpdate
+//     to force a requeue that creates fresh access keys
+func SyncAccessKeys(owner metav1.Object, username, secretName string, extraData map[string][]byte,
+	iamClient iamiface.IAMAPI, client client.Client, scheme *runtime.Scheme) error {
+	secret := &v1.Secret{}
+	err := client.Get(context.TODO(), types.NamespacedName{Name: secretName, Namespace: owner.GetNamespace()}, secret)
+	if err != nil {
+		if !apierror.IsNotFound(err) {
+			return err
+		}
+		// clean up access keys if any
+		if errDeletingAllAccessKeys := utils.DeleteAllAccessKeys(username, iamClient); errDeletingAllAccessKeys != nil {
+			return errDeletingAllAccessKeys
+		}
+		accessKeysOutput, errCreatingAccessKeys := utils.CreateAccessKeys(username, iamClient)
+		if errCreatingAccessKeys != nil {
+			return errCreatingAccessKeys
+		}
+		return createSecret(owner, secretName, *accessKeysOutput.AccessKey.AccessKeyId, *accessKeysOutput.AccessKey.SecretAccessKey,
+			extraData, client, scheme)
+	}
+
+	// if secret is found make sure access keys matches the one in IAM
+	accessKeyIdInAWS, errGettingKey := utils.GetAccessKeyForUser(username, iamClient)
+	if errGettingKey != nil {
+		return errGettingKey
+	}
+	if string(secret.Data["AWS_ACCESS_KEY_ID"]) != accessKeyIdInAWS {
+		// delete secret to re-initiate
+		if err := client.Delete(context.TODO(), secret); err != nil {
+			return err
+		}
+		return customErrors.ErrorIAMK8SSecretNeedsUpdate{Message: "AccessKeyId no longer matches with AWS"}
+	}
+	return nil
+}
+
+func createSecret(owner metav1.Object, secretName, accessKeyId, secretAccessKey string, extraData map[string][]byte,
+	client client.Client, scheme *runtime.Scheme) error {
+	secret := &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      secretName,
+			Namespace: owner.GetNamespace(),
+		},
+		Data: map[string][]byte{
+			"AWS_ACCESS_KEY_ID":     []byte(accessKeyId),
+			"AWS_SECRET_ACCESS_KEY": []byte(secretAccessKey),
+		},
+		Type: v1.SecretTypeOpaque,
+	}
+	for k, v := range extraData {
+		secret.Data[k] = v
+	}
+
+	_, err := controllerutil.CreateOrUpdate(context.TODO(), client, secret, func() error {
+		return controllerutil.SetControllerReference(owner, secret, scheme)
+	})
+	return err
+}
+
+// DeleteIAMUser deletes the user of owner, see utils.DeleteIAMUser. A user that was not created for owner is left behind
+// with a warning event on owner, so deleting the CR does not silently orphan the user and its keys.
+func DeleteIAMUser(owner runtime.Object, username string, iamOwner utils.IAMUserOwner, adoptUntagged bool,
+	iamClient iamiface.IAMAPI, recorder record.EventRecorder) error {
+	err := utils.DeleteIAMUser(username, iamOwner, adoptUntagged, iamClient)
+	if notOwned, ok := err.(utils.ErrorIAMUserNotOwned); ok {
+		recorder.Eventf(owner, v1.EventTypeWarning, "IAM_USER_NOT_DELETED", "Leaving IAM user %v and its access keys behind: %v", username, notOwned)
+		return nil
+	}
+	return err
+}
+
+// PutUserPolicyIfChanged puts the inline policy only when it is missing or differs from the one in IAM,
+// IAM write calls count against an account wide quota
+func PutUserPolicyIfChanged(inlinePolicyIn *iam.PutUserPolicyInput, iamClient iamiface.IAMAPI) error {
+	matches, errComparing := UserPolicyMatches(*inlinePolicyIn.PolicyDocument, *inlinePolicyIn.UserName, *inlinePolicyIn.PolicyName, iamClient)
+	if errComparing != nil && !isNoSuchEntity(errComparing) {
+		return errComparing
+	}
+	if matches {
+		return nil
+	}
+
+	_, errCreatingPolicy := iamClient.PutUserPolicy(inlinePolicyIn)
+	return errCreatingPolicy
+}
+
+// UserPolicyMatches compares the inline policy of the user in IAM with the desired policy document
+func UserPolicyMatches(desiredPolicyDocument, username, policyName string, iamClient iamiface.IAMAPI) (bool, error) {
+	currentPolicyInAWS, errGetting := iamClient.GetUserPolicy(&iam.GetUserPolicyInput{
+		PolicyName: &policyName,
+		UserName:   &username,
+	})
+	if errGetting != nil {
+		return false, errGetting
+	}
+	currentPolicyDocInAws, err := url.QueryUnescape(*currentPolicyInAWS.PolicyDocument)
+	if err != nil {
+		return false, err
+	}
+	return desiredPolicyDocument == currentPolicyDocInAws, nil
+}
+
+func isNoSuchEntity(err error) bool {
+	awsErr, ok := err.(awserr.Error)
+	return ok && awsErr.Code() == iam.ErrCodeNoSuchEntityException
+}
@@ -0,0 +1,88 @@
+package customErrors
+
+import (
+	"github.com/agill17/s3-operator/pkg/utils"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+type ErrorClass string
+
+const (
+	// retrying cannot fix it, the CR spec ( or something outside the operator ) has to change
+	ErrorClassTerminal ErrorClass = "Terminal"
+	// expected to go away on its own, retried with back-off
+	ErrorClassTransient ErrorClass = "Transient"
+)
+
+// AWS error codes that will fail the same way on every retry
+var terminalAWSCodes = map[string]struct{}{
+	// s3
+	"AccessDenied":                        {},
+	"InvalidBucketName":                   {},
+	"InvalidArgument":                     {},
+	"InvalidRequest":                      {},
+	"InvalidLocationConstraint":           {},
+	"IllegalLocationConstraintException":  {},
+	"MalformedPolicy":                     {},
+	"MalformedXML":                        {},
+	"TooManyBuckets":                      {},
+	"InvalidBucketAclWithObjectOwnership": {},
+	// iam
+	"MalformedPolicyDocument": {},
+	"ValidationError":         {},
+	"InvalidInput":            {},
+	"LimitExceeded":           {},
+	// request validation done by the sdk before anything is sent
+	"InvalidParameter": {},
+}
+
+// ErrorTerminal is returned for problems the operator detects itself and that retrying cannot fix
+type ErrorTerminal struct {
+	Reason  string
+	Message string
+}
+
+func (e ErrorTerminal) Error() string {
+	return e.Message
+}
+
+// Classify tells terminal errors apart from transient ones, unknown errors are treated as transient
+func Classify(err error) ErrorClass {
+	if _, ok := err.(ErrorTerminal); ok {
+		return ErrorClassTerminal
+	}
+	// another CR, tenant or human owns the user, only picking another username helps
+	if _, ok := err.(utils.ErrorIAMUserNotOwned); ok {
+		return ErrorClassTerminal
+	}
+	if _, isTerminal := terminalAWSCodes[Code(err)]; isTerminal {
+		return ErrorClassTerminal
+	}
+	return ErrorClassTransient
+}
+
+// Code returns a short, low cardinality identifier for the error, used as reason and metric label
+func Code(err error) string {
+	switch e := err.(type) {
+	case ErrorTerminal:
+		return e.Reason
+	case awserr.Error:
+		return e.Code()
+	case ErrorIAMK8SSecretNeedsUpdate:
+		return "IAMK8SSecretNeedsUpdate"
+	case ErrorIAMInlinePolicyNeedsUpdate:
+		return "IAMInlinePolicyNeedsUpdate"
+	case ErrorGeneratedBucketNameTaken:
+		return "GeneratedBucketNameTaken"
+	case ErrorIAMUserNotReady:
+		return "IAMUserNotReady"
+	case utils.ErrorIAMUserNotOwned:
+		return "UsernameTaken"
+	}
+	if reason := apierrors.ReasonForError(err); reason != metav1.StatusReasonUnknown {
+		return string(reason)
+	}
+	return "Unknown"
+}
@@ -0,0 +1,92 @@
+package customErrors
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/agill17/s3-operator/pkg/utils"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func TestClassify(t *testing.T) {
+	tests := []struct {
+		name      string
+		err       error
+		wantClass ErrorClass
+		wantCode  string
+	}{
+		{
+			name:      "operator detected terminal error",
+			err:       ErrorTerminal{Reason: "BucketNameTaken", Message: "taken"},
+			wantClass: ErrorClassTerminal,
+			wantCode:  "BucketNameTaken",
+		},
+		{
+			name:      "iam user owned by someone else",
+			err:       utils.ErrorIAMUserNotOwned{Username: "team-a.bucket.s3"},
+			wantClass: ErrorClassTerminal,
+			wantCode:  "UsernameTaken",
+		},
+		{
+			name:      "s3 access denied",
+			err:       awserr.NewRequestFailure(awserr.New("AccessDenied", "Access Denied", nil), http.StatusForbidden, "req"),
+			wantClass: ErrorClassTerminal,
+			wantCode:  "AccessDenied",
+		},
+		{
+			name:      "malformed iam policy",
+			err:       awserr.New("MalformedPolicyDocument", "bad policy", nil),
+			wantClass: ErrorClassTerminal,
+			wantCode:  "MalformedPolicyDocument",
+		},
+		{
+			name:      "throttled",
+			err:       awserr.New("Throttling", "Rate exceeded", nil),
+			wantClass: ErrorClassTransient,
+			wantCode:  "Throttling",
+		},
+		{
+			name:      "s3 internal error",
+			err:       awserr.NewRequestFailure(awserr.New("InternalError", "", nil), http.StatusInternalServerError, "req"),
+			wantClass: ErrorClassTransient,
+			wantCode:  "InternalError",
+		},
+		{
+			name:      "secret needs update",
+			err:       ErrorIAMK8SSecretNeedsUpdate{},
+			wantClass: ErrorClassTransient,
+			wantCode:  "IAMK8SSecretNeedsUpdate",
+		},
+		{
+			name:      "generated bucket name taken",
+			err:       ErrorGeneratedBucketNameTaken{Message: "taken"},
+			wantClass: ErrorClassTransient,
+			wantCode:  "GeneratedBucketNameTaken",
+		},
+		{
+			name:      "kubernetes conflict",
+			err:       apierrors.NewConflict(schema.GroupResource{Group: "agill.apps", Resource: "s3s"}, "bucket", errors.New("modified")),
+			wantClass: ErrorClassTransient,
+			wantCode:  "Conflict",
+		},
+		{
+			name:      "unknown error",
+			err:       errors.New("connection reset by peer"),
+			wantClass: ErrorClassTransient,
+			wantCode:  "Unknown",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Classify(tt.err); got != tt.wantClass {
+				t.Errorf("Classify(%v) = %v, want %v", tt.err, got, tt.wantClass)
+			}
+			if got := Code(tt.err); got != tt.wantCode {
+				t.Errorf("Code(%v) = %v, want %v", tt.err, got, tt.wantCode)
+			}
+		})
+	}
+}
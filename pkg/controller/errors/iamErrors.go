@@ -15,3 +15,12 @@ func (e ErrorIAMK8SSecretNeedsUpdate) Error() string {
 func (e ErrorIAMInlinePolicyNeedsUpdate) Error() string {
 	return e.Message
 }
+
+// ErrorIAMUserNotReady is returned while a referenced IAMUser CR has no user yet
+type ErrorIAMUserNotReady struct {
+	Message string
+}
+
+func (e ErrorIAMUserNotReady) Error() string {
+	return e.Message
+}
@@ -0,0 +1,9 @@
+package customErrors
+
+type ErrorGeneratedBucketNameTaken struct {
+	Message string
+}
+
+func (e ErrorGeneratedBucketNameTaken) Error() string {
+	return e.Message
+}
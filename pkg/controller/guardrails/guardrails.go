@@ -0,0 +1,56 @@
+package guardrails
+
+import (
+	"context"
+	agillv1beta1 "github.com/agill17/s3-operator/pkg/apis/agill/v1beta1"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// Selecting returns the S3Guardrails that apply to the namespace
+func Selecting(c client.Client, namespace string) ([]agillv1beta1.S3Guardrail, error) {
+	guardrailList := &agillv1beta1.S3GuardrailList{}
+	if err := c.List(context.TODO(), guardrailList); err != nil {
+		return nil, err
+	}
+	if len(guardrailList.Items) == 0 {
+		return nil, nil
+	}
+
+	ns := &v1.Namespace{}
+	if err := c.Get(context.TODO(), types.NamespacedName{Name: namespace}, ns); err != nil {
+		return nil, err
+	}
+	var selecting []agillv1beta1.S3Guardrail
+	for _, g := range guardrailList.Items {
+		selected, err := g.Selects(ns.GetLabels())
+		if err != nil {
+			return nil, err
+		}
+		if selected {
+			selecting = append(selecting, g)
+		}
+	}
+	return selecting, nil
+}
+
+// Violations lists the violations of every guardrail, see S3Guardrail.Violations
+func Violations(guardrails []agillv1beta1.S3Guardrail, s agillv1beta1.S3, bucketPolicy string) field.ErrorList {
+	allErrs := field.ErrorList{}
+	for _, g := range guardrails {
+		allErrs = append(allErrs, g.Violations(s, bucketPolicy)...)
+	}
+	return allErrs
+}
+
+// RequireEncryption is true when any guardrail forces the baseline encryption statement
+func RequireEncryption(guardrails []agillv1beta1.S3Guardrail) bool {
+	for _, g := range guardrails {
+		if g.Spec.RequireEncryption {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,178 @@
+package guardrails
+
+import (
+	"reflect"
+	"testing"
+
+	agillv1beta1 "github.com/agill17/s3-operator/pkg/apis/agill/v1beta1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+const publicPolicy = `{"Statement":[{"Effect":"Allow","Principal":"*","Action":"s3:GetObject","Resource":"arn:aws:s3:::team-a-logs/*"}]}`
+
+func guardrail(name string, spec agillv1beta1.S3GuardrailSpec) agillv1beta1.S3Guardrail {
+	return agillv1beta1.S3Guardrail{ObjectMeta: metav1.ObjectMeta{Name: name}, Spec: spec}
+}
+
+func bucket(mutate func(s *agillv1beta1.S3)) agillv1beta1.S3 {
+	s := agillv1beta1.S3{
+		ObjectMeta: metav1.ObjectMeta{Name: "logs", Namespace: "team-a", UID: "uid"},
+		Spec: agillv1beta1.S3Spec{
+			Region:     "eu-west-1",
+			BucketName: "team-a-logs",
+			BucketACL:  "private",
+		},
+	}
+	mutate(&s)
+	return s
+}
+
+func TestViolations(t *testing.T) {
+	restrictive := guardrail("restrictive", agillv1beta1.S3GuardrailSpec{
+		ForbiddenBucketACLs: []string{"public-read", "public-read-write"},
+		AllowedRegions:      []string{"eu-west-1", "eu-central-1"},
+		BucketNamePrefix:    "{namespace}-",
+		DenyPublicPrincipal: true,
+	})
+	tests := []struct {
+		name         string
+		guardrails   []agillv1beta1.S3Guardrail
+		s3           agillv1beta1.S3
+		bucketPolicy string
+		wantFields   []string
+	}{
+		{name: "no guardrails", s3: bucket(func(s *agillv1beta1.S3) { s.Spec.BucketACL = "public-read" }), bucketPolicy: publicPolicy},
+		{name: "compliant", guardrails: []agillv1beta1.S3Guardrail{restrictive}, s3: bucket(func(s *agillv1beta1.S3) {})},
+		{
+			name:       "forbidden acl",
+			guardrails: []agillv1beta1.S3Guardrail{restrictive},
+			s3:         bucket(func(s *agillv1beta1.S3) { s.Spec.BucketACL = "public-read-write" }),
+			wantFields: []string{"spec.bucketACL"},
+		},
+		{
+			name:       "region not allowed",
+			guardrails: []agillv1beta1.S3Guardrail{restrictive},
+			s3:         bucket(func(s *agillv1beta1.S3) { s.Spec.Region = "us-east-1" }),
+			wantFields: []string{"spec.region"},
+		},
+		{
+			// the region the bucket was created in counts, not spec.region
+			name:       "created in a region no longer allowed",
+			guardrails: []agillv1beta1.S3Guardrail{restrictive},
+			s3:         bucket(func(s *agillv1beta1.S3) { s.Status.Region = "us-east-1" }),
+			wantFields: []string{"spec.region"},
+		},
+		{
+			name:       "bucket name without the namespace prefix",
+			guardrails: []agillv1beta1.S3Guardrail{restrictive},
+			s3:         bucket(func(s *agillv1beta1.S3) { s.Spec.BucketName = "logs" }),
+			wantFields: []string{"spec.bucketName"},
+		},
+		{
+			name:       "generated name without the namespace prefix",
+			guardrails: []agillv1beta1.S3Guardrail{restrictive},
+			s3:         bucket(func(s *agillv1beta1.S3) { s.Spec.BucketName, s.Spec.BucketNamePrefix = "", "logs" }),
+			wantFields: []string{"spec.bucketNamePrefix"},
+		},
+		{
+			name:       "generated name with the namespace prefix",
+			guardrails: []agillv1beta1.S3Guardrail{restrictive},
+			s3:         bucket(func(s *agillv1beta1.S3) { s.Spec.BucketName, s.Spec.BucketNamePrefix = "", "team-a" }),
+		},
+		{
+			name:         "public principal",
+			guardrails:   []agillv1beta1.S3Guardrail{restrictive},
+			s3:           bucket(func(s *agillv1beta1.S3) {}),
+			bucketPolicy: publicPolicy,
+			wantFields:   []string{"spec.bucketPolicy"},
+		},
+		{
+			// reported by the bucket policy validation instead
+			name:         "malformed policy",
+			guardrails:   []agillv1beta1.S3Guardrail{restrictive},
+			s3:           bucket(func(s *agillv1beta1.S3) {}),
+			bucketPolicy: `{"Statement":`,
+		},
+		{
+			name: "every guardrail is checked",
+			guardrails: []agillv1beta1.S3Guardrail{
+				guardrail("acls", agillv1beta1.S3GuardrailSpec{ForbiddenBucketACLs: []string{"public-read"}}),
+				guardrail("regions", agillv1beta1.S3GuardrailSpec{AllowedRegions: []string{"eu-central-1"}}),
+				guardrail("public", agillv1beta1.S3GuardrailSpec{DenyPublicPrincipal: false}),
+			},
+			s3:           bucket(func(s *agillv1beta1.S3) { s.Spec.BucketACL = "public-read" }),
+			bucketPolicy: publicPolicy,
+			wantFields:   []string{"spec.bucketACL", "spec.region"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			errs := Violations(tt.guardrails, tt.s3, tt.bucketPolicy)
+			var fields []string
+			for _, err := range errs {
+				fields = append(fields, err.Field)
+				if err.Type != field.ErrorTypeForbidden && err.Type != field.ErrorTypeInvalid {
+					t.Errorf("Violations() error type = %v", err.Type)
+				}
+			}
+			if !reflect.DeepEqual(fields, tt.wantFields) {
+				t.Errorf("Violations() fields = %v, want %v, errors: %v", fields, tt.wantFields, errs)
+			}
+		})
+	}
+}
+
+func TestSelecting(t *testing.T) {
+	scheme := runtime.NewScheme()
+	if err := clientgoscheme.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	if err := agillv1beta1.SchemeBuilder.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+
+	everywhere := guardrail("everywhere", agillv1beta1.S3GuardrailSpec{})
+	production := guardrail("production", agillv1beta1.S3GuardrailSpec{
+		NamespaceSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"env": "production"}},
+		RequireEncryption: true,
+	})
+	objs := []runtime.Object{
+		&v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "team-a", Labels: map[string]string{"env": "production"}}},
+		&v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "team-b", Labels: map[string]string{"env": "dev"}}},
+		everywhere.DeepCopy(),
+		production.DeepCopy(),
+	}
+	c := fake.NewFakeClientWithScheme(scheme, objs...)
+
+	tests := []struct {
+		namespace             string
+		want                  []string
+		wantRequireEncryption bool
+	}{
+		{namespace: "team-a", want: []string{"everywhere", "production"}, wantRequireEncryption: true},
+		{namespace: "team-b", want: []string{"everywhere"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.namespace, func(t *testing.T) {
+			selecting, err := Selecting(c, tt.namespace)
+			if err != nil {
+				t.Fatalf("Selecting() error = %v", err)
+			}
+			var names []string
+			for _, g := range selecting {
+				names = append(names, g.GetName())
+			}
+			if !reflect.DeepEqual(names, tt.want) {
+				t.Errorf("Selecting() = %v, want %v", names, tt.want)
+			}
+			if got := RequireEncryption(selecting); got != tt.wantRequireEncryption {
+				t.Errorf("RequireEncryption() = %v, want %v", got, tt.wantRequireEncryption)
+			}
+		})
+	}
+}
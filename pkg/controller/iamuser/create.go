@@ -0,0 +1,153 @@
+package iamuser
+
+import (
+	"sort"
+
+	"github.com/agill17/s3-operator/pkg/apis/agill/v1beta1"
+	"github.com/agill17/s3-operator/pkg/controller/credentials"
+	"github.com/agill17/s3-operator/pkg/utils"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/iam"
+	"github.com/aws/aws-sdk-go/service/iam/iamiface"
+	v1 "k8s.io/api/core/v1"
+)
+
+// createUser creates or adopts the user, keeps its path in sync and records its name and ARN in status
+func (r ReconcileIAMUser) createUser(cr *v1beta1.IAMUser, iamClient iamiface.IAMAPI) error {
+	username := cr.GetUsername()
+	if errCreatingUser := utils.CreateIAMUser(cr.CreateIAMUserIn(), cr.IAMUserOwner(), r.adoptUntagged, iamClient); errCreatingUser != nil {
+		r.recorder.Eventf(cr, v1.EventTypeWarning, "FAILED", "Failed to create IAM user: %v", errCreatingUser)
+		return errCreatingUser
+	}
+
+	out, errGettingUser := iamClient.GetUser(&iam.GetUserInput{UserName: aws.String(username)})
+	if errGettingUser != nil {
+		return errGettingUser
+	}
+	if aws.StringValue(out.User.Path) != cr.GetPath() {
+		if _, errUpdatingPath := iamClient.UpdateUser(&iam.UpdateUserInput{
+			UserName: aws.String(username),
+			NewPath:  aws.String(cr.GetPath()),
+		}); errUpdatingPath != nil {
+			return errUpdatingPath
+		}
+	}
+
+	// the ARN contains the path, it is re-read on the next reconcile after a path change
+	if cr.Status.Username != username || cr.Status.ARN != aws.StringValue(out.User.Arn) {
+		cr.Status.Username = username
+		cr.Status.ARN = aws.StringValue(out.User.Arn)
+		return utils.UpdateCrStatus(cr, r.client)
+	}
+	return nil
+}
+
+// syncPolicies attaches and puts the policies in spec, and removes the ones this CR added before that are no longer in spec.
+// Policies added to the user outside of this CR are left alone. The current policies are listed first and only the difference
+// is written, IAM write calls count against an account wide quota.
+func (r ReconcileIAMUser) syncPolicies(cr *v1beta1.IAMUser, iamClient iamiface.IAMAPI) error {
+	username := cr.GetUsername()
+
+	attached := map[string]bool{}
+	if err := iamClient.ListAttachedUserPoliciesPages(&iam.ListAttachedUserPoliciesInput{UserName: aws.String(username)},
+		func(page *iam.ListAttachedUserPoliciesOutput, lastPage bool) bool {
+			for _, p := range page.AttachedPolicies {
+				attached[aws.StringValue(p.PolicyArn)] = true
+			}
+			return true
+		}); err != nil {
+		return err
+	}
+
+	desiredManaged := map[string]bool{}
+	for _, arn := range cr.Spec.ManagedPolicyARNs {
+		desiredManaged[arn] = true
+		if attached[arn] {
+			continue
+		}
+		if _, err := iamClient.AttachUserPolicy(&iam.AttachUserPolicyInput{
+			PolicyArn: aws.String(arn),
+			UserName:  aws.String(username),
+		}); err != nil {
+			return err
+		}
+	}
+	for _, arn := range cr.Status.ManagedPolicyARNs {
+		if desiredManaged[arn] || !attached[arn] {
+			continue
+		}
+		_, err := iamClient.DetachUserPolicy(&iam.DetachUserPolicyInput{
+			PolicyArn: aws.String(arn),
+			UserName:  aws.String(username),
+		})
+		if err != nil && !isNoSuchEntity(err) {
+			return err
+		}
+	}
+
+	for policyName, policyDoc := range cr.Spec.InlinePolicies {
+		if err := credentials.PutUserPolicyIfChanged(&iam.PutUserPolicyInput{
+			PolicyDocument: aws.String(policyDoc),
+			PolicyName:     aws.String(policyName),
+			UserName:       aws.String(username),
+		}, iamClient); err != nil {
+			return err
+		}
+	}
+	if len(cr.Status.InlinePolicies) > 0 {
+		inlineInAWS := map[string]bool{}
+		if err := iamClient.ListUserPoliciesPages(&iam.ListUserPoliciesInput{UserName: aws.String(username)},
+			func(page *iam.ListUserPoliciesOutput, lastPage bool) bool {
+				for _, name := range page.PolicyNames {
+					inlineInAWS[aws.StringValue(name)] = true
+				}
+				return true
+			}); err != nil {
+			return err
+		}
+		for _, policyName := range cr.Status.InlinePolicies {
+			if _, found := cr.Spec.InlinePolicies[policyName]; found || !inlineInAWS[policyName] {
+				continue
+			}
+			if err := utils.DeleteIAMInlinePolicyFromUser(policyName, username, iamClient); err != nil && !isNoSuchEntity(err) {
+				return err
+			}
+		}
+	}
+
+	managed := make([]string, 0, len(desiredManaged))
+	for arn := range desiredManaged {
+		managed = append(managed, arn)
+	}
+	sort.Strings(managed)
+	inline := make([]string, 0, len(cr.Spec.InlinePolicies))
+	for policyName := range cr.Spec.InlinePolicies {
+		inline = append(inline, policyName)
+	}
+	sort.Strings(inline)
+
+	if !stringSlicesEqual(managed, cr.Status.ManagedPolicyARNs) || !stringSlicesEqual(inline, cr.Status.InlinePolicies) {
+		cr.Status.ManagedPolicyARNs = managed
+		cr.Status.InlinePolicies = inline
+		return utils.UpdateCrStatus(cr, r.client)
+	}
+	return nil
+}
+
+func isNoSuchEntity(err error) bool {
+	awsErr, ok := err.(awserr.Error)
+	return ok && awsErr.Code() == iam.ErrCodeNoSuchEntityException
+}
+
+func stringSlicesEqual(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
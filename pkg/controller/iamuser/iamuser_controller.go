@@ -0,0 +1,186 @@
+package iamuser
+
+import (
+	"context"
+	"fmt"
+	agillv1beta1 "github.com/agill17/s3-operator/pkg/apis/agill/v1beta1"
+	"github.com/agill17/s3-operator/pkg/controller/credentials"
+	customErrors "github.com/agill17/s3-operator/pkg/controller/errors"
+	"github.com/agill17/s3-operator/pkg/controller/options"
+	"github.com/agill17/s3-operator/pkg/controller/quotas"
+	"github.com/agill17/s3-operator/pkg/controller/status"
+	"github.com/agill17/s3-operator/pkg/utils"
+	"github.com/go-logr/logr"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+	"strings"
+)
+
+const IAMUSER_CONTROLLER = "iamUserController"
+
+var log = logf.Log.WithName("controller_iamuser")
+
+// Add creates a new IAMUser Controller and adds it to the Manager. The Manager will set fields on the Controller
+// and Start it when the Manager is Started.
+func Add(mgr manager.Manager, opts options.Options) error {
+	return add(mgr, newReconciler(mgr, opts), opts)
+}
+
+// newReconciler returns a new reconcile.Reconciler
+func newReconciler(mgr manager.Manager, opts options.Options) reconcile.Reconciler {
+	return &ReconcileIAMUser{
+		client:   mgr.GetClient(),
+		scheme:   mgr.GetScheme(),
+		recorder: mgr.GetEventRecorderFor(IAMUSER_CONTROLLER),
+		clients:  opts.Clients,
+
+		adoptUntagged: opts.AdoptUntaggedIAMUsers,
+	}
+}
+
+// add adds a new Controller to mgr with r as the reconcile.Reconciler
+func add(mgr manager.Manager, r reconcile.Reconciler, opts options.Options) error {
+	c, err := controller.New("iamuser-controller", mgr, controller.Options{
+		Reconciler:              r,
+		MaxConcurrentReconciles: opts.MaxConcurrentReconciles,
+	})
+	if err != nil {
+		return err
+	}
+
+	// Watch for changes to primary resource IAMUser
+	err = c.Watch(&source.Kind{Type: &agillv1beta1.IAMUser{}}, &handler.EnqueueRequestForObject{})
+	if err != nil {
+		return err
+	}
+
+	// watch the access keys secret
+	err = c.Watch(&source.Kind{Type: &v1.Secret{}}, &handler.EnqueueRequestForOwner{
+		OwnerType:    &agillv1beta1.IAMUser{},
+		IsController: true,
+	})
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// blank assignment to verify that ReconcileIAMUser implements reconcile.Reconciler
+var _ reconcile.Reconciler = &ReconcileIAMUser{}
+
+// ReconcileIAMUser reconciles a IAMUser object
+// Reconciles run concurrently, anything specific to a single CR must stay local to Reconcile
+type ReconcileIAMUser struct {
+	client   client.Client
+	scheme   *runtime.Scheme
+	recorder record.EventRecorder
+	clients  *utils.ClientPool
+
+	// existing users without owner tags are adopted, see utils.IAMUserOwner
+	adoptUntagged bool
+}
+
+// Reconcile reads that state of the cluster for a IAMUser object and makes changes based on the state read
+// and what is in the IAMUser.Spec
+func (r *ReconcileIAMUser) Reconcile(request reconcile.Request) (reconcile.Result, error) {
+	reqLogger := log.WithValues("Request.Namespace", request.Namespace, "Request.Name", request.Name)
+	reqLogger.Info("Reconciling IAMUser")
+
+	cr := &agillv1beta1.IAMUser{}
+	err := r.client.Get(context.TODO(), request.NamespacedName, cr)
+	if err != nil {
+		if errors.IsNotFound(err) {
+			return reconcile.Result{}, nil
+		}
+		return reconcile.Result{}, err
+	}
+
+	// terminal errors are not retried until the spec changes or the stall expires, deletes are always retried
+	if cr.GetDeletionTimestamp() == nil && cr.IsStalled() {
+		retryAfter := agillv1beta1.StalledRetryAfter(cr.Status.Conditions, cr.GetGeneration())
+		reqLogger.Info("CR stalled on a terminal error, waiting for a spec change", "retryAfter", retryAfter.String())
+		return reconcile.Result{RequeueAfter: retryAfter}, nil
+	}
+
+	result, err := r.reconcile(cr, reqLogger)
+	return status.HandleReconcileError(IAMUSER_CONTROLLER, cr, result, err, r.client, r.recorder, reqLogger)
+}
+
+func (r *ReconcileIAMUser) reconcile(cr *agillv1beta1.IAMUser, reqLogger logr.Logger) (reconcile.Result, error) {
+	if errAddingFinalizer := utils.AddFinalizer(utils.IAM_FINALIZER, r.client, cr); errAddingFinalizer != nil {
+		reqLogger.Error(errAddingFinalizer, "Failed to add iam finalizer, requeue with exponential back-off")
+		return reconcile.Result{}, errAddingFinalizer
+	}
+
+	iamClient, errGettingClient := r.clients.IAM(cr.GetRegion())
+	if errGettingClient != nil {
+		return reconcile.Result{}, errGettingClient
+	}
+
+	// handle delete, buckets referencing this user lose access along with it
+	if cr.GetDeletionTimestamp() != nil {
+		if errSettingStatus := status.SetPhase(agillv1beta1.PHASE_DELETING, cr, r.client); errSettingStatus != nil {
+			return reconcile.Result{}, errSettingStatus
+		}
+		if errDeletingUser := credentials.DeleteIAMUser(cr, cr.GetUsername(), cr.IAMUserOwner(), r.adoptUntagged, iamClient, r.recorder); errDeletingUser != nil {
+			return reconcile.Result{}, errDeletingUser
+		}
+		if errRemovingFinalizers := utils.RemoveFinalizer(utils.IAM_FINALIZER, cr, r.client); errRemovingFinalizers != nil {
+			reqLogger.Error(errRemovingFinalizers, "Failed to remove iam finalizer, retrying..")
+			return reconcile.Result{}, errRemovingFinalizers
+		}
+
+		// do not requeue
+		return reconcile.Result{}, nil
+	}
+
+	// renaming would orphan the recorded user and every bucket policy put on it
+	if changed := cr.ChangedImmutableFields(); len(changed) > 0 {
+		return reconcile.Result{}, customErrors.ErrorTerminal{
+			Reason: "ImmutableFieldChanged",
+			Message: fmt.Sprintf("%v changed after the user was created, revert to the values in status or create a new CR",
+				strings.Join(changed, ", ")),
+		}
+	}
+
+	// checked again periodically, deleting other CRs of the namespace frees quota without touching this one
+	if blocked, errCheckingQuota := quotas.Enforce(r.client, r.recorder, cr); errCheckingQuota != nil || blocked {
+		return reconcile.Result{RequeueAfter: utils.DEFAULT_QUOTA_REQUEUE_AFTER}, errCheckingQuota
+	}
+
+	if errCreatingUser := r.createUser(cr, iamClient); errCreatingUser != nil {
+		return reconcile.Result{}, errCreatingUser
+	}
+
+	// the owner tags are kept next to the spec tags, they are what lets this CR manage the user
+	if errSyncingTags := utils.SyncIAMUserTags(cr.GetUsername(), cr.IAMUserOwner().MergeTags(cr.Spec.Tags), iamClient); errSyncingTags != nil {
+		return reconcile.Result{}, errSyncingTags
+	}
+
+	if errSyncingPolicies := r.syncPolicies(cr, iamClient); errSyncingPolicies != nil {
+		return reconcile.Result{}, errSyncingPolicies
+	}
+
+	if errHandlingKeys := credentials.SyncAccessKeys(cr, cr.GetUsername(), cr.GetSecretName(), nil, iamClient, r.client, r.scheme); errHandlingKeys != nil {
+		if _, ok := errHandlingKeys.(customErrors.ErrorIAMK8SSecretNeedsUpdate); ok {
+			return reconcile.Result{Requeue: true}, nil
+		}
+		return reconcile.Result{}, errHandlingKeys
+	}
+
+	if errSettingStatus := status.SetPhase(agillv1beta1.PHASE_READY, cr, r.client); errSettingStatus != nil {
+		return reconcile.Result{}, errSettingStatus
+	}
+
+	return reconcile.Result{}, nil
+}
@@ -0,0 +1,49 @@
+package options
+
+import (
+	"time"
+
+	"github.com/agill17/s3-operator/pkg/apis/agill/v1beta1"
+	"github.com/agill17/s3-operator/pkg/utils"
+)
+
+// Options are operator wide settings shared by all controllers
+type Options struct {
+	// number of CRs a controller reconciles in parallel
+	MaxConcurrentReconciles int
+
+	// AWS clients shared by all reconciles
+	Clients *utils.ClientPool
+
+	// operator wide values for unset S3 spec fields, namespace annotations take precedence
+	S3Defaults S3Defaults
+
+	// run Access Analyzer ValidatePolicy on bucket and user policies before applying them
+	ValidatePolicies bool
+
+	// statements added to every bucket policy, namespace annotations take precedence
+	BaselinePolicy v1beta1.BaselinePolicy
+
+	// public buckets are only reconciled once a BucketApproval references their spec hash
+	RequirePublicBucketApproval bool
+
+	// how often the security posture scanner checks every bucket, 0 disables it
+	ScanInterval time.Duration
+
+	// active access keys older than this are reported by the scanner
+	MaxAccessKeyAge time.Duration
+
+	// principals that keep access to locked down buckets, the operator itself always does
+	LockdownAdminARNs []string
+
+	// existing IAM users without owner tags are adopted and tagged, only meant for upgrades from untagged versions
+	AdoptUntaggedIAMUsers bool
+}
+
+// S3Defaults are applied by the defaulting webhook, empty / false values leave the field unset
+type S3Defaults struct {
+	Region                     string
+	BucketACL                  string
+	EnableVersioning           bool
+	EnableTransferAcceleration bool
+}
@@ -0,0 +1,172 @@
+package quotas
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	agillv1beta1 "github.com/agill17/s3-operator/pkg/apis/agill/v1beta1"
+	"github.com/agill17/s3-operator/pkg/controller/status"
+	"github.com/agill17/s3-operator/pkg/utils"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// Object is a CR that counts against the quota of its namespace
+type Object interface {
+	status.Object
+	QuotaUsage() agillv1beta1.QuotaUsage
+	ProvisionedQuotaUsage() agillv1beta1.QuotaUsage
+}
+
+// ForNamespace returns the quota of the namespace, see NamespaceQuota
+func ForNamespace(c client.Client, namespace string) (agillv1beta1.NamespaceQuota, error) {
+	ns := &v1.Namespace{}
+	if err := c.Get(context.TODO(), types.NamespacedName{Name: namespace}, ns); err != nil {
+		return agillv1beta1.NamespaceQuota{}, err
+	}
+	return agillv1beta1.ParseNamespaceQuota(ns.GetAnnotations())
+}
+
+// Usage sums what the S3, IAMUser and BucketAccessRequest CRs of the namespace count against its quota.
+// CRs being deleted and CRs for which skip returns true are left out.
+func Usage(c client.Client, namespace string, skip func(metav1.Object) bool) (agillv1beta1.QuotaUsage, error) {
+	usage := agillv1beta1.QuotaUsage{}
+	counts := func(obj metav1.Object) bool {
+		return obj.GetDeletionTimestamp() == nil && !skip(obj)
+	}
+
+	s3s := &agillv1beta1.S3List{}
+	if err := c.List(context.TODO(), s3s, client.InNamespace(namespace)); err != nil {
+		return usage, err
+	}
+	for i := range s3s.Items {
+		if counts(&s3s.Items[i]) {
+			usage = usage.Add(s3s.Items[i].QuotaUsage())
+		}
+	}
+
+	iamUsers := &agillv1beta1.IAMUserList{}
+	if err := c.List(context.TODO(), iamUsers, client.InNamespace(namespace)); err != nil {
+		return usage, err
+	}
+	for i := range iamUsers.Items {
+		if counts(&iamUsers.Items[i]) {
+			usage = usage.Add(iamUsers.Items[i].QuotaUsage())
+		}
+	}
+
+	accessRequests := &agillv1beta1.BucketAccessRequestList{}
+	if err := c.List(context.TODO(), accessRequests, client.InNamespace(namespace)); err != nil {
+		return usage, err
+	}
+	for i := range accessRequests.Items {
+		if counts(&accessRequests.Items[i]) {
+			usage = usage.Add(accessRequests.Items[i].QuotaUsage())
+		}
+	}
+	return usage, nil
+}
+
+// Excluding skips the object itself, for admission where the existing object is replaced by the one being admitted.
+// Objects being created have no UID yet and are never in the list anyway.
+func Excluding(self metav1.Object) func(metav1.Object) bool {
+	return func(obj metav1.Object) bool {
+		return obj.GetUID() == self.GetUID()
+	}
+}
+
+// CreatedAfter skips the object itself and everything created after it, so of the CRs over quota the oldest
+// are reconciled first, the same way admission would have ordered them
+func CreatedAfter(self metav1.Object) func(metav1.Object) bool {
+	return func(obj metav1.Object) bool {
+		if obj.GetUID() == self.GetUID() {
+			return true
+		}
+		created, selfCreated := obj.GetCreationTimestamp(), self.GetCreationTimestamp()
+		if created.Equal(&selfCreated) {
+			return obj.GetUID() > self.GetUID()
+		}
+		return selfCreated.Before(&created)
+	}
+}
+
+// Check returns why adding requested to the namespace would exceed its quota, empty when it fits
+func Check(c client.Client, namespace string, requested agillv1beta1.QuotaUsage, skip func(metav1.Object) bool) (string, error) {
+	if requested.IsZero() {
+		return "", nil
+	}
+	quota, err := ForNamespace(c, namespace)
+	if err != nil || quota.IsEmpty() {
+		return "", err
+	}
+	used, err := Usage(c, namespace, skip)
+	if err != nil {
+		return "", err
+	}
+	exceeded := quota.Exceeded(used, requested)
+	if len(exceeded) == 0 {
+		return "", nil
+	}
+	return fmt.Sprintf("exceeded quota of namespace %v: %v", namespace, strings.Join(exceeded, "; ")), nil
+}
+
+// SetExceeded sets the QuotaExceeded condition and Ready to false, returns true when the message changed
+func SetExceeded(conditions *[]agillv1beta1.Condition, generation int64, msg string) bool {
+	previous := agillv1beta1.GetCondition(*conditions, agillv1beta1.CONDITION_QUOTA_EXCEEDED)
+	changed := previous == nil || previous.Status != v1.ConditionTrue || previous.Message != msg
+	agillv1beta1.SetCondition(conditions, agillv1beta1.Condition{
+		Type:               agillv1beta1.CONDITION_READY,
+		Status:             v1.ConditionFalse,
+		ObservedGeneration: generation,
+		Reason:             agillv1beta1.CONDITION_QUOTA_EXCEEDED,
+		Message:            msg,
+	})
+	agillv1beta1.SetCondition(conditions, agillv1beta1.Condition{
+		Type:               agillv1beta1.CONDITION_QUOTA_EXCEEDED,
+		Status:             v1.ConditionTrue,
+		ObservedGeneration: generation,
+		Reason:             "Exceeded",
+		Message:            msg,
+	})
+	return changed
+}
+
+// ClearExceeded flips a previously set QuotaExceeded condition to false, returns true when it changed
+func ClearExceeded(conditions *[]agillv1beta1.Condition, generation int64) bool {
+	if !agillv1beta1.IsConditionTrue(*conditions, agillv1beta1.CONDITION_QUOTA_EXCEEDED) {
+		return false
+	}
+	return agillv1beta1.SetCondition(conditions, agillv1beta1.Condition{
+		Type:               agillv1beta1.CONDITION_QUOTA_EXCEEDED,
+		Status:             v1.ConditionFalse,
+		ObservedGeneration: generation,
+		Reason:             "WithinQuota",
+	})
+}
+
+// Enforce blocks creating what the CR does not have yet when it would exceed the namespace quota.
+// Only what does not exist yet is checked, what was already created keeps being reconciled when a quota is lowered.
+// Returns true while the CR is blocked, the reason is in the QuotaExceeded condition then.
+func Enforce(c client.Client, recorder record.EventRecorder, cr Object) (bool, error) {
+	requested := cr.QuotaUsage().Sub(cr.ProvisionedQuotaUsage())
+	msg, err := Check(c, cr.GetNamespace(), requested, CreatedAfter(cr))
+	if err != nil {
+		return false, err
+	}
+	if msg == "" {
+		if ClearExceeded(cr.GetConditions(), cr.GetGeneration()) {
+			return false, utils.UpdateCrStatus(cr, c)
+		}
+		return false, nil
+	}
+
+	if SetExceeded(cr.GetConditions(), cr.GetGeneration(), msg) {
+		recorder.Eventf(cr, v1.EventTypeWarning, "QUOTA_EXCEEDED", "%v", msg)
+	}
+	cr.SetPhase(agillv1beta1.PHASE_QUOTA_EXCEEDED)
+	return true, utils.UpdateCrStatus(cr, c)
+}
@@ -0,0 +1,188 @@
+package quotas
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	agillv1beta1 "github.com/agill17/s3-operator/pkg/apis/agill/v1beta1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+var created = metav1.NewTime(time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC))
+
+func newClient(t *testing.T, objs ...runtime.Object) client.Client {
+	scheme := runtime.NewScheme()
+	if err := clientgoscheme.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	if err := agillv1beta1.SchemeBuilder.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	return fake.NewFakeClientWithScheme(scheme, objs...)
+}
+
+func namespace(annotations map[string]string) *v1.Namespace {
+	return &v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "team-a", Annotations: annotations}}
+}
+
+// s3 is a bucket with a user of its own, created once the status has a bucket name
+func s3(name string, age time.Duration, bucketName string) *agillv1beta1.S3 {
+	return &agillv1beta1.S3{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:              name,
+			Namespace:         "team-a",
+			UID:               types.UID(name),
+			CreationTimestamp: metav1.NewTime(created.Add(age)),
+		},
+		Spec:   agillv1beta1.S3Spec{Region: "us-east-1", BucketACL: "private", IAMUser: agillv1beta1.BucketIAMUser{Username: "team-a." + name + ".s3"}},
+		Status: agillv1beta1.S3Status{BucketName: bucketName},
+	}
+}
+
+func iamUser(name string) *agillv1beta1.IAMUser {
+	return &agillv1beta1.IAMUser{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "team-a", UID: types.UID(name), CreationTimestamp: created}}
+}
+
+func TestCheck(t *testing.T) {
+	deleting := s3("deleting", 0, "deleting-bucket")
+	now := metav1.Now()
+	deleting.DeletionTimestamp = &now
+
+	existing := []runtime.Object{s3("logs", 0, "logs-bucket"), iamUser("reader"), deleting}
+	tests := []struct {
+		name        string
+		annotations map[string]string
+		requested   agillv1beta1.QuotaUsage
+		skip        func(metav1.Object) bool
+		want        string
+	}{
+		{name: "no quota", requested: agillv1beta1.QuotaUsage{Buckets: 1, IAMUsers: 1}},
+		{
+			name:        "nothing requested",
+			annotations: map[string]string{"s3.agill.apps/quota-buckets": "0"},
+		},
+		{
+			// CRs being deleted free their quota already
+			name:        "fits",
+			annotations: map[string]string{"s3.agill.apps/quota-buckets": "2", "s3.agill.apps/quota-iam-users": "3"},
+			requested:   agillv1beta1.QuotaUsage{Buckets: 1, IAMUsers: 1},
+		},
+		{
+			name:        "buckets exceeded",
+			annotations: map[string]string{"s3.agill.apps/quota-buckets": "1"},
+			requested:   agillv1beta1.QuotaUsage{Buckets: 1, IAMUsers: 1},
+			want:        "exceeded quota of namespace team-a: buckets: used 1, requested 1, limited to 1",
+		},
+		{
+			name:        "iam users exceeded",
+			annotations: map[string]string{"s3.agill.apps/quota-iam-users": "2"},
+			requested:   agillv1beta1.QuotaUsage{IAMUsers: 1},
+			want:        "exceeded quota of namespace team-a: iam-users: used 2, requested 1, limited to 2",
+		},
+		{
+			// admission of an update replaces the existing object
+			name:        "excluding the object itself",
+			annotations: map[string]string{"s3.agill.apps/quota-buckets": "1"},
+			requested:   agillv1beta1.QuotaUsage{Buckets: 1},
+			skip:        Excluding(s3("logs", 0, "")),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newClient(t, append([]runtime.Object{namespace(tt.annotations)}, existing...)...)
+			skip := tt.skip
+			if skip == nil {
+				skip = func(metav1.Object) bool { return false }
+			}
+			got, err := Check(c, "team-a", tt.requested, skip)
+			if err != nil {
+				t.Fatalf("Check() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("Check() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckInvalidQuota(t *testing.T) {
+	c := newClient(t, namespace(map[string]string{"s3.agill.apps/quota-buckets": "many"}))
+	if _, err := Check(c, "team-a", agillv1beta1.QuotaUsage{Buckets: 1}, Excluding(iamUser("reader"))); err == nil {
+		t.Errorf("Check() error = nil, want the invalid annotation reported")
+	}
+}
+
+func TestCreatedAfter(t *testing.T) {
+	self := s3("b", 0, "")
+	tests := []struct {
+		name string
+		obj  metav1.Object
+		want bool
+	}{
+		{name: "itself", obj: s3("b", 0, ""), want: true},
+		{name: "older", obj: s3("x", -time.Minute, ""), want: false},
+		{name: "newer", obj: s3("a", time.Minute, ""), want: true},
+		// same second, the UID decides so two CRs never both count the other
+		{name: "same time, lower uid", obj: s3("a", 0, ""), want: false},
+		{name: "same time, higher uid", obj: s3("c", 0, ""), want: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := CreatedAfter(self)(tt.obj); got != tt.want {
+				t.Errorf("CreatedAfter() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEnforce(t *testing.T) {
+	older, cr := s3("logs", 0, "logs-bucket"), s3("metrics", time.Minute, "")
+	ns := namespace(map[string]string{"s3.agill.apps/quota-buckets": "1"})
+	c := newClient(t, ns, older, cr)
+	recorder := record.NewFakeRecorder(10)
+
+	blocked, err := Enforce(c, recorder, cr)
+	if err != nil || !blocked {
+		t.Fatalf("Enforce() = %v, %v, want blocked", blocked, err)
+	}
+	if cr.Status.Phase != agillv1beta1.PHASE_QUOTA_EXCEEDED || !agillv1beta1.IsConditionTrue(cr.Status.Conditions, agillv1beta1.CONDITION_QUOTA_EXCEEDED) {
+		t.Errorf("Enforce() phase = %v, conditions = %v", cr.Status.Phase, cr.Status.Conditions)
+	}
+	if event := <-recorder.Events; !strings.Contains(event, "QUOTA_EXCEEDED") {
+		t.Errorf("Enforce() event = %v", event)
+	}
+
+	// still blocked, the event is not repeated
+	if blocked, err = Enforce(c, recorder, cr); err != nil || !blocked {
+		t.Fatalf("Enforce() = %v, %v, want blocked", blocked, err)
+	}
+	if len(recorder.Events) != 0 {
+		t.Errorf("Enforce() repeated the event: %v", <-recorder.Events)
+	}
+
+	// only what does not exist yet is checked, the provisioned bucket is never blocked
+	if blocked, err = Enforce(c, recorder, older); err != nil || blocked {
+		t.Errorf("Enforce() = %v, %v for a provisioned bucket, want not blocked", blocked, err)
+	}
+
+	// the older bucket is deleted, the quota is free again
+	if err := c.Delete(context.TODO(), older); err != nil {
+		t.Fatal(err)
+	}
+	if blocked, err = Enforce(c, recorder, cr); err != nil || blocked {
+		t.Fatalf("Enforce() = %v, %v, want not blocked", blocked, err)
+	}
+	condition := agillv1beta1.GetCondition(cr.Status.Conditions, agillv1beta1.CONDITION_QUOTA_EXCEEDED)
+	if condition == nil || condition.Status != v1.ConditionFalse {
+		t.Errorf("Enforce() QuotaExceeded condition = %+v, want false", condition)
+	}
+}
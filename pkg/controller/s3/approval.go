@@ -0,0 +1,72 @@
+package s3
+
+import (
+	"context"
+	"fmt"
+	agillv1beta1 "github.com/agill17/s3-operator/pkg/apis/agill/v1beta1"
+	"github.com/agill17/s3-operator/pkg/utils"
+	v1 "k8s.io/api/core/v1"
+)
+
+// checkApproval keeps public buckets from being created or changed until a BucketApproval references the current spec hash.
+// Returns true while the CR awaits approval.
+func (r ReconcileS3) checkApproval(cr *agillv1beta1.S3, bucketPolicy string) (bool, error) {
+	if !r.requireApproval {
+		return false, nil
+	}
+
+	var err error
+	specHash := ""
+	if cr.NeedsApproval(bucketPolicy) {
+		if specHash, err = cr.ApprovalSpecHash(bucketPolicy); err != nil {
+			return false, err
+		}
+	}
+	if cr.Status.ApprovalSpecHash != specHash {
+		cr.Status.ApprovalSpecHash = specHash
+		if err := utils.UpdateCrStatus(cr, r.client); err != nil {
+			return false, err
+		}
+	}
+	if specHash == "" {
+		return false, nil
+	}
+
+	approved, err := r.isApproved(cr, specHash)
+	if err != nil || approved {
+		return false, err
+	}
+	return true, r.setAwaitingApproval(cr, specHash)
+}
+
+func (r ReconcileS3) isApproved(cr *agillv1beta1.S3, specHash string) (bool, error) {
+	approvals := &agillv1beta1.BucketApprovalList{}
+	if err := r.client.List(context.TODO(), approvals); err != nil {
+		return false, err
+	}
+	for _, a := range approvals.Items {
+		if a.Approves(*cr, specHash) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// setAwaitingApproval records the hash to approve, the event is only sent once per hash
+func (r ReconcileS3) setAwaitingApproval(cr *agillv1beta1.S3, specHash string) error {
+	msg := fmt.Sprintf("public bucket needs a BucketApproval for bucketRef %v/%v with specHash %v", cr.GetNamespace(), cr.GetName(), specHash)
+	ready := agillv1beta1.Condition{
+		Type:               agillv1beta1.CONDITION_READY,
+		Status:             v1.ConditionFalse,
+		ObservedGeneration: cr.GetGeneration(),
+		Reason:             string(agillv1beta1.PHASE_AWAITING_APPROVAL),
+		Message:            msg,
+	}
+	if cr.Status.Phase == agillv1beta1.PHASE_AWAITING_APPROVAL && !agillv1beta1.SetCondition(&cr.Status.Conditions, ready) {
+		return nil
+	}
+	r.recorder.Eventf(cr, v1.EventTypeNormal, "AWAITING_APPROVAL", "%v", msg)
+	cr.Status.Phase = agillv1beta1.PHASE_AWAITING_APPROVAL
+	agillv1beta1.SetCondition(&cr.Status.Conditions, ready)
+	return utils.UpdateCrStatus(cr, r.client)
+}
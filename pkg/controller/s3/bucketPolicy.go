@@ -0,0 +1,138 @@
+package s3
+
+import (
+	"context"
+	"fmt"
+	agillv1beta1 "github.com/agill17/s3-operator/pkg/apis/agill/v1beta1"
+	customErrors "github.com/agill17/s3-operator/pkg/controller/errors"
+	"github.com/agill17/s3-operator/pkg/controller/guardrails"
+	"github.com/agill17/s3-operator/pkg/utils"
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"strconv"
+)
+
+// desiredBucketPolicy renders bucketPolicy ( or the bucketPolicyFrom ConfigMap key ) and merges the generated statements into it
+func (r ReconcileS3) desiredBucketPolicy(cr *agillv1beta1.S3) (string, error) {
+	source, err := r.bucketPolicySource(cr)
+	if err != nil {
+		return "", err
+	}
+
+	rendered := source
+	if agillv1beta1.IsBucketPolicyTemplate(source) {
+		data, err := r.bucketPolicyTemplateData(cr)
+		if err != nil {
+			return "", err
+		}
+		if rendered, err = agillv1beta1.RenderBucketPolicy(source, data); err != nil {
+			r.recorder.Eventf(cr, v1.EventTypeWarning, "TEMPLATE_ERROR", "Failed to render bucket policy: %v", err)
+			return "", bucketPolicyErr(cr, "BucketPolicyTemplateError", fmt.Sprintf("bucket policy template cannot be rendered: %v", err))
+		}
+	}
+
+	baseline, err := r.baselinePolicy(cr)
+	if err != nil {
+		return "", err
+	}
+
+	operatorUserID, err := r.clients.PrincipalUserID(cr.GetRegion())
+	if err != nil {
+		return "", err
+	}
+
+	policy, err := cr.DesiredBucketPolicy(rendered, baseline, operatorUserID)
+	if err != nil {
+		return "", bucketPolicyErr(cr, "MalformedPolicy", fmt.Sprintf("bucket policy cannot be merged with the generated statements: %v", err))
+	}
+	return policy, nil
+}
+
+// bucketPolicySource returns spec.bucketPolicy or the referenced ConfigMap key, a missing optional ConfigMap or key means no policy
+func (r ReconcileS3) bucketPolicySource(cr *agillv1beta1.S3) (string, error) {
+	if cr.Spec.BucketPolicyFrom == nil {
+		return cr.Spec.BucketPolicy, nil
+	}
+
+	ref := cr.Spec.BucketPolicyFrom.ConfigMapKeyRef
+	optional := ref.Optional != nil && *ref.Optional
+	cm := &v1.ConfigMap{}
+	if err := r.client.Get(context.TODO(), types.NamespacedName{Name: ref.Name, Namespace: cr.GetNamespace()}, cm); err != nil {
+		if apierrors.IsNotFound(err) && optional {
+			return "", nil
+		}
+		return "", err
+	}
+	policy, found := cm.Data[ref.Key]
+	if !found && !optional {
+		return "", fmt.Errorf("key %v not found in ConfigMap %v", ref.Key, ref.Name)
+	}
+	return policy, nil
+}
+
+// baselinePolicy returns the operator baseline with the overrides from the CR namespace annotations
+func (r ReconcileS3) baselinePolicy(cr *agillv1beta1.S3) (agillv1beta1.BaselinePolicy, error) {
+	baseline := r.baseline
+	ns := &v1.Namespace{}
+	if err := r.client.Get(context.TODO(), types.NamespacedName{Name: cr.GetNamespace()}, ns); err != nil {
+		return baseline, err
+	}
+
+	for annotation, value := range map[string]*bool{
+		"deny-insecure-transport": &baseline.DenyInsecureTransport,
+		"require-encryption":      &baseline.RequireEncryption,
+	} {
+		v, found := ns.GetAnnotations()[utils.NAMESPACE_BASELINE_ANNOTATION_PREFIX+annotation]
+		if !found {
+			continue
+		}
+		parsed, err := strconv.ParseBool(v)
+		if err != nil {
+			// fixed on the namespace, not the CR, so retried with back-off
+			return baseline, fmt.Errorf("namespace annotation %v%v must be true or false, got %q",
+				utils.NAMESPACE_BASELINE_ANNOTATION_PREFIX, annotation, v)
+		}
+		*value = parsed
+	}
+
+	// guardrails win over the namespace annotations
+	selecting, err := guardrails.Selecting(r.client, cr.GetNamespace())
+	if err != nil {
+		return baseline, err
+	}
+	if guardrails.RequireEncryption(selecting) {
+		baseline.RequireEncryption = true
+	}
+	return baseline, nil
+}
+
+func (r ReconcileS3) bucketPolicyTemplateData(cr *agillv1beta1.S3) (agillv1beta1.BucketPolicyTemplateData, error) {
+	accountID, err := r.clients.AccountID(cr.GetRegion())
+	if err != nil {
+		return agillv1beta1.BucketPolicyTemplateData{}, err
+	}
+
+	iamUserARN := ""
+	switch {
+	case cr.UsesIAMUserRef():
+		// empty until the IAMUser CR created its user, the bucket user step waits for it anyway
+		iamUser, err := r.getIAMUserRef(cr)
+		if err != nil {
+			return agillv1beta1.BucketPolicyTemplateData{}, err
+		}
+		iamUserARN = iamUser.Status.ARN
+	case cr.Spec.IAMUser.Username != "":
+		iamUserARN = utils.IAMUserARN(cr.GetRegion(), accountID, cr.Spec.IAMUser.Username)
+	}
+	return cr.BucketPolicyTemplateData(accountID, iamUserARN), nil
+}
+
+// bucketPolicyErr is terminal for policies in the spec. Policies read from a ConfigMap are fixed without a spec change,
+// so they are retried with back-off instead.
+func bucketPolicyErr(cr *agillv1beta1.S3, reason, msg string) error {
+	if cr.Spec.BucketPolicyFrom != nil {
+		return fmt.Errorf("%v: %v", reason, msg)
+	}
+	return customErrors.ErrorTerminal{Reason: reason, Message: msg}
+}
@@ -0,0 +1,143 @@
+package s3
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+
+	"github.com/agill17/s3-operator/pkg/apis/agill/v1beta1"
+	"github.com/agill17/s3-operator/pkg/controller/credentials"
+	customErrors "github.com/agill17/s3-operator/pkg/controller/errors"
+	"github.com/agill17/s3-operator/pkg/utils"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/iam"
+	"github.com/aws/aws-sdk-go/service/iam/iamiface"
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// handleConsumers creates a user, a prefix scoped inline policy and an access keys secret per consumer,
+// then deletes the users and secrets of consumers that were removed from the spec
+func (r ReconcileS3) handleConsumers(cr *v1beta1.S3, iamClient iamiface.IAMAPI) error {
+	desired := desiredConsumerStatuses(cr)
+
+	// an explicit username may name any user of the account, e.g: a human admin. Only users created for this CR are
+	// tracked and taken over, anything else stalls the CR before its keys are rotated or it is deleted along with the consumer.
+	for _, c := range desired {
+		if _, err := utils.ClaimIAMUser(c.Username, cr.IAMUserOwner(), r.adoptUntagged, iamClient); err != nil {
+			if notOwned, ok := err.(utils.ErrorIAMUserNotOwned); ok {
+				return customErrors.ErrorTerminal{Reason: "UsernameTaken", Message: fmt.Sprintf("consumer %v: %v", c.Name, notOwned)}
+			}
+			return err
+		}
+	}
+
+	// track new consumers before creating anything, so one removed right after is still cleaned up
+	var tracked []v1beta1.ConsumerStatus
+	tracked = append(tracked, cr.Status.Consumers...)
+	for _, c := range desired {
+		if !containsConsumerStatus(tracked, c) {
+			tracked = append(tracked, c)
+		}
+	}
+	if !reflect.DeepEqual(tracked, cr.Status.Consumers) {
+		cr.Status.Consumers = tracked
+		if err := utils.UpdateCrStatus(cr, r.client); err != nil {
+			return err
+		}
+	}
+
+	for _, c := range cr.Spec.Consumers {
+		username := cr.GetConsumerUsername(c)
+		if err := utils.CreateIAMUser(&iam.CreateUserInput{UserName: aws.String(username)}, cr.IAMUserOwner(), r.adoptUntagged, iamClient); err != nil {
+			return err
+		}
+
+		policyIn, err := cr.GetConsumerPolicyInput(c)
+		if err != nil {
+			return err
+		}
+		if err := credentials.PutUserPolicyIfChanged(policyIn, iamClient); err != nil {
+			return err
+		}
+
+		if err := credentials.SyncAccessKeys(cr, username, cr.GetConsumerSecretName(c), nil, iamClient, r.client, r.scheme); err != nil {
+			return err
+		}
+	}
+
+	// a renamed user or secret replaces the old one
+	for _, t := range tracked {
+		if containsConsumerStatus(desired, t) {
+			continue
+		}
+		if !consumerUsernameInUse(desired, t.Username) {
+			r.recorder.Eventf(cr, v1.EventTypeNormal, "CONSUMER_REMOVED", "Deleting IAM user %v of consumer %v", t.Username, t.Name)
+			if err := credentials.DeleteIAMUser(cr, t.Username, cr.IAMUserOwner(), r.adoptUntagged, iamClient, r.recorder); err != nil {
+				return err
+			}
+		}
+		if !consumerSecretInUse(desired, t.SecretName) {
+			secret := &v1.Secret{ObjectMeta: metav1.ObjectMeta{Name: t.SecretName, Namespace: cr.GetNamespace()}}
+			if err := r.client.Delete(context.TODO(), secret); err != nil && !apierrors.IsNotFound(err) {
+				return err
+			}
+		}
+	}
+
+	if !reflect.DeepEqual(desired, cr.Status.Consumers) {
+		cr.Status.Consumers = desired
+		return utils.UpdateCrStatus(cr, r.client)
+	}
+	return nil
+}
+
+// deleteConsumerUsers deletes the users of every consumer in spec or status
+func (r ReconcileS3) deleteConsumerUsers(cr *v1beta1.S3, iamClient iamiface.IAMAPI) error {
+	for _, c := range append(desiredConsumerStatuses(cr), cr.Status.Consumers...) {
+		if err := credentials.DeleteIAMUser(cr, c.Username, cr.IAMUserOwner(), r.adoptUntagged, iamClient, r.recorder); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func desiredConsumerStatuses(cr *v1beta1.S3) []v1beta1.ConsumerStatus {
+	var statuses []v1beta1.ConsumerStatus
+	for _, c := range cr.Spec.Consumers {
+		statuses = append(statuses, v1beta1.ConsumerStatus{
+			Name:       c.Name,
+			Username:   cr.GetConsumerUsername(c),
+			SecretName: cr.GetConsumerSecretName(c),
+		})
+	}
+	return statuses
+}
+
+func containsConsumerStatus(statuses []v1beta1.ConsumerStatus, c v1beta1.ConsumerStatus) bool {
+	for _, s := range statuses {
+		if s == c {
+			return true
+		}
+	}
+	return false
+}
+
+func consumerUsernameInUse(statuses []v1beta1.ConsumerStatus, username string) bool {
+	for _, s := range statuses {
+		if s.Username == username {
+			return true
+		}
+	}
+	return false
+}
+
+func consumerSecretInUse(statuses []v1beta1.ConsumerStatus, secretName string) bool {
+	for _, s := range statuses {
+		if s.SecretName == secretName {
+			return true
+		}
+	}
+	return false
+}
@@ -1,8 +1,9 @@
 package s3
 
 import (
-	"context"
-	"github.com/agill17/s3-operator/pkg/apis/agill/v1alpha1"
+	"fmt"
+	"github.com/agill17/s3-operator/pkg/apis/agill/v1beta1"
+	"github.com/agill17/s3-operator/pkg/controller/credentials"
 	customErrors "github.com/agill17/s3-operator/pkg/controller/errors"
 	"github.com/agill17/s3-operator/pkg/utils"
 	"github.com/aws/aws-sdk-go/aws"
@@ -11,21 +12,31 @@ import (
 	"github.com/aws/aws-sdk-go/service/s3/s3iface"
 	"github.com/davecgh/go-spew/spew"
 	v1 "k8s.io/api/core/v1"
-	apierror "k8s.io/apimachinery/pkg/api/errors"
-	"k8s.io/apimachinery/pkg/runtime"
-	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
-func (r ReconcileS3) createBucket(cr *v1alpha1.S3) error {
-	exists, errGettingBucket := utils.BucketExists(cr.Spec.BucketName, r.s3Client)
+// createBucket creates or adopts the bucket and records its name in status.
+// Runs before anything else so the IAM policy is built for the final bucket name.
+func (r ReconcileS3) createBucket(cr *v1beta1.S3, s3Client s3iface.S3API) error {
+	bucketName := cr.GetBucketName()
+	ownership, errGettingBucket := utils.GetBucketOwnership(bucketName, s3Client)
 	if errGettingBucket != nil {
 		r.recorder.Eventf(cr, v1.EventTypeWarning, "FAILED", "Failed to get bucket from Cloud: %v", errGettingBucket)
 		return errGettingBucket
 	}
 
-	if !exists {
-		r.recorder.Eventf(cr, v1.EventTypeNormal, "CREATING", "Bucket does not exist, creating now...")
-		out, err := r.s3Client.CreateBucket(cr.CreateBucketIn())
+	switch ownership {
+	case utils.BUCKET_OWNED_BY_ANOTHER_ACCOUNT:
+		return r.handleBucketNameTaken(cr)
+	case utils.BUCKET_NOT_FOUND:
+		r.recorder.Eventf(cr, v1.EventTypeNormal, "CREATING", "Bucket %v does not exist, creating now...", bucketName)
+		out, err := s3Client.CreateBucket(cr.CreateBucketIn())
+		if utils.IsBucketAlreadyOwnedByYou(err) {
+			r.recorder.Eventf(cr, v1.EventTypeNormal, "CREATED", "S3 Bucket already exists and is owned by this account")
+			break
+		}
+		if utils.IsBucketAlreadyExists(err) {
+			return r.handleBucketNameTaken(cr)
+		}
 		if err != nil {
 			r.recorder.Eventf(cr, v1.EventTypeWarning, "FAILED", "Failed to create bucket: %v", err)
 			return err
@@ -34,29 +45,67 @@ func (r ReconcileS3) createBucket(cr *v1alpha1.S3) error {
 		r.recorder.Eventf(cr, v1.EventTypeNormal, "CREATED", "S3 Bucket created successfully")
 	}
 
-	if _, errPuttingBucketAcl := r.s3Client.PutBucketAcl(cr.PutBucketAclIn()); errPuttingBucketAcl != nil {
+	// from here on name, region and object lock never change, later reconciles and DeleteBucket use them
+	if cr.Status.BucketName != bucketName || cr.Status.Region == "" {
+		cr.Status.BucketName = bucketName
+		cr.Status.Region = cr.GetRegion()
+		cr.Status.ObjectLockEnabled = cr.Spec.ObjectLockEnabled
+		return utils.UpdateCrStatus(cr, r.client)
+	}
+	return nil
+}
+
+// handleBucketNameTaken moves generated names to the next attempt, user provided names are terminal
+func (r ReconcileS3) handleBucketNameTaken(cr *v1beta1.S3) error {
+	bucketName := cr.GetBucketName()
+	if !cr.HasGeneratedBucketName() || cr.Status.BucketName != "" {
+		return bucketNameTakenErr(bucketName)
+	}
+	if cr.Status.BucketNameAttempt+1 >= utils.MAX_GENERATED_BUCKET_NAME_ATTEMPTS {
+		return customErrors.ErrorTerminal{
+			Reason:  "BucketNameTaken",
+			Message: fmt.Sprintf("all %v generated bucket names were taken, set bucketName or a different bucketNamePrefix", utils.MAX_GENERATED_BUCKET_NAME_ATTEMPTS),
+		}
+	}
+
+	r.recorder.Eventf(cr, v1.EventTypeWarning, "NAME_TAKEN", "Generated bucket name %v is taken, generating a new one", bucketName)
+	cr.Status.BucketNameAttempt++
+	if err := utils.UpdateCrStatus(cr, r.client); err != nil {
+		return err
+	}
+	return customErrors.ErrorGeneratedBucketNameTaken{Message: fmt.Sprintf("generated bucket name %v is taken", bucketName)}
+}
+
+// configureBucket keeps the bucket properties in sync with the spec, bucketPolicy is the result of desiredBucketPolicy
+func (r ReconcileS3) configureBucket(cr *v1beta1.S3, bucketPolicy string, s3Client s3iface.S3API) error {
+	if _, errPuttingBucketAcl := s3Client.PutBucketAcl(cr.PutBucketAclIn()); errPuttingBucketAcl != nil {
 		return errPuttingBucketAcl
 	}
 
-	if _, errPuttingBucketVersionong := r.s3Client.PutBucketVersioning(cr.PutBucketVersioningIn()); errPuttingBucketVersionong != nil {
-		return errPuttingBucketVersionong
+	// nil inputs are Unmanaged features, left as they are on the bucket
+	if versioningIn := cr.PutBucketVersioningIn(); versioningIn != nil {
+		if _, errPuttingBucketVersionong := s3Client.PutBucketVersioning(versioningIn); errPuttingBucketVersionong != nil {
+			return errPuttingBucketVersionong
+		}
 	}
 
-	if _, errPuttingBucketAcceleration := r.s3Client.PutBucketAccelerateConfiguration(cr.PutBucketAccelIn()); errPuttingBucketAcceleration != nil {
-		return errPuttingBucketAcceleration
+	if accelIn := cr.PutBucketAccelIn(); accelIn != nil {
+		if _, errPuttingBucketAcceleration := s3Client.PutBucketAccelerateConfiguration(accelIn); errPuttingBucketAcceleration != nil {
+			return errPuttingBucketAcceleration
+		}
 	}
 
-	return PutBucketPolicy(cr, r.s3Client)
+	return PutBucketPolicy(cr, bucketPolicy, s3Client)
 }
 
-func PutBucketPolicy(cr *v1alpha1.S3, s3Client s3iface.S3API) error {
-
-	if cr.Spec.BucketPolicy == "" {
-		_, errDeletingBucketPolicy := s3Client.DeleteBucketPolicy(&s3.DeleteBucketPolicyInput{Bucket: aws.String(cr.Spec.BucketName)})
+// PutBucketPolicy is the only writer of the bucket policy, policy is the rendered bucketPolicy merged with the generated statements
+func PutBucketPolicy(cr *v1beta1.S3, policy string, s3Client s3iface.S3API) error {
+	input := cr.PutBucketPolicyIn(policy)
+	if input == nil {
+		_, errDeletingBucketPolicy := s3Client.DeleteBucketPolicy(&s3.DeleteBucketPolicyInput{Bucket: aws.String(cr.GetBucketName())})
 		return errDeletingBucketPolicy
 	}
 
-	input := cr.PutBucketPolicyIn()
 	if err := input.Validate(); err != nil {
 		return err
 	}
@@ -67,63 +116,20 @@ func PutBucketPolicy(cr *v1alpha1.S3, s3Client s3iface.S3API) error {
 
 }
 
-// if secret is not found in namespace, create new access keys ( delete the rest of the access keys if any )
-// if secret is found, and access key does not match IAM access key ( delete the secret and delete all access keys on IAM ) and create fresh access keys
-func handleAccessKeys(cr *v1alpha1.S3, iamClient iamiface.IAMAPI, client client.Client, scheme *runtime.Scheme) error {
-	secret, err := getIamK8sSecret(cr, client)
-	if err != nil {
-		if apierror.IsNotFound(err) {
-			// clean up access keys if any
-			if errDeletingAllAccessKeys := utils.DeleteAllAccessKeys(cr.Spec.IAMUserSpec.Username, iamClient); errDeletingAllAccessKeys != nil {
-				return errDeletingAllAccessKeys
-			}
-
-			// create fresh access keys
-			acccessKeysOutput, errCreatingAccessKeys := utils.CreateAccessKeys(cr.Spec.IAMUserSpec.Username, iamClient)
-			if errCreatingAccessKeys != nil {
-				return errCreatingAccessKeys
-			}
-
-			// create k8s secret
-			if errCreatingSecret := createIamK8sSecret(cr,
-				*acccessKeysOutput.AccessKey.AccessKeyId,
-				*acccessKeysOutput.AccessKey.SecretAccessKey,
-				client, scheme); errCreatingSecret != nil {
-				return errCreatingSecret
-			}
-		}
-		// if err is something else other then isNotFound, return that error
-		return err
-	}
-
-	// if secret is found make sure access keys matches the one in IAM
-	accessKeyMatches, errCheckingForMatch := secretAccessKeyAndIamAccessKeyMatch(cr, secret, iamClient)
-	if errCheckingForMatch != nil {
-		return errCheckingForMatch
-	}
-	if !accessKeyMatches {
-		// delete secret to re-initiate
-		if err := client.Delete(context.TODO(), secret); err != nil {
-			return err
-		}
-		// return error to force a requeue
-		return customErrors.ErrorIAMK8SSecretNeedsUpdate{Message: "AccessKeyId no longer matches with AWS"}
-	}
-
-	return nil
-}
-
-func CreateOrUpdateIAMPolicy(cr *v1alpha1.S3, iamClient iamiface.IAMAPI) error {
-	inlinePolicyIn, errCreatingPolicyInput := cr.GetRestrictedInlinePolicyInput()
+// CreateOrUpdateIAMPolicy puts the bucket inline policy on the user when it is missing or no longer matches the spec,
+// e.g: after the access level changed
+func CreateOrUpdateIAMPolicy(cr *v1beta1.S3, username string, iamClient iamiface.IAMAPI) error {
+	inlinePolicyIn, errCreatingPolicyInput := cr.GetRestrictedInlinePolicyInput(username)
 	if errCreatingPolicyInput != nil {
 		return errCreatingPolicyInput
 	}
+	return credentials.PutUserPolicyIfChanged(inlinePolicyIn, iamClient)
+}
 
-	_, errCreatingPolicy := iamClient.PutUserPolicy(inlinePolicyIn)
-	if errCreatingPolicy != nil {
-		return errCreatingPolicy
+func bucketNameTakenErr(bucketName string) error {
+	return customErrors.ErrorTerminal{
+		Reason: "BucketNameTaken",
+		Message: fmt.Sprintf("bucket name %v is owned by another AWS account. "+
+			"Bucket names are global across all AWS accounts, pick a different bucketName", bucketName),
 	}
-
-	return nil
-
 }
@@ -0,0 +1,48 @@
+package s3
+
+import (
+	agillv1beta1 "github.com/agill17/s3-operator/pkg/apis/agill/v1beta1"
+	"github.com/agill17/s3-operator/pkg/controller/guardrails"
+	"github.com/agill17/s3-operator/pkg/utils"
+	v1 "k8s.io/api/core/v1"
+)
+
+// checkGuardrails checks the CR against the S3Guardrails selecting its namespace, they may have changed since it was admitted.
+// Returns true when the CR violates any of them, the violations are in the GuardrailViolation condition then.
+func (r ReconcileS3) checkGuardrails(cr *agillv1beta1.S3, bucketPolicy string) (bool, error) {
+	selecting, err := guardrails.Selecting(r.client, cr.GetNamespace())
+	if err != nil {
+		return false, err
+	}
+
+	violations := guardrails.Violations(selecting, *cr, bucketPolicy)
+	if len(violations) == 0 {
+		return false, clearGuardrailViolation(cr, r.client)
+	}
+	return true, r.setGuardrailViolation(cr, violations.ToAggregate().Error())
+}
+
+// setGuardrailViolation stops the CR until it complies again, events are only sent when the violations change
+func (r ReconcileS3) setGuardrailViolation(cr *agillv1beta1.S3, msg string) error {
+	previous := agillv1beta1.GetCondition(cr.Status.Conditions, agillv1beta1.CONDITION_GUARDRAIL_VIOLATION)
+	if previous == nil || previous.Status != v1.ConditionTrue || previous.Message != msg {
+		r.recorder.Eventf(cr, v1.EventTypeWarning, "GUARDRAIL_VIOLATION", "%v", msg)
+	}
+
+	cr.Status.Phase = agillv1beta1.PHASE_STALLED
+	agillv1beta1.SetCondition(&cr.Status.Conditions, agillv1beta1.Condition{
+		Type:               agillv1beta1.CONDITION_READY,
+		Status:             v1.ConditionFalse,
+		ObservedGeneration: cr.GetGeneration(),
+		Reason:             "GuardrailViolation",
+		Message:            msg,
+	})
+	agillv1beta1.SetCondition(&cr.Status.Conditions, agillv1beta1.Condition{
+		Type:               agillv1beta1.CONDITION_GUARDRAIL_VIOLATION,
+		Status:             v1.ConditionTrue,
+		ObservedGeneration: cr.GetGeneration(),
+		Reason:             "Violated",
+		Message:            msg,
+	})
+	return utils.UpdateCrStatus(cr, r.client)
+}
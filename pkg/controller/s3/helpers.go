@@ -2,52 +2,88 @@ package s3
 
 import (
 	"context"
-	"github.com/agill17/s3-operator/pkg/apis/agill/v1alpha1"
+	"github.com/agill17/s3-operator/pkg/apis/agill/v1beta1"
 	"github.com/agill17/s3-operator/pkg/utils"
+	"github.com/aws/aws-sdk-go/aws/awserr"
 	"github.com/aws/aws-sdk-go/service/iam"
-	"github.com/aws/aws-sdk-go/service/iam/iamiface"
 	v1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/types"
-	"net/url"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 )
 
-func secretAccessKeyAndIamAccessKeyMatch(cr *v1alpha1.S3, k8sSecret *v1.Secret, iamClient iamiface.IAMAPI) (bool, error) {
-	accessKeyIdInSecret := string(k8sSecret.Data["AWS_ACCESS_KEY_ID"])
-	accessKeyIdInAWS, err := utils.GetAccessKeyForUser(cr.Spec.IAMUserSpec.Username, iamClient)
-	if err != nil {
-		return false, err
+func clearGuardrailViolation(cr *v1beta1.S3, client client.Client) error {
+	if !v1beta1.IsConditionTrue(cr.Status.Conditions, v1beta1.CONDITION_GUARDRAIL_VIOLATION) {
+		return nil
 	}
-
-	return accessKeyIdInSecret == accessKeyIdInAWS, nil
+	v1beta1.SetCondition(&cr.Status.Conditions, v1beta1.Condition{
+		Type:               v1beta1.CONDITION_GUARDRAIL_VIOLATION,
+		Status:             v1.ConditionFalse,
+		ObservedGeneration: cr.GetGeneration(),
+		Reason:             "Compliant",
+	})
+	return utils.UpdateCrStatus(cr, client)
 }
 
-func getIamK8sSecret(cr *v1alpha1.S3, client2 client.Client) (*v1.Secret, error) {
-	s := &v1.Secret{}
-	err := client2.Get(context.TODO(), types.NamespacedName{Name: cr.GetIAMK8SSecretName(), Namespace: cr.GetNamespace()}, s)
-	return s, err
+// allS3s maps any object to every S3 CR in the cluster
+func allS3s(c client.Client) []reconcile.Request {
+	s3List := &v1beta1.S3List{}
+	if err := c.List(context.TODO(), s3List); err != nil {
+		log.Error(err, "Failed to list S3 CRs")
+		return nil
+	}
+	var requests []reconcile.Request
+	for _, s := range s3List.Items {
+		requests = append(requests, reconcile.Request{NamespacedName: types.NamespacedName{Name: s.GetName(), Namespace: s.GetNamespace()}})
+	}
+	return requests
 }
 
-func IAMPolicyMatchesDesiredPolicyDocument(desiredPolicyDocument, username, policyName string, iamClient iamiface.IAMAPI) (bool, error) {
-	currentPolicyInAWS, errGetting := iamClient.GetUserPolicy(&iam.GetUserPolicyInput{
-		PolicyName: &policyName,
-		UserName:   &username,
-	})
-	if errGetting != nil {
-		return false, errGetting
+// s3sReferencingIAMUser maps an IAMUser CR to the S3 CRs in its namespace that reference it
+func s3sReferencingIAMUser(c client.Client, namespace, name string) []reconcile.Request {
+	s3List := &v1beta1.S3List{}
+	if err := c.List(context.TODO(), s3List, client.InNamespace(namespace)); err != nil {
+		log.Error(err, "Failed to list S3 CRs referencing IAMUser", "Namespace", namespace, "Name", name)
+		return nil
 	}
-	currentPolicyDocInAws, err := url.QueryUnescape(*currentPolicyInAWS.PolicyDocument)
-	if err != nil {
-		return false, err
+	var requests []reconcile.Request
+	for _, s := range s3List.Items {
+		if s.UsesIAMUserRef() && s.Spec.IAMUserRef.Name == name {
+			requests = append(requests, reconcile.Request{NamespacedName: types.NamespacedName{Name: s.GetName(), Namespace: namespace}})
+		}
 	}
-	return desiredPolicyDocument == currentPolicyDocInAws, nil
+	return requests
+}
 
+// s3sInNamespace maps a Namespace to every S3 CR in it
+func s3sInNamespace(c client.Client, namespace string) []reconcile.Request {
+	s3List := &v1beta1.S3List{}
+	if err := c.List(context.TODO(), s3List, client.InNamespace(namespace)); err != nil {
+		log.Error(err, "Failed to list S3 CRs in namespace", "Namespace", namespace)
+		return nil
+	}
+	var requests []reconcile.Request
+	for _, s := range s3List.Items {
+		requests = append(requests, reconcile.Request{NamespacedName: types.NamespacedName{Name: s.GetName(), Namespace: namespace}})
+	}
+	return requests
 }
 
-func setStatus(msg string, cr *v1alpha1.S3, client client.Client) error {
-	if cr.Status.Status != msg {
-		cr.Status.Status = msg
-		return utils.UpdateCrStatus(cr, client)
+// s3sReferencingConfigMap maps a ConfigMap to the S3 CRs in its namespace reading their bucket policy from it
+func s3sReferencingConfigMap(c client.Client, namespace, name string) []reconcile.Request {
+	s3List := &v1beta1.S3List{}
+	if err := c.List(context.TODO(), s3List, client.InNamespace(namespace), client.MatchingField(BUCKET_POLICY_CONFIGMAP_INDEX, name)); err != nil {
+		log.Error(err, "Failed to list S3 CRs referencing ConfigMap", "Namespace", namespace, "Name", name)
+		return nil
+	}
+	var requests []reconcile.Request
+	for _, s := range s3List.Items {
+		requests = append(requests, reconcile.Request{NamespacedName: types.NamespacedName{Name: s.GetName(), Namespace: namespace}})
 	}
-	return nil
+	return requests
+}
+
+func isNoSuchEntity(err error) bool {
+	awsErr, ok := err.(awserr.Error)
+	return ok && awsErr.Code() == iam.ErrCodeNoSuchEntityException
 }
@@ -2,7 +2,7 @@ package s3
 
 import (
 	"context"
-	agillv1alpha1 "github.com/agill17/s3-operator/pkg/apis/agill/v1alpha1"
+	agillv1beta1 "github.com/agill17/s3-operator/pkg/apis/agill/v1beta1"
 	v1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
@@ -10,42 +10,19 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
 )
 
-func createIamK8sSecret(cr *agillv1alpha1.S3, accessKeyId, secretAccessKey string, client client.Client, scheme *runtime.Scheme) error {
-	secret := &v1.Secret{
-		ObjectMeta: metav1.ObjectMeta{
-			Name:      cr.GetIAMK8SSecretName(),
-			Namespace: cr.GetNamespace(),
-		},
-		Data: map[string][]byte{
-			"AWS_ACCESS_KEY_ID":     []byte(accessKeyId),
-			"AWS_SECRET_ACCESS_KEY": []byte(secretAccessKey),
-		},
-		Type: v1.SecretTypeOpaque,
-	}
-
-	if _, err := controllerutil.CreateOrUpdate(context.TODO(), client, secret, func() error {
-		return controllerutil.SetControllerReference(cr, secret, scheme)
-	}); err != nil {
-		return err
-	}
-
-	return nil
-}
-
-func createS3K8sService(cr *agillv1alpha1.S3, client client.Client, scheme *runtime.Scheme) error {
+func createS3K8sService(cr *agillv1beta1.S3, endpointHost string, client client.Client, scheme *runtime.Scheme) error {
 	svc := &v1.Service{
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      cr.GetName(),
 			Namespace: cr.GetNamespace(),
 		},
-		Spec: v1.ServiceSpec{
-			Type:         v1.ServiceTypeExternalName,
-			ExternalName: "s3.amazonaws.com",
-		},
 	}
 
 	// TODO: record result in a event
 	if _, err := controllerutil.CreateOrUpdate(context.TODO(), client, svc, func() error {
+		// keep the published endpoint in sync with the region and endpoint settings
+		svc.Spec.Type = v1.ServiceTypeExternalName
+		svc.Spec.ExternalName = endpointHost
 		return controllerutil.SetControllerReference(cr, svc, scheme)
 	}); err != nil {
 		return err
@@ -0,0 +1,171 @@
+package s3
+
+import (
+	"fmt"
+
+	agillv1beta1 "github.com/agill17/s3-operator/pkg/apis/agill/v1beta1"
+	"github.com/agill17/s3-operator/pkg/utils"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/iam"
+	"github.com/aws/aws-sdk-go/service/iam/iamiface"
+	"github.com/aws/aws-sdk-go/service/s3/s3iface"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// handleLockdown contains a leak while the s3.agill.apps/lockdown annotation is set: the access keys of the bucket users
+// are deactivated and the bucket policy denies everyone but the admins. Everything changed is recorded in status.lockdown
+// before it is changed, removing the annotation restores exactly that. Returns true while the bucket is locked down.
+func (r ReconcileS3) handleLockdown(cr *agillv1beta1.S3, s3Client s3iface.S3API, iamClient iamiface.IAMAPI) (bool, error) {
+	reason := cr.LockdownReason()
+	if reason == "" {
+		if cr.Status.Lockdown == nil {
+			return false, nil
+		}
+		return false, r.liftLockdown(cr, s3Client, iamClient)
+	}
+
+	if cr.Status.Lockdown == nil {
+		previousPolicy := ""
+		if cr.Status.BucketName != "" {
+			var err error
+			if previousPolicy, err = utils.GetBucketPolicy(cr.GetBucketName(), s3Client); err != nil {
+				return false, err
+			}
+		}
+		r.recorder.Eventf(cr, v1.EventTypeWarning, "LOCKDOWN", "Locking down bucket and credentials: %v", reason)
+		cr.Status.Lockdown = &agillv1beta1.LockdownStatus{Reason: reason, Since: metav1.Now(), PreviousBucketPolicy: previousPolicy}
+		if err := utils.UpdateCrStatus(cr, r.client); err != nil {
+			return false, err
+		}
+	}
+
+	// a bucket that was never created has nothing to lock, it just is not created until the lockdown is lifted
+	if cr.Status.BucketName != "" {
+		if err := r.deactivateAccessKeys(cr, iamClient); err != nil {
+			return false, err
+		}
+		// the operator stays exempt, so it can lift the lockdown again
+		operatorUserID, err := r.clients.PrincipalUserID(cr.GetRegion())
+		if err != nil {
+			return false, err
+		}
+		// put on every reconcile, so a policy changed in AWS during the incident is locked again
+		policy, err := cr.LockdownBucketPolicy(cr.Status.Lockdown.PreviousBucketPolicy, operatorUserID, r.lockdownAdmins)
+		if err != nil {
+			return false, err
+		}
+		if err := PutBucketPolicy(cr, policy, s3Client); err != nil {
+			return false, err
+		}
+	}
+	return true, r.setLockedDown(cr, reason)
+}
+
+// handleStalledLockdown applies or lifts the lockdown of a CR stalled on a terminal error, the CR is back in phase
+// Stalled once the lockdown is lifted
+func (r ReconcileS3) handleStalledLockdown(cr *agillv1beta1.S3) error {
+	s3Client, err := r.clients.S3(cr.GetRegion())
+	if err != nil {
+		return err
+	}
+	iamClient, err := r.clients.IAM(cr.GetRegion())
+	if err != nil {
+		return err
+	}
+	locked, err := r.handleLockdown(cr, s3Client, iamClient)
+	if err != nil || locked || cr.Status.Phase == agillv1beta1.PHASE_STALLED {
+		return err
+	}
+	cr.Status.Phase = agillv1beta1.PHASE_STALLED
+	return utils.UpdateCrStatus(cr, r.client)
+}
+
+// deactivateAccessKeys deactivates the active keys of the bucket users. Keys are recorded before they are deactivated,
+// so a failed status update never leaves a key inactive that would not be activated again.
+func (r ReconcileS3) deactivateAccessKeys(cr *agillv1beta1.S3, iamClient iamiface.IAMAPI) error {
+	recorded := map[agillv1beta1.LockedAccessKey]bool{}
+	for _, k := range cr.Status.Lockdown.DeactivatedAccessKeys {
+		recorded[k] = true
+	}
+
+	for _, username := range cr.LockdownUsernames() {
+		// a username taken by someone else was never adopted, its keys are not ours to deactivate
+		owned, err := utils.IsIAMUserOwner(username, cr.IAMUserOwner(), r.adoptUntagged, iamClient)
+		if err != nil {
+			return err
+		}
+		if !owned {
+			continue
+		}
+		keys, err := iamClient.ListAccessKeys(&iam.ListAccessKeysInput{UserName: aws.String(username)})
+		if err != nil {
+			if isNoSuchEntity(err) {
+				continue
+			}
+			return err
+		}
+		for _, k := range keys.AccessKeyMetadata {
+			if aws.StringValue(k.Status) != iam.StatusTypeActive {
+				continue
+			}
+			key := agillv1beta1.LockedAccessKey{Username: username, AccessKeyID: aws.StringValue(k.AccessKeyId)}
+			if !recorded[key] {
+				cr.Status.Lockdown.DeactivatedAccessKeys = append(cr.Status.Lockdown.DeactivatedAccessKeys, key)
+				if err := utils.UpdateCrStatus(cr, r.client); err != nil {
+					return err
+				}
+				recorded[key] = true
+			}
+			if _, err := iamClient.UpdateAccessKey(&iam.UpdateAccessKeyInput{
+				UserName:    k.UserName,
+				AccessKeyId: k.AccessKeyId,
+				Status:      aws.String(iam.StatusTypeInactive),
+			}); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// liftLockdown activates the recorded keys again and puts back the bucket policy from before the lockdown,
+// the rest of the reconcile then carries on as usual
+func (r ReconcileS3) liftLockdown(cr *agillv1beta1.S3, s3Client s3iface.S3API, iamClient iamiface.IAMAPI) error {
+	for _, k := range cr.Status.Lockdown.DeactivatedAccessKeys {
+		if _, err := iamClient.UpdateAccessKey(&iam.UpdateAccessKeyInput{
+			UserName:    aws.String(k.Username),
+			AccessKeyId: aws.String(k.AccessKeyID),
+			Status:      aws.String(iam.StatusTypeActive),
+		}); err != nil && !isNoSuchEntity(err) {
+			return err
+		}
+	}
+	if cr.Status.BucketName != "" {
+		if err := PutBucketPolicy(cr, cr.Status.Lockdown.PreviousBucketPolicy, s3Client); err != nil {
+			return err
+		}
+	}
+
+	r.recorder.Eventf(cr, v1.EventTypeNormal, "LOCKDOWN_LIFTED", "Lockdown since %v lifted, %v access keys activated again",
+		cr.Status.Lockdown.Since.UTC().Format("2006-01-02T15:04:05Z"), len(cr.Status.Lockdown.DeactivatedAccessKeys))
+	cr.Status.Lockdown = nil
+	return utils.UpdateCrStatus(cr, r.client)
+}
+
+// setLockedDown reports the lockdown in phase and Ready, status is only updated when something changed
+func (r ReconcileS3) setLockedDown(cr *agillv1beta1.S3, reason string) error {
+	conditionChanged := agillv1beta1.SetCondition(&cr.Status.Conditions, agillv1beta1.Condition{
+		Type:               agillv1beta1.CONDITION_READY,
+		Status:             v1.ConditionFalse,
+		ObservedGeneration: cr.GetGeneration(),
+		Reason:             string(agillv1beta1.PHASE_LOCKED_DOWN),
+		Message:            fmt.Sprintf("locked down since %v: %v", cr.Status.Lockdown.Since.UTC().Format("2006-01-02T15:04:05Z"), reason),
+	})
+	if cr.Status.Phase == agillv1beta1.PHASE_LOCKED_DOWN && cr.Status.Lockdown.Reason == reason && !conditionChanged {
+		return nil
+	}
+	cr.Status.Lockdown.Reason = reason
+	cr.Status.Phase = agillv1beta1.PHASE_LOCKED_DOWN
+	return utils.UpdateCrStatus(cr, r.client)
+}
@@ -1,34 +1,115 @@
 package s3
 
 import (
-	agillv1alpha1 "github.com/agill17/s3-operator/pkg/apis/agill/v1alpha1"
+	"context"
+	"fmt"
+	agillv1beta1 "github.com/agill17/s3-operator/pkg/apis/agill/v1beta1"
+	"github.com/agill17/s3-operator/pkg/controller/credentials"
+	customErrors "github.com/agill17/s3-operator/pkg/controller/errors"
 	"github.com/agill17/s3-operator/pkg/utils"
+	"github.com/aws/aws-sdk-go/service/iam/iamiface"
+	"github.com/aws/aws-sdk-go/service/s3/s3iface"
 	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
 )
 
-func (r ReconcileS3) handleCreateIamResources(cr *agillv1alpha1.S3) error {
+func (r ReconcileS3) handleCreateIamResources(cr *agillv1beta1.S3, iamClient iamiface.IAMAPI) error {
+	if errCreatingBucketUser := r.handleBucketIamUser(cr, iamClient); errCreatingBucketUser != nil {
+		return errCreatingBucketUser
+	}
+	return r.handleConsumers(cr, iamClient)
+}
+
+func (r ReconcileS3) handleBucketIamUser(cr *agillv1beta1.S3, iamClient iamiface.IAMAPI) error {
+	// the referenced IAMUser CR owns the user, its keys and secret, only grant it access to the bucket
+	if cr.UsesIAMUserRef() {
+		username, errResolving := r.resolveIAMUserRef(cr)
+		if errResolving != nil {
+			return errResolving
+		}
+		if username == "" {
+			return customErrors.ErrorIAMUserNotReady{Message: fmt.Sprintf("IAMUser %v has not created its user yet", cr.Spec.IAMUserRef.Name)}
+		}
+		return CreateOrUpdateIAMPolicy(cr, username, iamClient)
+	}
+
+	// buckets with consumers only may not have a user of their own
+	if cr.Spec.IAMUser.Username == "" {
+		return nil
+	}
+
 	// create iam user
-	errCreatingIamUser := utils.CreateIAMUser(cr.CreateIAMUserIn(), r.iamClient)
+	errCreatingIamUser := utils.CreateIAMUser(cr.CreateIAMUserIn(), cr.IAMUserOwner(), r.adoptUntagged, iamClient)
 	if errCreatingIamUser != nil {
 		return errCreatingIamUser
 	}
 
-	if errCreatingUpdatingPolicy := CreateOrUpdateIAMPolicy(cr, r.iamClient); errCreatingUpdatingPolicy != nil {
+	if errCreatingUpdatingPolicy := CreateOrUpdateIAMPolicy(cr, cr.Spec.IAMUser.Username, iamClient); errCreatingUpdatingPolicy != nil {
 		return errCreatingUpdatingPolicy
 	}
 
-	return handleAccessKeys(cr, r.iamClient, r.client, r.scheme)
+	return credentials.SyncAccessKeys(cr, cr.Spec.IAMUser.Username, cr.GetIAMK8SSecretName(), nil, iamClient, r.client, r.scheme)
 }
 
 // meant to create cloud resources if they do not exist ( s3, iam user )
-func (r ReconcileS3) handleCreateS3Resources(cr *agillv1alpha1.S3) error {
+func (r ReconcileS3) handleCreateS3Resources(cr *agillv1beta1.S3, bucketPolicy string, s3Client s3iface.S3API) error {
 
-	// create bucket
-	if errCreatingBucket := r.createBucket(cr); errCreatingBucket != nil {
-		return errCreatingBucket
+	// update bucket properties
+	if errConfiguringBucket := r.configureBucket(cr, bucketPolicy, s3Client); errConfiguringBucket != nil {
+		return errConfiguringBucket
 	}
 
 	// change phase to completed
 	r.recorder.Eventf(cr, v1.EventTypeNormal, "COMPLETED", "All resources are successfully reconciled.")
-	return createS3K8sService(cr, r.client, r.scheme)
+	return createS3K8sService(cr, r.clients.Config().S3EndpointHost(cr.GetRegion()), r.client, r.scheme)
+}
+
+// resolveIAMUserRef returns the username of the referenced IAMUser CR, empty until that CR created its user
+func (r ReconcileS3) resolveIAMUserRef(cr *agillv1beta1.S3) (string, error) {
+	iamUser, err := r.getIAMUserRef(cr)
+	if err != nil {
+		return "", err
+	}
+	return iamUser.Status.Username, nil
+}
+
+func (r ReconcileS3) getIAMUserRef(cr *agillv1beta1.S3) (*agillv1beta1.IAMUser, error) {
+	iamUser := &agillv1beta1.IAMUser{}
+	if err := r.client.Get(context.TODO(), types.NamespacedName{Name: cr.Spec.IAMUserRef.Name, Namespace: cr.GetNamespace()}, iamUser); err != nil {
+		return nil, err
+	}
+	return iamUser, nil
+}
+
+// handleDeleteIamResources deletes the bucket user and consumer users, or only the bucket inline policy
+// when the user belongs to an IAMUser CR. Secrets are owned by the CR and garbage collected with it.
+func (r ReconcileS3) handleDeleteIamResources(cr *agillv1beta1.S3, iamClient iamiface.IAMAPI) error {
+	if errDeletingConsumers := r.deleteConsumerUsers(cr, iamClient); errDeletingConsumers != nil {
+		return errDeletingConsumers
+	}
+
+	if !cr.UsesIAMUserRef() {
+		if cr.Spec.IAMUser.Username == "" {
+			return nil
+		}
+		return credentials.DeleteIAMUser(cr, cr.Spec.IAMUser.Username, cr.IAMUserOwner(), r.adoptUntagged, iamClient, r.recorder)
+	}
+
+	username, errResolving := r.resolveIAMUserRef(cr)
+	if errResolving != nil {
+		// the IAMUser CR deletes its user along with every inline policy
+		if apierrors.IsNotFound(errResolving) {
+			return nil
+		}
+		return errResolving
+	}
+	if username == "" {
+		return nil
+	}
+	errDeleting := utils.DeleteIAMInlinePolicyFromUser(cr.GetPolicyName(username), username, iamClient)
+	if isNoSuchEntity(errDeleting) {
+		return nil
+	}
+	return errDeleting
 }
@@ -0,0 +1,149 @@
+package s3
+
+import (
+	"fmt"
+	agillv1beta1 "github.com/agill17/s3-operator/pkg/apis/agill/v1beta1"
+	customErrors "github.com/agill17/s3-operator/pkg/controller/errors"
+	"github.com/agill17/s3-operator/pkg/utils"
+	v1 "k8s.io/api/core/v1"
+	"strings"
+)
+
+type policyToValidate struct {
+	// shown in findings, e.g: bucketPolicy, consumers[tenant-a]
+	name         string
+	document     string
+	policyType   string
+	resourceType string
+}
+
+// validatePolicies runs Access Analyzer on the bucket policy and every generated user policy before any of them is applied.
+// Errors stall the CR, security warnings that are not acknowledged end up in the PolicyWarnings condition and events.
+func (r ReconcileS3) validatePolicies(cr *agillv1beta1.S3, bucketPolicy string) error {
+	if !r.policyValidation {
+		return nil
+	}
+	validator, err := r.clients.PolicyValidator(cr.GetRegion())
+	if err != nil {
+		return err
+	}
+
+	policies, err := r.policiesToValidate(cr, bucketPolicy)
+	if err != nil {
+		return err
+	}
+
+	acknowledged := acknowledgedFindings(cr)
+	var errs, warnings []string
+	for _, p := range policies {
+		findings, err := validator.ValidatePolicy(p.document, p.policyType, p.resourceType)
+		if err != nil {
+			return err
+		}
+		for _, f := range findings {
+			switch f.FindingType {
+			case utils.POLICY_FINDING_ERROR:
+				errs = append(errs, fmt.Sprintf("%v: %v %v", p.name, f.IssueCode, f.FindingDetails))
+			case utils.POLICY_FINDING_SECURITY_WARNING:
+				if !acknowledged[f.IssueCode] {
+					warnings = append(warnings, fmt.Sprintf("%v: %v %v", p.name, f.IssueCode, f.FindingDetails))
+				}
+			}
+		}
+	}
+
+	if len(errs) > 0 {
+		return customErrors.ErrorTerminal{
+			Reason:  "PolicyValidationFailed",
+			Message: strings.Join(errs, "; "),
+		}
+	}
+	return r.setPolicyWarnings(cr, warnings)
+}
+
+// policiesToValidate returns the documents the reconcile is about to apply
+func (r ReconcileS3) policiesToValidate(cr *agillv1beta1.S3, bucketPolicy string) ([]policyToValidate, error) {
+	var err error
+	var policies []policyToValidate
+	if bucketPolicy != "" {
+		policies = append(policies, policyToValidate{
+			name:         "bucketPolicy",
+			document:     bucketPolicy,
+			policyType:   utils.POLICY_TYPE_RESOURCE,
+			resourceType: utils.VALIDATE_POLICY_RESOURCE_TYPE_S3_BUCKET,
+		})
+	}
+
+	username := cr.Spec.IAMUser.Username
+	if cr.UsesIAMUserRef() {
+		if username, err = r.resolveIAMUserRef(cr); err != nil {
+			return nil, err
+		}
+		// templates may use the user ARN, nothing is validated against a user that does not exist yet
+		if username == "" {
+			return nil, customErrors.ErrorIAMUserNotReady{Message: fmt.Sprintf("IAMUser %v has not created its user yet", cr.Spec.IAMUserRef.Name)}
+		}
+	}
+	if username != "" {
+		in, err := cr.GetRestrictedInlinePolicyInput(username)
+		if err != nil {
+			return nil, err
+		}
+		policies = append(policies, policyToValidate{
+			name:       "iamUser",
+			document:   *in.PolicyDocument,
+			policyType: utils.POLICY_TYPE_IDENTITY,
+		})
+	}
+
+	for _, c := range cr.Spec.Consumers {
+		in, err := cr.GetConsumerPolicyInput(c)
+		if err != nil {
+			return nil, err
+		}
+		policies = append(policies, policyToValidate{
+			name:       fmt.Sprintf("consumers[%v]", c.Name),
+			document:   *in.PolicyDocument,
+			policyType: utils.POLICY_TYPE_IDENTITY,
+		})
+	}
+	return policies, nil
+}
+
+// setPolicyWarnings records unacknowledged security warnings, events are only sent when they change
+func (r ReconcileS3) setPolicyWarnings(cr *agillv1beta1.S3, warnings []string) error {
+	condition := agillv1beta1.Condition{
+		Type:               agillv1beta1.CONDITION_POLICY_WARNINGS,
+		Status:             v1.ConditionFalse,
+		ObservedGeneration: cr.GetGeneration(),
+		Reason:             "NoSecurityWarnings",
+	}
+	if len(warnings) > 0 {
+		condition.Status = v1.ConditionTrue
+		condition.Reason = "SecurityWarnings"
+		condition.Message = strings.Join(warnings, "; ")
+	}
+
+	previous := agillv1beta1.GetCondition(cr.Status.Conditions, agillv1beta1.CONDITION_POLICY_WARNINGS)
+	if previous == nil || previous.Message != condition.Message {
+		for _, w := range warnings {
+			r.recorder.Eventf(cr, v1.EventTypeWarning, "POLICY_WARNING", "%v", w)
+		}
+	}
+
+	if agillv1beta1.SetCondition(&cr.Status.Conditions, condition) {
+		return utils.UpdateCrStatus(cr, r.client)
+	}
+	return nil
+}
+
+// acknowledgedFindings returns the issue codes listed in the acknowledged findings annotation
+func acknowledgedFindings(cr *agillv1beta1.S3) map[string]bool {
+	acknowledged := map[string]bool{}
+	for _, code := range strings.Split(cr.GetAnnotations()[utils.ACKNOWLEDGED_FINDINGS_ANNOTATION], ",") {
+		if code = strings.TrimSpace(code); code != "" {
+			acknowledged[code] = true
+		}
+	}
+	return acknowledged
+}
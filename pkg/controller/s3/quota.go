@@ -0,0 +1,33 @@
+package s3
+
+import (
+	"time"
+
+	agillv1beta1 "github.com/agill17/s3-operator/pkg/apis/agill/v1beta1"
+	"github.com/agill17/s3-operator/pkg/controller/quotas"
+	"github.com/agill17/s3-operator/pkg/utils"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// refreshBucketSize records the bucket size for the bytes quota, only in namespaces that have one
+func (r ReconcileS3) refreshBucketSize(cr *agillv1beta1.S3) error {
+	quota, err := quotas.ForNamespace(r.client, cr.GetNamespace())
+	if err != nil || quota.Bytes == nil {
+		return err
+	}
+	if cr.Status.SizeCheckedAt != nil && time.Since(cr.Status.SizeCheckedAt.Time) < utils.BUCKET_SIZE_REFRESH_INTERVAL {
+		return nil
+	}
+
+	cwClient, err := r.clients.CloudWatch(cr.GetRegion())
+	if err != nil {
+		return err
+	}
+	size, err := utils.GetBucketSizeBytes(cr.GetBucketName(), cwClient)
+	if err != nil {
+		return err
+	}
+	now := metav1.Now()
+	cr.Status.SizeBytes, cr.Status.SizeCheckedAt = size, &now
+	return utils.UpdateCrStatus(cr, r.client)
+}
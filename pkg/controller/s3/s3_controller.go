@@ -2,14 +2,18 @@ package s3
 
 import (
 	"context"
-	agillv1alpha1 "github.com/agill17/s3-operator/pkg/apis/agill/v1alpha1"
+	"fmt"
+	agillv1beta1 "github.com/agill17/s3-operator/pkg/apis/agill/v1beta1"
 	customErrors "github.com/agill17/s3-operator/pkg/controller/errors"
+	"github.com/agill17/s3-operator/pkg/controller/options"
+	"github.com/agill17/s3-operator/pkg/controller/quotas"
+	"github.com/agill17/s3-operator/pkg/controller/status"
 	"github.com/agill17/s3-operator/pkg/utils"
-	"github.com/aws/aws-sdk-go/service/iam/iamiface"
-	"github.com/aws/aws-sdk-go/service/s3/s3iface"
+	"github.com/go-logr/logr"
 	v1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/tools/record"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller"
@@ -18,40 +22,58 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/manager"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 	"sigs.k8s.io/controller-runtime/pkg/source"
+	"strings"
 )
 
 const S3_CONTROLLER = "s3Controller"
 
+// field index of the cache, the S3 CRs reading their bucket policy from a ConfigMap by its name
+const BUCKET_POLICY_CONFIGMAP_INDEX = "spec.bucketPolicyFrom.configMapKeyRef.name"
+
 var log = logf.Log.WithName("controller_s3")
 
 // Add creates a new S3 Controller and adds it to the Manager. The Manager will set fields on the Controller
 // and Start it when the Manager is Started.
-func Add(mgr manager.Manager) error {
-	return add(mgr, newReconciler(mgr))
+func Add(mgr manager.Manager, opts options.Options) error {
+	return add(mgr, newReconciler(mgr, opts), opts)
 }
 
 // newReconciler returns a new reconcile.Reconciler
-func newReconciler(mgr manager.Manager) reconcile.Reconciler {
-	return &ReconcileS3{client: mgr.GetClient(), scheme: mgr.GetScheme(), recorder: mgr.GetEventRecorderFor(S3_CONTROLLER)}
+func newReconciler(mgr manager.Manager, opts options.Options) reconcile.Reconciler {
+	return &ReconcileS3{
+		client:   mgr.GetClient(),
+		scheme:   mgr.GetScheme(),
+		recorder: mgr.GetEventRecorderFor(S3_CONTROLLER),
+		clients:  opts.Clients,
+
+		policyValidation: opts.ValidatePolicies,
+		baseline:         opts.BaselinePolicy,
+		requireApproval:  opts.RequirePublicBucketApproval,
+		lockdownAdmins:   opts.LockdownAdminARNs,
+		adoptUntagged:    opts.AdoptUntaggedIAMUsers,
+	}
 }
 
 // add adds a new Controller to mgr with r as the reconcile.Reconciler
-func add(mgr manager.Manager, r reconcile.Reconciler) error {
+func add(mgr manager.Manager, r reconcile.Reconciler, opts options.Options) error {
 	// Create a new controller
-	c, err := controller.New("s3-controller", mgr, controller.Options{Reconciler: r})
+	c, err := controller.New("s3-controller", mgr, controller.Options{
+		Reconciler:              r,
+		MaxConcurrentReconciles: opts.MaxConcurrentReconciles,
+	})
 	if err != nil {
 		return err
 	}
 
 	// Watch for changes to primary resource S3
-	err = c.Watch(&source.Kind{Type: &agillv1alpha1.S3{}}, &handler.EnqueueRequestForObject{})
+	err = c.Watch(&source.Kind{Type: &agillv1beta1.S3{}}, &handler.EnqueueRequestForObject{})
 	if err != nil {
 		return err
 	}
 
 	// watch s3 k8s service
 	err = c.Watch(&source.Kind{Type: &v1.Service{}}, &handler.EnqueueRequestForOwner{
-		OwnerType:    &agillv1alpha1.S3{},
+		OwnerType:    &agillv1beta1.S3{},
 		IsController: true,
 	})
 	if err != nil {
@@ -59,13 +81,80 @@ func add(mgr manager.Manager, r reconcile.Reconciler) error {
 	}
 
 	err = c.Watch(&source.Kind{Type: &v1.Secret{}}, &handler.EnqueueRequestForOwner{
-		OwnerType:    &agillv1alpha1.S3{},
+		OwnerType:    &agillv1beta1.S3{},
 		IsController: true,
 	})
 	if err != nil {
 		return err
 	}
 
+	// buckets referencing an IAMUser CR wait for its user to be created
+	err = c.Watch(&source.Kind{Type: &agillv1beta1.IAMUser{}}, &handler.EnqueueRequestsFromMapFunc{
+		ToRequests: handler.ToRequestsFunc(func(obj handler.MapObject) []reconcile.Request {
+			return s3sReferencingIAMUser(mgr.GetClient(), obj.Meta.GetNamespace(), obj.Meta.GetName())
+		}),
+	})
+	if err != nil {
+		return err
+	}
+
+	// baseline statements can be changed per namespace
+	err = c.Watch(&source.Kind{Type: &v1.Namespace{}}, &handler.EnqueueRequestsFromMapFunc{
+		ToRequests: handler.ToRequestsFunc(func(obj handler.MapObject) []reconcile.Request {
+			return s3sInNamespace(mgr.GetClient(), obj.Meta.GetName())
+		}),
+	})
+	if err != nil {
+		return err
+	}
+
+	// guardrails are cluster scoped and select namespaces by label, every CR is checked again
+	err = c.Watch(&source.Kind{Type: &agillv1beta1.S3Guardrail{}}, &handler.EnqueueRequestsFromMapFunc{
+		ToRequests: handler.ToRequestsFunc(func(obj handler.MapObject) []reconcile.Request {
+			return allS3s(mgr.GetClient())
+		}),
+	})
+	if err != nil {
+		return err
+	}
+
+	// approvals are cluster scoped, map them to the S3 CR they reference
+	err = c.Watch(&source.Kind{Type: &agillv1beta1.BucketApproval{}}, &handler.EnqueueRequestsFromMapFunc{
+		ToRequests: handler.ToRequestsFunc(func(obj handler.MapObject) []reconcile.Request {
+			approval, ok := obj.Object.(*agillv1beta1.BucketApproval)
+			if !ok {
+				return nil
+			}
+			return []reconcile.Request{{NamespacedName: types.NamespacedName{
+				Name:      approval.Spec.BucketRef.Name,
+				Namespace: approval.Spec.BucketRef.Namespace,
+			}}}
+		}),
+	})
+	if err != nil {
+		return err
+	}
+
+	// bucket policies read from a ConfigMap follow its changes, the index saves listing every S3 CR of the namespace per ConfigMap event
+	err = mgr.GetFieldIndexer().IndexField(&agillv1beta1.S3{}, BUCKET_POLICY_CONFIGMAP_INDEX, func(obj runtime.Object) []string {
+		s3, ok := obj.(*agillv1beta1.S3)
+		if !ok || s3.Spec.BucketPolicyFrom == nil {
+			return nil
+		}
+		return []string{s3.Spec.BucketPolicyFrom.ConfigMapKeyRef.Name}
+	})
+	if err != nil {
+		return err
+	}
+	err = c.Watch(&source.Kind{Type: &v1.ConfigMap{}}, &handler.EnqueueRequestsFromMapFunc{
+		ToRequests: handler.ToRequestsFunc(func(obj handler.MapObject) []reconcile.Request {
+			return s3sReferencingConfigMap(mgr.GetClient(), obj.Meta.GetNamespace(), obj.Meta.GetName())
+		}),
+	})
+	if err != nil {
+		return err
+	}
+
 	return nil
 }
 
@@ -73,12 +162,22 @@ func add(mgr manager.Manager, r reconcile.Reconciler) error {
 var _ reconcile.Reconciler = &ReconcileS3{}
 
 // ReconcileS3 reconciles a S3 object
+// Reconciles run concurrently, anything specific to a single CR must stay local to Reconcile
 type ReconcileS3 struct {
-	client    client.Client
-	scheme    *runtime.Scheme
-	s3Client  s3iface.S3API
-	iamClient iamiface.IAMAPI
-	recorder  record.EventRecorder
+	client   client.Client
+	scheme   *runtime.Scheme
+	recorder record.EventRecorder
+	clients  *utils.ClientPool
+
+	policyValidation bool
+	// operator wide baseline, see baselinePolicy for the namespace overrides
+	baseline agillv1beta1.BaselinePolicy
+	// public buckets wait for a BucketApproval
+	requireApproval bool
+	// principals keeping access to locked down buckets, next to the operator itself
+	lockdownAdmins []string
+	// existing users without owner tags are adopted, see utils.IAMUserOwner
+	adoptUntagged bool
 }
 
 // Reconcile reads that state of the cluster for a S3 object and makes changes based on the state read
@@ -88,7 +187,7 @@ func (r *ReconcileS3) Reconcile(request reconcile.Request) (reconcile.Result, er
 	reqLogger.Info("Reconciling S3")
 
 	// Fetch the S3 instance
-	cr := &agillv1alpha1.S3{}
+	cr := &agillv1beta1.S3{}
 	err := r.client.Get(context.TODO(), request.NamespacedName, cr)
 	if err != nil {
 		if errors.IsNotFound(err) {
@@ -97,25 +196,56 @@ func (r *ReconcileS3) Reconcile(request reconcile.Request) (reconcile.Result, er
 		return reconcile.Result{}, err
 	}
 
+	// terminal errors are not retried until the spec changes or the stall expires, deletes are always retried
+	if cr.GetDeletionTimestamp() == nil && cr.IsStalled() {
+		// the lockdown is a kill switch, it is applied and lifted even though nothing else of the CR reconciles.
+		// The annotation does not bump the generation, so it would never clear the stall.
+		if cr.LockdownReason() != "" || cr.Status.Lockdown != nil {
+			if errHandlingLockdown := r.handleStalledLockdown(cr); errHandlingLockdown != nil {
+				return reconcile.Result{}, errHandlingLockdown
+			}
+		}
+		retryAfter := agillv1beta1.StalledRetryAfter(cr.Status.Conditions, cr.GetGeneration())
+		reqLogger.Info("CR stalled on a terminal error, waiting for a spec change", "retryAfter", retryAfter.String())
+		return reconcile.Result{RequeueAfter: retryAfter}, nil
+	}
+
+	result, err := r.reconcile(cr, reqLogger)
+	return status.HandleReconcileError(S3_CONTROLLER, cr, result, err, r.client, r.recorder, reqLogger)
+}
+
+func (r *ReconcileS3) reconcile(cr *agillv1beta1.S3, reqLogger logr.Logger) (reconcile.Result, error) {
 	// add finalizer
 	if errAddingFinalizer := utils.AddFinalizer(utils.S3_FINALIZER, r.client, cr); errAddingFinalizer != nil {
 		reqLogger.Error(errAddingFinalizer, "Failed to add s3 finalizer, requeue with exponential back-off")
 		return reconcile.Result{}, errAddingFinalizer
 	}
 
-	// set up s3 and iam client
-	r.s3Client = utils.S3Client(cr.Spec.Region)
-	r.iamClient = utils.IAMClient(cr.Spec.Region)
+	// get s3 and iam client for this CR, in the region the bucket was created in
+	s3Client, errGettingClient := r.clients.S3(cr.GetRegion())
+	if errGettingClient != nil {
+		return reconcile.Result{}, errGettingClient
+	}
+	iamClient, errGettingClient := r.clients.IAM(cr.GetRegion())
+	if errGettingClient != nil {
+		return reconcile.Result{}, errGettingClient
+	}
 
 	// handle delete
 	if cr.GetDeletionTimestamp() != nil {
-		if errSettingStatus := setStatus("Deleting", cr, r.client); errSettingStatus != nil {
+		if errSettingStatus := status.SetPhase(agillv1beta1.PHASE_DELETING, cr, r.client); errSettingStatus != nil {
 			return reconcile.Result{}, errSettingStatus
 		}
-		if errDeletingBucket := DeleteBucket(cr.Spec.BucketName, r.s3Client); errDeletingBucket != nil {
+		// the previous bucket policy is put back first, so nothing of the lockdown is left behind should deleting fail
+		if cr.Status.Lockdown != nil {
+			if errLifting := r.liftLockdown(cr, s3Client, iamClient); errLifting != nil {
+				return reconcile.Result{}, errLifting
+			}
+		}
+		if errDeletingBucket := DeleteBucket(cr.GetBucketName(), s3Client); errDeletingBucket != nil {
 			return reconcile.Result{}, errDeletingBucket
 		}
-		if errDeletingUser := DeleteUser(cr.Spec.IAMUserSpec.Username, r.iamClient); errDeletingUser != nil {
+		if errDeletingUser := r.handleDeleteIamResources(cr, iamClient); errDeletingUser != nil {
 			return reconcile.Result{}, errDeletingUser
 		}
 		if errRemovingFinalizers := utils.RemoveFinalizer(utils.S3_FINALIZER, cr, r.client); errRemovingFinalizers != nil {
@@ -127,20 +257,73 @@ func (r *ReconcileS3) Reconcile(request reconcile.Request) (reconcile.Result, er
 		return reconcile.Result{}, nil
 	}
 
+	// a lockdown is applied before anything else can fail, nothing else is reconciled until it is lifted
+	if locked, errHandlingLockdown := r.handleLockdown(cr, s3Client, iamClient); errHandlingLockdown != nil || locked {
+		return reconcile.Result{}, errHandlingLockdown
+	}
+
+	// never act on a spec that points at a different bucket than the one recorded, it would orphan the recorded one
+	if changed := cr.ChangedImmutableFields(); len(changed) > 0 {
+		return reconcile.Result{}, customErrors.ErrorTerminal{
+			Reason: "ImmutableFieldChanged",
+			Message: fmt.Sprintf("%v changed after the bucket was created, revert to the values in status or create a new CR",
+				strings.Join(changed, ", ")),
+		}
+	}
+
+	// built once, everything below checks, validates and puts the same policy
+	bucketPolicy, errBuildingPolicy := r.desiredBucketPolicy(cr)
+	if errBuildingPolicy != nil {
+		return reconcile.Result{}, errBuildingPolicy
+	}
+
+	// nothing is created or changed for CRs violating a guardrail, the S3Guardrail and S3 watches retry once it complies
+	if violated, errCheckingGuardrails := r.checkGuardrails(cr, bucketPolicy); errCheckingGuardrails != nil || violated {
+		return reconcile.Result{}, errCheckingGuardrails
+	}
+
+	// public buckets wait for a BucketApproval of their current spec, the BucketApproval watch picks them up again
+	if awaiting, errCheckingApproval := r.checkApproval(cr, bucketPolicy); errCheckingApproval != nil || awaiting {
+		return reconcile.Result{}, errCheckingApproval
+	}
+
+	// new buckets and users wait for quota, checked again periodically since freeing quota does not touch this CR
+	if blocked, errCheckingQuota := quotas.Enforce(r.client, r.recorder, cr); errCheckingQuota != nil || blocked {
+		return reconcile.Result{RequeueAfter: utils.DEFAULT_QUOTA_REQUEUE_AFTER}, errCheckingQuota
+	}
+
+	// create or adopt the bucket first, so everything else uses its final name
+	if errCreatingBucket := r.createBucket(cr, s3Client); errCreatingBucket != nil {
+		if _, ok := errCreatingBucket.(customErrors.ErrorGeneratedBucketNameTaken); ok {
+			return reconcile.Result{Requeue: true}, nil
+		}
+		return reconcile.Result{}, errCreatingBucket
+	}
+
+	// nothing is applied when a policy does not pass validation
+	if errValidatingPolicies := r.validatePolicies(cr, bucketPolicy); errValidatingPolicies != nil {
+		return reconcile.Result{}, errValidatingPolicies
+	}
+
 	// create/update all IAM related resources ( user, inline policy, access keys, k8s secrets )
-	if errCreatingIAMResources := r.handleCreateIamResources(cr); errCreatingIAMResources != nil {
+	if errCreatingIAMResources := r.handleCreateIamResources(cr, iamClient); errCreatingIAMResources != nil {
 		if _, ok := errCreatingIAMResources.(customErrors.ErrorIAMK8SSecretNeedsUpdate); ok {
 			return reconcile.Result{Requeue: true}, nil
 		}
 		return reconcile.Result{}, errCreatingIAMResources
 	}
 
-	// create/update all S3 related resources ( bucket, k8s external name service )
-	if errCreatingS3Resources := r.handleCreateS3Resources(cr); errCreatingS3Resources != nil {
+	// update all S3 related resources ( bucket properties, k8s external name service )
+	if errCreatingS3Resources := r.handleCreateS3Resources(cr, bucketPolicy, s3Client); errCreatingS3Resources != nil {
 		return reconcile.Result{}, errCreatingS3Resources
 	}
 
-	if errSettingStatus := setStatus("Ready", cr, r.client); errSettingStatus != nil {
+	// counted against the bytes quota of the namespace
+	if errRefreshingSize := r.refreshBucketSize(cr); errRefreshingSize != nil {
+		return reconcile.Result{}, errRefreshingSize
+	}
+
+	if errSettingStatus := status.SetPhase(agillv1beta1.PHASE_READY, cr, r.client); errSettingStatus != nil {
 		return reconcile.Result{}, errSettingStatus
 	}
 
@@ -0,0 +1,186 @@
+package scanner
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	agillv1beta1 "github.com/agill17/s3-operator/pkg/apis/agill/v1beta1"
+	"github.com/agill17/s3-operator/pkg/utils"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/iam"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// severity of each check
+var severities = map[string]agillv1beta1.Severity{
+	agillv1beta1.CHECK_PUBLIC_ACL:          agillv1beta1.SEVERITY_CRITICAL,
+	agillv1beta1.CHECK_PUBLIC_POLICY:       agillv1beta1.SEVERITY_CRITICAL,
+	agillv1beta1.CHECK_ENCRYPTION_MISSING:  agillv1beta1.SEVERITY_HIGH,
+	agillv1beta1.CHECK_ACCESS_KEY_AGE:      agillv1beta1.SEVERITY_HIGH,
+	agillv1beta1.CHECK_BROAD_POLICY:        agillv1beta1.SEVERITY_MEDIUM,
+	agillv1beta1.CHECK_VERSIONING_DISABLED: agillv1beta1.SEVERITY_LOW,
+}
+
+// grantees that make a bucket ACL public, AuthenticatedUsers is any AWS account
+var publicGrantees = map[string]string{
+	"http://acs.amazonaws.com/groups/global/AllUsers":           "AllUsers",
+	"http://acs.amazonaws.com/groups/global/AuthenticatedUsers": "AuthenticatedUsers",
+}
+
+// finding is a failed check, keyed by check
+type finding struct {
+	check   string
+	message string
+}
+
+// scanBucket runs every check against the live configuration of the bucket
+func (s *scanner) scanBucket(cr *agillv1beta1.S3) ([]finding, error) {
+	s3Client, err := s.clients.S3(cr.GetRegion())
+	if err != nil {
+		return nil, err
+	}
+	bucketName := cr.GetBucketName()
+	var findings []finding
+
+	acl, err := utils.GetBucketACL(bucketName, s3Client)
+	if err != nil {
+		return nil, err
+	}
+	var publicGrants []string
+	for _, g := range acl.Grants {
+		if g.Grantee == nil || aws.StringValue(g.Grantee.Type) != s3.TypeGroup {
+			continue
+		}
+		if group, found := publicGrantees[aws.StringValue(g.Grantee.URI)]; found {
+			publicGrants = append(publicGrants, fmt.Sprintf("%v to %v", aws.StringValue(g.Permission), group))
+		}
+	}
+	if len(publicGrants) > 0 {
+		findings = append(findings, finding{agillv1beta1.CHECK_PUBLIC_ACL, "bucket ACL grants " + strings.Join(publicGrants, ", ")})
+	}
+
+	policy, err := utils.GetBucketPolicy(bucketName, s3Client)
+	if err != nil {
+		return nil, err
+	}
+	if policy != "" {
+		doc, err := agillv1beta1.ParsePolicyDocument(policy)
+		if err != nil {
+			return nil, err
+		}
+		findings = append(findings, policyFindings(*doc)...)
+	}
+
+	encrypted, err := utils.HasDefaultEncryption(bucketName, s3Client)
+	if err != nil {
+		return nil, err
+	}
+	if !encrypted {
+		findings = append(findings, finding{agillv1beta1.CHECK_ENCRYPTION_MISSING, "bucket has no default encryption configuration"})
+	}
+
+	versioning, err := s3Client.GetBucketVersioning(&s3.GetBucketVersioningInput{Bucket: aws.String(bucketName)})
+	if err != nil {
+		return nil, err
+	}
+	if aws.StringValue(versioning.Status) != s3.BucketVersioningStatusEnabled {
+		status := aws.StringValue(versioning.Status)
+		if status == "" {
+			status = "never enabled"
+		}
+		findings = append(findings, finding{agillv1beta1.CHECK_VERSIONING_DISABLED, "versioning is " + status})
+	}
+
+	oldKeys, err := s.oldAccessKeys(cr)
+	if err != nil {
+		return nil, err
+	}
+	if len(oldKeys) > 0 {
+		findings = append(findings, finding{agillv1beta1.CHECK_ACCESS_KEY_AGE,
+			fmt.Sprintf("active access keys older than %v: %v", s.maxAccessKeyAge, strings.Join(oldKeys, ", "))})
+	}
+	return findings, nil
+}
+
+// policyFindings reports public and overly broad Allow statements of a bucket policy
+func policyFindings(doc agillv1beta1.PolicyDocument) []finding {
+	var public, broad []string
+	for i, statement := range doc.Statements {
+		if statement.Effect != "Allow" {
+			continue
+		}
+		name := statement.SID
+		if name == "" {
+			name = fmt.Sprintf("#%v", i)
+		}
+		if statement.Principal.IsPublic() {
+			public = append(public, name)
+		}
+		// generated statements are scoped by the operator, only hand written ones can be broader than expected
+		if strings.HasPrefix(statement.SID, agillv1beta1.GENERATED_SID_PREFIX) {
+			continue
+		}
+		for _, action := range statement.Action {
+			if action == "*" || action == "s3:*" {
+				broad = append(broad, fmt.Sprintf("%v ( %v )", name, action))
+				break
+			}
+		}
+	}
+
+	var findings []finding
+	if len(public) > 0 {
+		findings = append(findings, finding{agillv1beta1.CHECK_PUBLIC_POLICY,
+			`bucket policy allows Principal "*" in statements ` + strings.Join(public, ", ")})
+	}
+	if len(broad) > 0 {
+		findings = append(findings, finding{agillv1beta1.CHECK_BROAD_POLICY,
+			"bucket policy allows every action in statements " + strings.Join(broad, ", ")})
+	}
+	return findings
+}
+
+// oldAccessKeys lists the active keys older than maxAccessKeyAge of the bucket user, the referenced IAMUser CR user
+// and the consumers, as <username>/<access key id>
+func (s *scanner) oldAccessKeys(cr *agillv1beta1.S3) ([]string, error) {
+	iamClient, err := s.clients.IAM(cr.GetRegion())
+	if err != nil {
+		return nil, err
+	}
+
+	var usernames []string
+	switch {
+	case cr.UsesIAMUserRef():
+		iamUser := &agillv1beta1.IAMUser{}
+		key := types.NamespacedName{Name: cr.Spec.IAMUserRef.Name, Namespace: cr.GetNamespace()}
+		if err := s.client.Get(context.TODO(), key, iamUser); err == nil && iamUser.Status.Username != "" {
+			usernames = append(usernames, iamUser.Status.Username)
+		}
+	case cr.GetUsername() != "":
+		usernames = append(usernames, cr.GetUsername())
+	}
+	for _, c := range cr.Status.Consumers {
+		usernames = append(usernames, c.Username)
+	}
+
+	var old []string
+	for _, username := range usernames {
+		keys, err := iamClient.ListAccessKeys(&iam.ListAccessKeysInput{UserName: aws.String(username)})
+		if err != nil {
+			if isNoSuchEntity(err) {
+				// not created yet, or already deleted
+				continue
+			}
+			return nil, err
+		}
+		for _, k := range keys.AccessKeyMetadata {
+			if aws.StringValue(k.Status) == iam.StatusTypeActive && time.Since(aws.TimeValue(k.CreateDate)) > s.maxAccessKeyAge {
+				old = append(old, fmt.Sprintf("%v/%v", username, aws.StringValue(k.AccessKeyId)))
+			}
+		}
+	}
+	return old, nil
+}
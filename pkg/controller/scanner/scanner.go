@@ -0,0 +1,206 @@
+package scanner
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+
+	agillv1beta1 "github.com/agill17/s3-operator/pkg/apis/agill/v1beta1"
+	"github.com/agill17/s3-operator/pkg/controller/options"
+	"github.com/agill17/s3-operator/pkg/metrics"
+	"github.com/agill17/s3-operator/pkg/utils"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/iam"
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+)
+
+const SCANNER = "s3Scanner"
+
+var log = logf.Log.WithName("s3_scanner")
+
+// scanner periodically checks the live configuration of every managed bucket and keeps one S3Finding per failed check.
+// Admission and reconcile only see the spec, the scanner catches what was changed in AWS directly.
+type scanner struct {
+	client          client.Client
+	apiReader       client.Reader
+	scheme          *runtime.Scheme
+	recorder        record.EventRecorder
+	clients         *utils.ClientPool
+	interval        time.Duration
+	maxAccessKeyAge time.Duration
+}
+
+// Add registers the scanner with the manager, it is started and stopped along with the controllers
+func Add(mgr manager.Manager, opts options.Options) error {
+	if opts.ScanInterval <= 0 {
+		log.Info("Security posture scanner is disabled")
+		return nil
+	}
+	s := &scanner{
+		client:          mgr.GetClient(),
+		apiReader:       mgr.GetAPIReader(),
+		scheme:          mgr.GetScheme(),
+		recorder:        mgr.GetEventRecorderFor(SCANNER),
+		clients:         opts.Clients,
+		interval:        opts.ScanInterval,
+		maxAccessKeyAge: opts.MaxAccessKeyAge,
+	}
+	return mgr.Add(manager.RunnableFunc(s.run))
+}
+
+func (s *scanner) run(stop <-chan struct{}) error {
+	log.Info(fmt.Sprintf("Scanning every bucket every %v", s.interval))
+	wait.Until(s.scanAll, s.interval, stop)
+	return nil
+}
+
+// scanAll scans the buckets one after the other, AWS calls go through the shared rate limiters so reconciles are not starved
+func (s *scanner) scanAll() {
+	s3List := &agillv1beta1.S3List{}
+	if err := s.client.List(context.TODO(), s3List); err != nil {
+		log.Error(err, "Failed to list S3 CRs, skipping scan")
+		return
+	}
+	for i := range s3List.Items {
+		cr := &s3List.Items[i]
+		// buckets are only known once created, deleted ones take their findings with them
+		if cr.Status.BucketName == "" || cr.GetDeletionTimestamp() != nil {
+			continue
+		}
+		if err := s.scan(cr); err != nil {
+			log.Error(err, "Failed to scan bucket, keeping its previous findings", "Namespace", cr.GetNamespace(), "Name", cr.GetName())
+			metrics.ScanErrorsTotal.WithLabelValues(cr.GetNamespace(), cr.GetName()).Inc()
+		}
+	}
+	s.updateMetrics()
+}
+
+func (s *scanner) scan(cr *agillv1beta1.S3) error {
+	findings, err := s.scanBucket(cr)
+	if err != nil {
+		return err
+	}
+	return s.syncFindings(cr, findings)
+}
+
+// syncFindings creates or refreshes an S3Finding per finding and deletes the ones of checks that pass again
+func (s *scanner) syncFindings(cr *agillv1beta1.S3, findings []finding) error {
+	now := metav1.Now()
+	current := map[string]bool{}
+	for _, f := range findings {
+		name := findingName(cr, f.check)
+		current[name] = true
+		if err := s.upsertFinding(cr, name, f, now); err != nil {
+			return err
+		}
+	}
+
+	existing := &agillv1beta1.S3FindingList{}
+	if err := s.client.List(context.TODO(), existing, client.InNamespace(cr.GetNamespace())); err != nil {
+		return err
+	}
+	for i := range existing.Items {
+		f := &existing.Items[i]
+		if f.Spec.BucketRef.Name != cr.GetName() || current[f.GetName()] {
+			continue
+		}
+		s.recorder.Eventf(cr, v1.EventTypeNormal, "FINDING_RESOLVED", "%v: check passes again", f.Spec.Check)
+		if err := s.client.Delete(context.TODO(), f); err != nil && !apierrors.IsNotFound(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *scanner) upsertFinding(cr *agillv1beta1.S3, name string, f finding, now metav1.Time) error {
+	spec := agillv1beta1.S3FindingSpec{
+		BucketRef:  v1.LocalObjectReference{Name: cr.GetName()},
+		BucketName: cr.GetBucketName(),
+		Check:      f.check,
+		Severity:   severities[f.check],
+		Message:    f.message,
+	}
+
+	existing := &agillv1beta1.S3Finding{}
+	err := s.client.Get(context.TODO(), types.NamespacedName{Name: name, Namespace: cr.GetNamespace()}, existing)
+	if err != nil && !apierrors.IsNotFound(err) {
+		return err
+	}
+
+	if apierrors.IsNotFound(err) {
+		existing = &agillv1beta1.S3Finding{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: cr.GetNamespace()},
+			Spec:       spec,
+		}
+		// garbage collected along with the bucket
+		if err := controllerutil.SetControllerReference(cr, existing, s.scheme); err != nil {
+			return err
+		}
+		if err := s.client.Create(context.TODO(), existing); err != nil {
+			return err
+		}
+		s.recorder.Eventf(cr, v1.EventTypeWarning, "FINDING", "%v ( %v ): %v", f.check, spec.Severity, f.message)
+	} else if existing.Spec != spec {
+		existing.Spec = spec
+		if err := utils.UpdateCr(existing, s.client); err != nil {
+			return err
+		}
+	}
+
+	// lastSeen is only refreshed once it is stale, writing every open finding on every scan adds up across the cluster
+	if existing.Status.FirstSeen != nil && existing.Status.LastSeen != nil &&
+		now.Sub(existing.Status.LastSeen.Time) < utils.FINDING_LAST_SEEN_REFRESH_INTERVAL {
+		return nil
+	}
+	if existing.Status.FirstSeen == nil {
+		existing.Status.FirstSeen = &now
+	}
+	existing.Status.LastSeen = &now
+	return utils.UpdateCrStatus(existing, s.client)
+}
+
+// updateMetrics publishes the open findings of the whole cluster, so findings of deleted buckets disappear too.
+// Read from the API server, the cache may not have the findings written by this scan yet.
+func (s *scanner) updateMetrics() {
+	findings := &agillv1beta1.S3FindingList{}
+	if err := s.apiReader.List(context.TODO(), findings); err != nil {
+		log.Error(err, "Failed to list S3Findings, metrics are not updated")
+		return
+	}
+	metrics.Findings.Reset()
+	for _, f := range findings.Items {
+		metrics.Findings.WithLabelValues(f.GetNamespace(), f.Spec.BucketRef.Name, f.Spec.Check, string(f.Spec.Severity)).Set(1)
+	}
+}
+
+// findingName is <S3 CR name>-<check>, e.g: my-bucket-publicacl
+// findingName is <cr name>-<check>, long CR names are cut and get a hash of the full name so the name stays a valid object name
+func findingName(cr *agillv1beta1.S3, check string) string {
+	name := fmt.Sprintf("%v-%v", cr.GetName(), strings.ToLower(check))
+	if len(name) <= validation.DNS1123SubdomainMaxLength {
+		return name
+	}
+	sum := sha256.Sum256([]byte(name))
+	hash := hex.EncodeToString(sum[:])[:utils.BUCKET_NAME_HASH_LENGTH]
+	// cut names may end in a dot or dash, which an object name cannot
+	return fmt.Sprintf("%v-%v", strings.TrimRight(name[:validation.DNS1123SubdomainMaxLength-utils.BUCKET_NAME_HASH_LENGTH-1], ".-"), hash)
+}
+
+func isNoSuchEntity(err error) bool {
+	awsErr, ok := err.(awserr.Error)
+	return ok && awsErr.Code() == iam.ErrCodeNoSuchEntityException
+}
@@ -0,0 +1,54 @@
+package status
+
+import (
+	customErrors "github.com/agill17/s3-operator/pkg/controller/errors"
+	"github.com/agill17/s3-operator/pkg/metrics"
+	"github.com/agill17/s3-operator/pkg/utils"
+	"github.com/go-logr/logr"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// HandleReconcileError decides how a failed reconcile is retried
+//   - throttled: requeue with jitter
+//   - terminal: mark the CR Stalled and retry after DEFAULT_STALLED_RETRY_AFTER, or once the spec changes.
+//     Deletes are retried with back-off, the finalizer must not be left behind.
+//   - transient: hand the error back to controller-runtime for exponential back-off
+func HandleReconcileError(controller string, cr Object, result reconcile.Result, err error, client client.Client, recorder record.EventRecorder, reqLogger logr.Logger) (reconcile.Result, error) {
+	if err == nil {
+		// nothing left to update once the finalizer is gone
+		if cr.GetDeletionTimestamp() != nil {
+			return result, nil
+		}
+		if errClearing := ClearStalled(cr, client); errClearing != nil {
+			return reconcile.Result{}, errClearing
+		}
+		return result, nil
+	}
+
+	class, code := customErrors.Classify(err), customErrors.Code(err)
+	metrics.ReconcileErrorsTotal.WithLabelValues(controller, string(class), code).Inc()
+
+	if utils.IsThrottlingError(err) {
+		// back-off with jitter instead of the rate limited queue, which would hammer AWS again right away
+		requeueAfter := utils.ThrottledRequeueAfter()
+		reqLogger.Info("AWS API calls are throttled, requeue with jitter", "requeueAfter", requeueAfter.String(), "error", err.Error())
+		return reconcile.Result{RequeueAfter: requeueAfter}, nil
+	}
+
+	if class == customErrors.ErrorClassTerminal {
+		reqLogger.Error(err, "Terminal error, will not retry until the spec changes or the stall expires", "code", code)
+		recorder.Eventf(cr, v1.EventTypeWarning, "STALLED", "%v: %v", code, err)
+		if errSettingStalled := SetStalled(code, err.Error(), cr, client); errSettingStalled != nil {
+			return reconcile.Result{}, errSettingStalled
+		}
+		if cr.GetDeletionTimestamp() != nil {
+			return result, err
+		}
+		return reconcile.Result{RequeueAfter: utils.DEFAULT_STALLED_RETRY_AFTER}, nil
+	}
+
+	return result, err
+}
@@ -0,0 +1,74 @@
+package status
+
+import (
+	"github.com/agill17/s3-operator/pkg/apis/agill/v1beta1"
+	"github.com/agill17/s3-operator/pkg/utils"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// Object is a CR that reports a phase and conditions in its status, e.g: S3, IAMUser, BucketAccessRequest
+type Object interface {
+	runtime.Object
+	metav1.Object
+	GetPhase() v1beta1.Phase
+	SetPhase(phase v1beta1.Phase)
+	GetConditions() *[]v1beta1.Condition
+}
+
+// SetPhase updates the phase and the Ready condition that goes with it
+func SetPhase(phase v1beta1.Phase, cr Object, client client.Client) error {
+	ready := v1beta1.Condition{
+		Type:               v1beta1.CONDITION_READY,
+		Status:             v1.ConditionFalse,
+		ObservedGeneration: cr.GetGeneration(),
+		Reason:             string(phase),
+	}
+	if phase == v1beta1.PHASE_READY {
+		ready.Status = v1.ConditionTrue
+	}
+	conditionChanged := v1beta1.SetCondition(cr.GetConditions(), ready)
+	if cr.GetPhase() != phase || conditionChanged {
+		cr.SetPhase(phase)
+		return utils.UpdateCrStatus(cr, client)
+	}
+	return nil
+}
+
+// SetStalled marks the CR Stalled after a terminal error
+func SetStalled(reason, msg string, cr Object, client client.Client) error {
+	cr.SetPhase(v1beta1.PHASE_STALLED)
+	v1beta1.SetCondition(cr.GetConditions(), v1beta1.Condition{
+		Type:               v1beta1.CONDITION_READY,
+		Status:             v1.ConditionFalse,
+		ObservedGeneration: cr.GetGeneration(),
+		Reason:             reason,
+		Message:            msg,
+	})
+	v1beta1.SetCondition(cr.GetConditions(), v1beta1.Condition{
+		Type:               v1beta1.CONDITION_STALLED,
+		Status:             v1.ConditionTrue,
+		ObservedGeneration: cr.GetGeneration(),
+		Reason:             reason,
+		Message:            msg,
+	})
+	// a retry of an expired stall that failed again waits the full DEFAULT_STALLED_RETRY_AFTER, see v1beta1.StalledRetryAfter
+	v1beta1.GetCondition(*cr.GetConditions(), v1beta1.CONDITION_STALLED).LastTransitionTime = metav1.Now()
+	return utils.UpdateCrStatus(cr, client)
+}
+
+// ClearStalled resets the Stalled condition once a reconcile succeeds
+func ClearStalled(cr Object, client client.Client) error {
+	if !v1beta1.IsConditionTrue(*cr.GetConditions(), v1beta1.CONDITION_STALLED) {
+		return nil
+	}
+	v1beta1.SetCondition(cr.GetConditions(), v1beta1.Condition{
+		Type:               v1beta1.CONDITION_STALLED,
+		Status:             v1.ConditionFalse,
+		ObservedGeneration: cr.GetGeneration(),
+		Reason:             "ReconcileSucceeded",
+	})
+	return utils.UpdateCrStatus(cr, client)
+}
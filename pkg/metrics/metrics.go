@@ -0,0 +1,45 @@
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+// operator specific metrics, served next to the controller-runtime metrics
+var (
+	AWSRateLimiterWaitSeconds = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "s3_operator_aws_rate_limiter_wait_seconds",
+		Help:    "Time AWS API calls waited on the client side rate limiter",
+		Buckets: prometheus.ExponentialBuckets(0.001, 2, 16),
+	}, []string{"account", "api_family"})
+
+	AWSThrottledRequestsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "s3_operator_aws_throttled_requests_total",
+		Help: "AWS API calls rejected with a throttling error",
+	}, []string{"account", "api_family"})
+
+	ReconcileErrorsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "s3_operator_reconcile_errors_total",
+		Help: "Failed reconciles by error class ( Terminal, Transient ) and error code",
+	}, []string{"controller", "class", "code"})
+
+	Findings = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "s3_operator_findings",
+		Help: "Open S3Findings of the security posture scanner, 1 per bucket and failed check",
+	}, []string{"namespace", "bucket", "check", "severity"})
+
+	ScanErrorsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "s3_operator_scan_errors_total",
+		Help: "Buckets the security posture scanner failed to scan, their previous findings are kept",
+	}, []string{"namespace", "bucket"})
+)
+
+func init() {
+	metrics.Registry.MustRegister(
+		AWSRateLimiterWaitSeconds,
+		AWSThrottledRequestsTotal,
+		ReconcileErrorsTotal,
+		Findings,
+		ScanErrorsTotal,
+	)
+}
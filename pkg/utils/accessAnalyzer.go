@@ -0,0 +1,104 @@
+package utils
+
+import (
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/accessanalyzer"
+)
+
+// Access Analyzer ValidatePolicy finding types
+const (
+	POLICY_FINDING_ERROR            = "ERROR"
+	POLICY_FINDING_SECURITY_WARNING = "SECURITY_WARNING"
+	POLICY_FINDING_WARNING          = "WARNING"
+	POLICY_FINDING_SUGGESTION       = "SUGGESTION"
+)
+
+// ValidatePolicy policy types
+const (
+	POLICY_TYPE_IDENTITY = "IDENTITY_POLICY"
+	POLICY_TYPE_RESOURCE = "RESOURCE_POLICY"
+)
+
+// resource type passed with resource policies, enables the S3 specific checks
+const VALIDATE_POLICY_RESOURCE_TYPE_S3_BUCKET = "AWS::S3::Bucket"
+
+// PolicyFinding is a single ValidatePolicy finding
+type PolicyFinding struct {
+	FindingType    string
+	IssueCode      string
+	FindingDetails string
+	LearnMoreLink  string
+}
+
+// PolicyValidator runs Access Analyzer policy checks
+type PolicyValidator interface {
+	// ValidatePolicy returns every finding for the document, resourceType is only used for resource policies
+	ValidatePolicy(document, policyType, resourceType string) ([]PolicyFinding, error)
+}
+
+// the vendored sdk predates ValidatePolicy, so the operation is described here and sent through the
+// Access Analyzer client, which already knows how to sign and (un)marshal rest-json requests
+type validatePolicyInput struct {
+	_ struct{} `type:"structure"`
+
+	NextToken                  *string `location:"querystring" locationName:"nextToken" type:"string"`
+	PolicyDocument             *string `locationName:"policyDocument" type:"string" required:"true"`
+	PolicyType                 *string `locationName:"policyType" type:"string" required:"true"`
+	ValidatePolicyResourceType *string `locationName:"validatePolicyResourceType" type:"string"`
+}
+
+type validatePolicyOutput struct {
+	_ struct{} `type:"structure"`
+
+	Findings  []*validatePolicyFinding `locationName:"findings" type:"list"`
+	NextToken *string                  `locationName:"nextToken" type:"string"`
+}
+
+type validatePolicyFinding struct {
+	_ struct{} `type:"structure"`
+
+	FindingDetails *string `locationName:"findingDetails" type:"string"`
+	FindingType    *string `locationName:"findingType" type:"string"`
+	IssueCode      *string `locationName:"issueCode" type:"string"`
+	LearnMoreLink  *string `locationName:"learnMoreLink" type:"string"`
+}
+
+type accessAnalyzerValidator struct {
+	client *accessanalyzer.AccessAnalyzer
+}
+
+func (v accessAnalyzerValidator) ValidatePolicy(document, policyType, resourceType string) ([]PolicyFinding, error) {
+	input := &validatePolicyInput{
+		PolicyDocument: aws.String(document),
+		PolicyType:     aws.String(policyType),
+	}
+	if resourceType != "" {
+		input.ValidatePolicyResourceType = aws.String(resourceType)
+	}
+
+	var findings []PolicyFinding
+	for {
+		output := &validatePolicyOutput{}
+		req := v.client.NewRequest(&request.Operation{
+			Name:       "ValidatePolicy",
+			HTTPMethod: "POST",
+			HTTPPath:   "/policy/validation",
+		}, input, output)
+		if err := req.Send(); err != nil {
+			return nil, err
+		}
+		for _, f := range output.Findings {
+			findings = append(findings, PolicyFinding{
+				FindingType:    aws.StringValue(f.FindingType),
+				IssueCode:      aws.StringValue(f.IssueCode),
+				FindingDetails: aws.StringValue(f.FindingDetails),
+				LearnMoreLink:  aws.StringValue(f.LearnMoreLink),
+			})
+		}
+		if aws.StringValue(output.NextToken) == "" {
+			return findings, nil
+		}
+		input.NextToken = output.NextToken
+	}
+}
@@ -1,29 +1,264 @@
 package utils
 
 import (
+	"fmt"
 	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/endpoints"
 	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/accessanalyzer"
+	"github.com/aws/aws-sdk-go/service/cloudwatch"
+	"github.com/aws/aws-sdk-go/service/cloudwatch/cloudwatchiface"
 	"github.com/aws/aws-sdk-go/service/iam"
 	"github.com/aws/aws-sdk-go/service/iam/iamiface"
 	"github.com/aws/aws-sdk-go/service/s3"
 	"github.com/aws/aws-sdk-go/service/s3/s3iface"
-	"math"
+	"github.com/aws/aws-sdk-go/service/sts"
+	"net/http"
+	"strings"
+	"sync"
 )
 
-func S3Client(region string) s3iface.S3API {
-	sess, _ := session.NewSession(&aws.Config{
-		CredentialsChainVerboseErrors: aws.Bool(true),
-		Region:                        aws.String(region),
-		MaxRetries:                    aws.Int(math.MaxInt64),
-	})
-	return s3.New(sess)
+// ClientConfig holds operator wide settings that apply to every AWS client
+type ClientConfig struct {
+	// use FIPS 140-2 validated endpoints for S3, IAM, STS, Access Analyzer and CloudWatch
+	UseFIPSEndpoint bool
+	// use dual-stack ( IPv4 + IPv6 ) endpoints for S3
+	UseDualStackEndpoint bool
+	// optional http client ( proxy, custom CA ), sdk default is used when nil
+	HTTPClient *http.Client
+	// retries per AWS API call before the error is handed back to the reconciler
+	MaxRetries int
+	// client side rate limits per AWS account, keyed by api family ( API_FAMILY_IAM, API_FAMILY_S3 )
+	RateLimits map[string]RateLimit
+}
+
+// S3EndpointHost returns the regional s3 hostname, honouring the FIPS and dual-stack settings.
+// e.g: s3.us-west-2.amazonaws.com, s3-fips.dualstack.us-gov-west-1.amazonaws.com, s3.cn-north-1.amazonaws.com.cn
+func (c ClientConfig) S3EndpointHost(region string) string {
+	host := "s3"
+	if c.UseFIPSEndpoint {
+		host = "s3-fips"
+	}
+	if c.UseDualStackEndpoint {
+		host = fmt.Sprintf("%v.dualstack", host)
+	}
+	return fmt.Sprintf("%v.%v.%v", host, region, PartitionForRegion(region).DNSSuffix())
+}
+
+type clientKey struct {
+	region        string
+	credentialsID string
 }
 
-func IAMClient(region string) iamiface.IAMAPI {
-	sess, _ := session.NewSession(&aws.Config{
+type regionClients struct {
+	s3         s3iface.S3API
+	iam        iamiface.IAMAPI
+	analyzer   PolicyValidator
+	cloudwatch cloudwatchiface.CloudWatchAPI
+}
+
+// ClientPool hands out AWS clients keyed by region and credentials.
+// Clients are safe for concurrent use, so every reconcile in the same region shares them.
+type ClientPool struct {
+	config   ClientConfig
+	base     *session.Session
+	limiters *rateLimiters
+
+	mu      sync.Mutex
+	clients map[clientKey]*regionClients
+	// account and principal of the current credentials, looked up once per credentials
+	account struct{ credentialsID, accountID, principalUserID string }
+}
+
+func NewClientPool(cfg ClientConfig) (*ClientPool, error) {
+	base, err := session.NewSession(&aws.Config{
 		CredentialsChainVerboseErrors: aws.Bool(true),
-		Region:                        aws.String(region),
-		MaxRetries:                    aws.Int(math.MaxInt64),
+		MaxRetries:                    aws.Int(cfg.MaxRetries),
+		HTTPClient:                    cfg.HTTPClient,
 	})
-	return iam.New(sess)
+	if err != nil {
+		return nil, err
+	}
+	return &ClientPool{
+		config:   cfg,
+		base:     base,
+		limiters: newRateLimiters(cfg.RateLimits),
+		clients:  map[clientKey]*regionClients{},
+	}, nil
+}
+
+func (p *ClientPool) Config() ClientConfig {
+	return p.config
+}
+
+func (p *ClientPool) S3(region string) (s3iface.S3API, error) {
+	c, err := p.get(region)
+	if err != nil {
+		return nil, err
+	}
+	return c.s3, nil
+}
+
+func (p *ClientPool) IAM(region string) (iamiface.IAMAPI, error) {
+	c, err := p.get(region)
+	if err != nil {
+		return nil, err
+	}
+	return c.iam, nil
+}
+
+// PolicyValidator returns an Access Analyzer backed policy validator for the region
+func (p *ClientPool) PolicyValidator(region string) (PolicyValidator, error) {
+	c, err := p.get(region)
+	if err != nil {
+		return nil, err
+	}
+	return c.analyzer, nil
+}
+
+// CloudWatch returns the client used to read bucket size metrics
+func (p *ClientPool) CloudWatch(region string) (cloudwatchiface.CloudWatchAPI, error) {
+	c, err := p.get(region)
+	if err != nil {
+		return nil, err
+	}
+	return c.cloudwatch, nil
+}
+
+// AccountID returns the AWS account the operator credentials belong to
+func (p *ClientPool) AccountID(region string) (string, error) {
+	// the sdk caches credentials until they expire, so this is cheap
+	creds, err := p.base.Config.Credentials.Get()
+	if err != nil {
+		return "", err
+	}
+	return p.accountID(region, creds.AccessKeyID)
+}
+
+// PrincipalUserID returns the aws:userid pattern matching every request of the operator credentials
+func (p *ClientPool) PrincipalUserID(region string) (string, error) {
+	creds, err := p.base.Config.Credentials.Get()
+	if err != nil {
+		return "", err
+	}
+	if _, err := p.accountID(region, creds.AccessKeyID); err != nil {
+		return "", err
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.account.principalUserID, nil
+}
+
+func (p *ClientPool) accountID(region, credentialsID string) (string, error) {
+	p.mu.Lock()
+	cached := p.account
+	p.mu.Unlock()
+	if cached.credentialsID == credentialsID {
+		return cached.accountID, nil
+	}
+
+	identity, err := sts.New(p.base.Copy(p.regionalConfig(STS_ENDPOINT_PREFIX, region))).GetCallerIdentity(&sts.GetCallerIdentityInput{})
+	if err != nil {
+		return "", err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.account.credentialsID, p.account.accountID = credentialsID, *identity.Account
+	p.account.principalUserID = principalUserID(*identity.UserId)
+	return *identity.Account, nil
+}
+
+// principalUserID turns the caller user ID into the aws:userid pattern of the principal, e.g: AROAEXAMPLE:session -> AROAEXAMPLE:*.
+// Role sessions share the unique ID of the role, which unlike a role ARN does not depend on the role path.
+// IAM users ( AIDAEXAMPLE ) and the account root are used as they are.
+func principalUserID(callerUserID string) string {
+	if i := strings.Index(callerUserID, ":"); i >= 0 {
+		return callerUserID[:i] + ":*"
+	}
+	return callerUserID
+}
+
+func (p *ClientPool) get(region string) (*regionClients, error) {
+	creds, err := p.base.Config.Credentials.Get()
+	if err != nil {
+		return nil, err
+	}
+	key := clientKey{region: region, credentialsID: creds.AccessKeyID}
+
+	// rate limiters are per account, so the account has to be known before building clients
+	accountID, err := p.accountID(region, creds.AccessKeyID)
+	if err != nil {
+		return nil, err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if c, found := p.clients[key]; found {
+		return c, nil
+	}
+
+	// drop clients built with credentials that have since been rotated
+	for k := range p.clients {
+		if k.region == region {
+			delete(p.clients, k)
+		}
+	}
+	s3Client := s3.New(p.base.Copy(p.s3Config(region)))
+	p.limiters.addRateLimiting(&s3Client.Handlers, accountID, API_FAMILY_S3)
+	iamClient := iam.New(p.base.Copy(p.iamConfig(region)))
+	p.limiters.addRateLimiting(&iamClient.Handlers, accountID, API_FAMILY_IAM)
+
+	// Access Analyzer is part of IAM and shares its low, account wide limits
+	analyzerClient := accessanalyzer.New(p.base.Copy(p.regionalConfig(ACCESS_ANALYZER_ENDPOINT_PREFIX, region)))
+	p.limiters.addRateLimiting(&analyzerClient.Handlers, accountID, API_FAMILY_IAM)
+
+	// bucket sizes are read at most once per BUCKET_SIZE_REFRESH_INTERVAL per bucket, well below the CloudWatch limits
+	cloudwatchClient := cloudwatch.New(p.base.Copy(p.regionalConfig(CLOUDWATCH_ENDPOINT_PREFIX, region)))
+
+	c := &regionClients{
+		s3:         s3Client,
+		iam:        iamClient,
+		analyzer:   accessAnalyzerValidator{client: analyzerClient},
+		cloudwatch: cloudwatchClient,
+	}
+	p.clients[key] = c
+	return c, nil
+}
+
+func (p *ClientPool) s3Config(region string) *aws.Config {
+	cfg := &aws.Config{
+		Region:       aws.String(region),
+		UseDualStack: aws.Bool(p.config.UseDualStackEndpoint),
+	}
+	// the sdk has no FIPS switch, so point it at the FIPS host directly ( dual-stack is part of the host )
+	if p.config.UseFIPSEndpoint {
+		cfg.Endpoint = aws.String(fmt.Sprintf("https://%v", p.config.S3EndpointHost(region)))
+	}
+	return cfg
+}
+
+func (p *ClientPool) iamConfig(region string) *aws.Config {
+	cfg := &aws.Config{Region: aws.String(region)}
+	if p.config.UseFIPSEndpoint {
+		if endpoint := iamFIPSEndpoint(region); endpoint != "" {
+			// IAM is global, requests to its endpoint are always signed for us-east-1
+			cfg.Endpoint = aws.String(endpoint)
+			cfg.Region = aws.String(endpoints.UsEast1RegionID)
+		}
+	}
+	return cfg
+}
+
+// regionalConfig is the config of the regional services next to S3 and IAM ( STS, Access Analyzer, CloudWatch ),
+// pointed at the FIPS endpoint of the service when FIPS is enabled and the region has one.
+// Dual-stack only applies to S3, the sdk has no dual-stack endpoints for these services.
+func (p *ClientPool) regionalConfig(endpointPrefix, region string) *aws.Config {
+	cfg := &aws.Config{Region: aws.String(region)}
+	if p.config.UseFIPSEndpoint {
+		if endpoint := regionalFIPSEndpoint(endpointPrefix, region); endpoint != "" {
+			cfg.Endpoint = aws.String(endpoint)
+		}
+	}
+	return cfg
 }
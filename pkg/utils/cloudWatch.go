@@ -0,0 +1,70 @@
+package utils
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/cloudwatch"
+	"github.com/aws/aws-sdk-go/service/cloudwatch/cloudwatchiface"
+)
+
+// storage types S3 reports BucketSizeBytes for, a bucket's size is the sum of all of them
+var bucketSizeStorageTypes = []string{
+	"StandardStorage",
+	"IntelligentTieringFAStorage",
+	"IntelligentTieringIAStorage",
+	"StandardIAStorage",
+	"OneZoneIAStorage",
+	"ReducedRedundancyStorage",
+	"GlacierStorage",
+	"DeepArchiveStorage",
+}
+
+// GetBucketSizeBytes returns the bytes stored in a bucket as published daily by S3 to CloudWatch.
+// New buckets have no datapoints for up to a day and are reported as empty.
+func GetBucketSizeBytes(bucketName string, cwClient cloudwatchiface.CloudWatchAPI) (int64, error) {
+	end := time.Now()
+	in := &cloudwatch.GetMetricDataInput{
+		StartTime: aws.Time(end.Add(-3 * 24 * time.Hour)),
+		EndTime:   aws.Time(end),
+		ScanBy:    aws.String(cloudwatch.ScanByTimestampDescending),
+	}
+	for i, storageType := range bucketSizeStorageTypes {
+		in.MetricDataQueries = append(in.MetricDataQueries, &cloudwatch.MetricDataQuery{
+			Id: aws.String(fmt.Sprintf("size%v", i)),
+			MetricStat: &cloudwatch.MetricStat{
+				Metric: &cloudwatch.Metric{
+					Namespace:  aws.String("AWS/S3"),
+					MetricName: aws.String("BucketSizeBytes"),
+					Dimensions: []*cloudwatch.Dimension{
+						{Name: aws.String("BucketName"), Value: aws.String(bucketName)},
+						{Name: aws.String("StorageType"), Value: aws.String(storageType)},
+					},
+				},
+				Period: aws.Int64(int64((24 * time.Hour).Seconds())),
+				Stat:   aws.String(cloudwatch.StatisticAverage),
+			},
+		})
+	}
+
+	// newest datapoint per storage type, results of a query may be split across pages
+	latest := map[string]float64{}
+	err := cwClient.GetMetricDataPages(in, func(out *cloudwatch.GetMetricDataOutput, lastPage bool) bool {
+		for _, result := range out.MetricDataResults {
+			if _, found := latest[aws.StringValue(result.Id)]; !found && len(result.Values) > 0 {
+				latest[aws.StringValue(result.Id)] = aws.Float64Value(result.Values[0])
+			}
+		}
+		return true
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	var size float64
+	for _, v := range latest {
+		size += v
+	}
+	return int64(size), nil
+}
@@ -1,6 +1,64 @@
 package utils
 
+import "time"
+
 const (
 	S3_FINALIZER  = "agill.apps.s3"
 	IAM_FINALIZER = "agill.apps.iam"
+	// BucketAccessRequests keep their IAM user until it is deleted
+	BUCKET_ACCESS_FINALIZER = "agill.apps.bucketaccess"
+
+	// retries per AWS API call, the reconciler requeues with back-off afterwards
+	DEFAULT_AWS_MAX_RETRIES = 5
+
+	// client side token bucket per AWS account, IAM limits are account wide and much lower than S3
+	DEFAULT_IAM_API_QPS   = 5
+	DEFAULT_IAM_API_BURST = 10
+	DEFAULT_S3_API_QPS    = 50
+	DEFAULT_S3_API_BURST  = 100
+
+	// generated bucket names, see GenerateBucketName
+	BUCKET_NAME_MAX_LENGTH  = 63
+	BUCKET_NAME_HASH_LENGTH = 10
+	// generated names are regenerated at most this many times when taken by another account
+	MAX_GENERATED_BUCKET_NAME_ATTEMPTS = 5
+
+	// generated iam usernames, see DefaultIAMUsername
+	IAM_USERNAME_MAX_LENGTH = 64
+	// kind suffixes of generated iam usernames, e.g: <namespace>.<name>.s3
+	IAM_USERNAME_SUFFIX_S3       = "s3"
+	IAM_USERNAME_SUFFIX_CONSUMER = "consumer"
+	IAM_USERNAME_SUFFIX_IAMUSER  = "iamuser"
+	IAM_USERNAME_SUFFIX_ACCESS   = "access"
+
+	// tags recording the CR an iam user was created for, see IAMUserOwner
+	IAM_USER_OWNER_KIND_TAG      = "s3.agill.apps/owner-kind"
+	IAM_USER_OWNER_NAMESPACE_TAG = "s3.agill.apps/owner-namespace"
+	IAM_USER_OWNER_NAME_TAG      = "s3.agill.apps/owner-name"
+	IAM_USER_OWNER_UID_TAG       = "s3.agill.apps/owner-uid"
+
+	// namespace annotations holding per namespace defaults for S3 CRs, e.g: s3.agill.apps/default-region
+	NAMESPACE_DEFAULT_ANNOTATION_PREFIX = "s3.agill.apps/default-"
+	// namespace annotations overriding the operator baseline bucket policy statements, e.g: s3.agill.apps/baseline-require-encryption
+	NAMESPACE_BASELINE_ANNOTATION_PREFIX = "s3.agill.apps/baseline-"
+	// namespace annotations capping what S3, IAMUser and BucketAccessRequest CRs may create, e.g: s3.agill.apps/quota-buckets
+	NAMESPACE_QUOTA_ANNOTATION_PREFIX = "s3.agill.apps/quota-"
+	// records which spec fields were defaulted by the webhook and where the value came from
+	DEFAULTED_FIELDS_ANNOTATION = "s3.agill.apps/defaulted-fields"
+	// comma separated Access Analyzer issue codes whose security warnings are accepted for the CR
+	ACKNOWLEDGED_FINDINGS_ANNOTATION = "s3.agill.apps/acknowledged-findings"
+	// any non empty value locks the bucket and its credentials down, e.g: the incident ticket
+	LOCKDOWN_ANNOTATION = "s3.agill.apps/lockdown"
+
+	// throttled CRs are requeued after this plus up to the same amount of jitter
+	DEFAULT_THROTTLED_REQUEUE_AFTER = 30 * time.Second
+	// CRs stalled on a terminal error are retried after this even without a spec change, some terminal errors
+	// ( e.g: AccessDenied, LimitExceeded, TooManyBuckets ) are fixed outside the CR
+	DEFAULT_STALLED_RETRY_AFTER = 30 * time.Minute
+	// CRs blocked by a namespace quota check again after this, deleting other CRs frees quota without touching them
+	DEFAULT_QUOTA_REQUEUE_AFTER = time.Minute
+	// lastSeen of open S3Findings is refreshed at most this often
+	FINDING_LAST_SEEN_REFRESH_INTERVAL = 24 * time.Hour
+	// bucket sizes are only published by CloudWatch once a day, so there is no point in asking more often
+	BUCKET_SIZE_REFRESH_INTERVAL = 6 * time.Hour
 )
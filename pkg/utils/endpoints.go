@@ -0,0 +1,75 @@
+package utils
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws/endpoints"
+)
+
+const (
+	PARTITION_AWS        = endpoints.AwsPartitionID
+	PARTITION_AWS_CN     = endpoints.AwsCnPartitionID
+	PARTITION_AWS_US_GOV = endpoints.AwsUsGovPartitionID
+)
+
+// PartitionForRegion returns the partition ( aws, aws-cn, aws-us-gov ) a region belongs to.
+// Unknown regions fall back to the commercial aws partition.
+func PartitionForRegion(region string) endpoints.Partition {
+	if p, found := endpoints.PartitionForRegion(endpoints.DefaultPartitions(), region); found {
+		return p
+	}
+	return endpoints.AwsPartition()
+}
+
+// S3BucketARN returns the bucket ARN within the partition of the given region
+func S3BucketARN(region, bucketName string) string {
+	return fmt.Sprintf("arn:%v:s3:::%v", PartitionForRegion(region).ID(), bucketName)
+}
+
+// S3ObjectsARN returns the ARN matching all objects in the bucket within the partition of the given region
+func S3ObjectsARN(region, bucketName string) string {
+	return fmt.Sprintf("%v/*", S3BucketARN(region, bucketName))
+}
+
+// S3PrefixObjectsARN returns the ARN matching the objects under a key prefix within the partition of the given region
+func S3PrefixObjectsARN(region, bucketName, prefix string) string {
+	return fmt.Sprintf("%v/%v*", S3BucketARN(region, bucketName), prefix)
+}
+
+// IAMUserARN returns the ARN of a user created without a path, within the partition of the given region
+func IAMUserARN(region, accountID, username string) string {
+	return fmt.Sprintf("arn:%v:iam::%v:user/%v", PartitionForRegion(region).ID(), accountID, username)
+}
+
+// iamFIPSEndpoint returns the FIPS endpoint for IAM if the partition has a dedicated one.
+// The aws-us-gov IAM endpoint is FIPS validated already and aws-cn has no FIPS endpoint.
+func iamFIPSEndpoint(region string) string {
+	if PartitionForRegion(region).ID() == PARTITION_AWS {
+		return "https://iam-fips.amazonaws.com"
+	}
+	return ""
+}
+
+// endpoint prefixes of the regional services, see regionalFIPSEndpoint
+const (
+	STS_ENDPOINT_PREFIX             = "sts"
+	ACCESS_ANALYZER_ENDPOINT_PREFIX = "access-analyzer"
+	CLOUDWATCH_ENDPOINT_PREFIX      = "monitoring"
+)
+
+// regionalFIPSEndpoint returns the FIPS endpoint of a regional service, e.g: https://sts-fips.us-east-1.amazonaws.com.
+// Only the US and GovCloud regions have FIPS endpoints, other regions keep the default endpoint.
+func regionalFIPSEndpoint(endpointPrefix, region string) string {
+	if !strings.HasPrefix(region, "us-") {
+		return ""
+	}
+	return fmt.Sprintf("https://%v-fips.%v.%v", endpointPrefix, region, PartitionForRegion(region).DNSSuffix())
+}
+
+// IsValidRegion is true if the region belongs to a known partition.
+// Partitions match regions by pattern, so regions newer than the sdk are accepted as well.
+func IsValidRegion(region string) bool {
+	_, found := endpoints.PartitionForRegion(endpoints.DefaultPartitions(), region)
+	return found
+}
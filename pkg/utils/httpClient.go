@@ -0,0 +1,72 @@
+package utils
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+
+	"golang.org/x/net/http/httpproxy"
+)
+
+// HTTPClientConfig describes how the operator reaches the AWS APIs
+type HTTPClientConfig struct {
+	// proxy url used for all AWS API calls, e.g: http://proxy.corp:3128
+	ProxyURL string
+	// comma separated hosts, domains or CIDRs that bypass the proxy
+	NoProxy string
+	// path to a PEM encoded CA bundle ( mounted from a configMap or secret ) trusted in addition to the system roots
+	CABundleFile string
+}
+
+// NewHTTPClient returns a http client for the AWS sessions, or nil when nothing needs customizing.
+// Errors are meant to be fatal at startup, a bad proxy or CA should never silently fall back to defaults.
+func NewHTTPClient(cfg HTTPClientConfig) (*http.Client, error) {
+	if cfg.ProxyURL == "" && cfg.CABundleFile == "" {
+		return nil, nil
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+
+	if cfg.ProxyURL != "" {
+		if u, err := url.Parse(cfg.ProxyURL); err != nil || u.Host == "" {
+			return nil, fmt.Errorf("invalid proxy url %q, expected scheme://host:port", cfg.ProxyURL)
+		}
+		proxyFunc := (&httpproxy.Config{
+			HTTPProxy:  cfg.ProxyURL,
+			HTTPSProxy: cfg.ProxyURL,
+			NoProxy:    cfg.NoProxy,
+		}).ProxyFunc()
+		transport.Proxy = func(req *http.Request) (*url.URL, error) {
+			return proxyFunc(req.URL)
+		}
+	}
+
+	if cfg.CABundleFile != "" {
+		pool, err := certPoolWithBundle(cfg.CABundleFile)
+		if err != nil {
+			return nil, err
+		}
+		transport.TLSClientConfig = &tls.Config{RootCAs: pool}
+	}
+
+	return &http.Client{Transport: transport}, nil
+}
+
+func certPoolWithBundle(caBundleFile string) (*x509.CertPool, error) {
+	pem, err := ioutil.ReadFile(caBundleFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CA bundle %v: %v", caBundleFile, err)
+	}
+
+	pool, err := x509.SystemCertPool()
+	if err != nil || pool == nil {
+		pool = x509.NewCertPool()
+	}
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("CA bundle %v does not contain any valid PEM encoded certificates", caBundleFile)
+	}
+	return pool, nil
+}
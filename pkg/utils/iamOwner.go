@@ -0,0 +1,116 @@
+package utils
+
+import (
+	"fmt"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/iam"
+	"github.com/aws/aws-sdk-go/service/iam/iamiface"
+)
+
+// IAMUserOwner is the CR an IAM user was created for, recorded as tags on the user.
+// Usernames are typed in by hand or derived from CR names, so without the tags one CR could adopt,
+// rotate the keys of and delete the user of another tenant, or of a human.
+type IAMUserOwner struct {
+	Kind      string
+	Namespace string
+	Name      string
+	UID       string
+}
+
+func (o IAMUserOwner) String() string {
+	return fmt.Sprintf("%v %v/%v ( uid %v )", o.Kind, o.Namespace, o.Name, o.UID)
+}
+
+// Tags returns the owner tags
+func (o IAMUserOwner) Tags() map[string]string {
+	return map[string]string{
+		IAM_USER_OWNER_KIND_TAG:      o.Kind,
+		IAM_USER_OWNER_NAMESPACE_TAG: o.Namespace,
+		IAM_USER_OWNER_NAME_TAG:      o.Name,
+		IAM_USER_OWNER_UID_TAG:       o.UID,
+	}
+}
+
+// MergeTags adds the owner tags to tags, owner tags cannot be overridden
+func (o IAMUserOwner) MergeTags(tags map[string]string) map[string]string {
+	merged := map[string]string{}
+	for k, v := range tags {
+		merged[k] = v
+	}
+	for k, v := range o.Tags() {
+		merged[k] = v
+	}
+	return merged
+}
+
+func (o IAMUserOwner) iamTags() []*iam.Tag {
+	var tags []*iam.Tag
+	for _, k := range []string{IAM_USER_OWNER_KIND_TAG, IAM_USER_OWNER_NAMESPACE_TAG, IAM_USER_OWNER_NAME_TAG, IAM_USER_OWNER_UID_TAG} {
+		tags = append(tags, &iam.Tag{Key: aws.String(k), Value: aws.String(o.Tags()[k])})
+	}
+	return tags
+}
+
+func iamUserOwnerFromTags(tags []*iam.Tag) IAMUserOwner {
+	owner := IAMUserOwner{}
+	for _, t := range tags {
+		switch aws.StringValue(t.Key) {
+		case IAM_USER_OWNER_KIND_TAG:
+			owner.Kind = aws.StringValue(t.Value)
+		case IAM_USER_OWNER_NAMESPACE_TAG:
+			owner.Namespace = aws.StringValue(t.Value)
+		case IAM_USER_OWNER_NAME_TAG:
+			owner.Name = aws.StringValue(t.Value)
+		case IAM_USER_OWNER_UID_TAG:
+			owner.UID = aws.StringValue(t.Value)
+		}
+	}
+	return owner
+}
+
+// ErrorIAMUserNotOwned is returned for existing users that were not created for the CR
+type ErrorIAMUserNotOwned struct {
+	Username string
+	// empty when the user has no owner tags
+	Owner IAMUserOwner
+}
+
+func (e ErrorIAMUserNotOwned) Error() string {
+	if e.Owner == (IAMUserOwner{}) {
+		return fmt.Sprintf("IAM user %v already exists and was not created by the operator, pick another username", e.Username)
+	}
+	return fmt.Sprintf("IAM user %v already exists and belongs to %v, pick another username", e.Username, e.Owner)
+}
+
+// IsIAMUserOwner is true when the user exists and was created for owner
+func IsIAMUserOwner(username string, owner IAMUserOwner, adoptUntagged bool, iamClient iamiface.IAMAPI) (bool, error) {
+	exists, err := ClaimIAMUser(username, owner, adoptUntagged, iamClient)
+	if _, notOwned := err.(ErrorIAMUserNotOwned); notOwned {
+		return false, nil
+	}
+	return exists, err
+}
+
+// ClaimIAMUser returns whether the user exists, and ErrorIAMUserNotOwned when it exists but was not created for owner.
+// With adoptUntagged a user without owner tags is tagged for owner instead.
+func ClaimIAMUser(username string, owner IAMUserOwner, adoptUntagged bool, iamClient iamiface.IAMAPI) (bool, error) {
+	out, err := iamClient.ListUserTags(&iam.ListUserTagsInput{UserName: aws.String(username)})
+	if err != nil {
+		if awsErr, ok := err.(awserr.Error); ok && awsErr.Code() == iam.ErrCodeNoSuchEntityException {
+			return false, nil
+		}
+		return false, err
+	}
+
+	current := iamUserOwnerFromTags(out.Tags)
+	if current == owner {
+		return true, nil
+	}
+	if current == (IAMUserOwner{}) && adoptUntagged {
+		_, errTagging := iamClient.TagUser(&iam.TagUserInput{UserName: aws.String(username), Tags: owner.iamTags()})
+		return true, errTagging
+	}
+	return true, ErrorIAMUserNotOwned{Username: username, Owner: current}
+}
@@ -1,7 +1,11 @@
 package utils
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
+	"fmt"
+	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/awserr"
 	"github.com/aws/aws-sdk-go/service/iam"
 	"github.com/aws/aws-sdk-go/service/iam/iamiface"
@@ -87,13 +91,23 @@ func CreateAccessKeys(username string, iamapi iamiface.IAMAPI) (*iam.CreateAcces
 	return iamapi.CreateAccessKey(&iam.CreateAccessKeyInput{UserName: &username})
 }
 
-func CreateIAMUser(input *iam.CreateUserInput, iamClient iamiface.IAMAPI) error {
-	userExists, checkErr := IAMUserExists(*input.UserName, iamClient)
-	if checkErr != nil {
-		return checkErr
+// CreateIAMUser creates the user tagged with its owner. An existing user is only adopted when it was created for
+// the same owner, users of other CRs, tenants or humans return ErrorIAMUserNotOwned and are left untouched.
+// adoptUntagged claims users without owner tags, created before users were tagged.
+func CreateIAMUser(input *iam.CreateUserInput, owner IAMUserOwner, adoptUntagged bool, iamClient iamiface.IAMAPI) error {
+	exists, err := ClaimIAMUser(*input.UserName, owner, adoptUntagged, iamClient)
+	if err != nil {
+		return err
 	}
-	if !userExists {
-		if _, errCreatingUser := iamClient.CreateUser(input); errCreatingUser != nil {
+	if !exists {
+		in := *input
+		in.Tags = owner.iamTags()
+		for _, t := range input.Tags {
+			if _, isOwnerTag := owner.Tags()[aws.StringValue(t.Key)]; !isOwnerTag {
+				in.Tags = append(in.Tags, t)
+			}
+		}
+		if _, errCreatingUser := iamClient.CreateUser(&in); errCreatingUser != nil {
 			return errCreatingUser
 		}
 	}
@@ -107,3 +121,116 @@ func DeleteIAMInlinePolicyFromUser(policyName, username string, iamClient iamifa
 	})
 	return errDeletingInlinePolicy
 }
+
+// DefaultIAMUsername derives a deterministic username for a CR: <namespace>.<name>.<kind suffix>.
+// Namespaces cannot contain dots and the suffix is fixed per kind, so names of different namespaces and kinds never collide.
+// Names longer than the IAM limit are truncated and suffixed with a hash of the full name, so they stay unique.
+func DefaultIAMUsername(namespace, name, suffix string) string {
+	username := fmt.Sprintf("%v.%v.%v", namespace, name, suffix)
+	if len(username) <= IAM_USERNAME_MAX_LENGTH {
+		return username
+	}
+	sum := sha256.Sum256([]byte(username))
+	hash := hex.EncodeToString(sum[:])[:BUCKET_NAME_HASH_LENGTH]
+	return fmt.Sprintf("%v-%v", username[:IAM_USERNAME_MAX_LENGTH-BUCKET_NAME_HASH_LENGTH-1], hash)
+}
+
+// LegacyIAMUsername is the <namespace>-<name> format generated before DefaultIAMUsername, users created with it keep their name
+func LegacyIAMUsername(namespace, name string) string {
+	username := fmt.Sprintf("%v-%v", namespace, name)
+	if len(username) <= IAM_USERNAME_MAX_LENGTH {
+		return username
+	}
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%v/%v", namespace, name)))
+	hash := hex.EncodeToString(sum[:])[:BUCKET_NAME_HASH_LENGTH]
+	return fmt.Sprintf("%v-%v", username[:IAM_USERNAME_MAX_LENGTH-BUCKET_NAME_HASH_LENGTH-1], hash)
+}
+
+func DetachAllUserManagedPolicies(iamUser string, iamapi iamiface.IAMAPI) error {
+	attached, err := iamapi.ListAttachedUserPolicies(&iam.ListAttachedUserPoliciesInput{
+		UserName: &iamUser,
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, e := range attached.AttachedPolicies {
+		if _, errDetaching := iamapi.DetachUserPolicy(&iam.DetachUserPolicyInput{
+			PolicyArn: e.PolicyArn,
+			UserName:  &iamUser,
+		}); errDetaching != nil {
+			return errDetaching
+		}
+	}
+	return nil
+}
+
+// DeleteIAMUser removes everything IAM requires to be gone before a user can be deleted, then the user itself.
+// Users not created for owner were never adopted by it, they are left alone and ErrorIAMUserNotOwned is returned.
+func DeleteIAMUser(username string, owner IAMUserOwner, adoptUntagged bool, iamClient iamiface.IAMAPI) error {
+	userExists, err := ClaimIAMUser(username, owner, adoptUntagged, iamClient)
+	if err != nil {
+		return err
+	}
+
+	if userExists {
+		if errDeletingAccessKeys := DeleteAllAccessKeys(username, iamClient); errDeletingAccessKeys != nil {
+			return errDeletingAccessKeys
+		}
+
+		if errDeletingPolicies := DeleteAllUserInlinePolicies(username, iamClient); errDeletingPolicies != nil {
+			return errDeletingPolicies
+		}
+
+		if errDetachingPolicies := DetachAllUserManagedPolicies(username, iamClient); errDetachingPolicies != nil {
+			return errDetachingPolicies
+		}
+
+		if _, errDeletingUser := iamClient.DeleteUser(&iam.DeleteUserInput{UserName: &username}); errDeletingUser != nil {
+			return errDeletingUser
+		}
+	}
+
+	return nil
+}
+
+// SyncIAMUserTags makes the user tags match the desired ones, tags are assumed to be fully owned by the caller
+func SyncIAMUserTags(username string, desired map[string]string, iamClient iamiface.IAMAPI) error {
+	current, err := iamClient.ListUserTags(&iam.ListUserTagsInput{UserName: &username})
+	if err != nil {
+		return err
+	}
+
+	var toRemove []*string
+	for _, t := range current.Tags {
+		if _, found := desired[*t.Key]; !found {
+			toRemove = append(toRemove, t.Key)
+		}
+	}
+	if len(toRemove) > 0 {
+		if _, err := iamClient.UntagUser(&iam.UntagUserInput{UserName: &username, TagKeys: toRemove}); err != nil {
+			return err
+		}
+	}
+
+	var toSet []*iam.Tag
+	for k, v := range desired {
+		k, v := k, v
+		found := false
+		for _, t := range current.Tags {
+			if *t.Key == k && *t.Value == v {
+				found = true
+				break
+			}
+		}
+		if !found {
+			toSet = append(toSet, &iam.Tag{Key: &k, Value: &v})
+		}
+	}
+	if len(toSet) > 0 {
+		if _, err := iamClient.TagUser(&iam.TagUserInput{UserName: &username, Tags: toSet}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,92 @@
+package utils
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDefaultIAMUsername(t *testing.T) {
+	tests := []struct {
+		name      string
+		namespace string
+		crName    string
+		suffix    string
+		want      string
+	}{
+		{name: "short", namespace: "team-a", crName: "logs", suffix: IAM_USERNAME_SUFFIX_S3, want: "team-a.logs.s3"},
+		{name: "consumer", namespace: "team-a", crName: "logs.reader", suffix: IAM_USERNAME_SUFFIX_CONSUMER, want: "team-a.logs.reader.consumer"},
+		{
+			name:      "exactly 64 chars",
+			namespace: strings.Repeat("n", 30),
+			crName:    strings.Repeat("c", 25),
+			suffix:    IAM_USERNAME_SUFFIX_IAMUSER,
+			want:      strings.Repeat("n", 30) + "." + strings.Repeat("c", 25) + ".iamuser",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := DefaultIAMUsername(tt.namespace, tt.crName, tt.suffix); got != tt.want {
+				t.Errorf("DefaultIAMUsername() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDefaultIAMUsernameTruncated(t *testing.T) {
+	namespace, crName := strings.Repeat("n", 40), strings.Repeat("c", 40)
+	got := DefaultIAMUsername(namespace, crName, IAM_USERNAME_SUFFIX_S3)
+	if len(got) != IAM_USERNAME_MAX_LENGTH {
+		t.Fatalf("DefaultIAMUsername() = %v, %v chars, want %v", got, len(got), IAM_USERNAME_MAX_LENGTH)
+	}
+	readable := namespace + "." + crName
+	if !strings.HasPrefix(got, readable[:IAM_USERNAME_MAX_LENGTH-BUCKET_NAME_HASH_LENGTH-1]+"-") {
+		t.Errorf("DefaultIAMUsername() = %v, want the truncated name followed by the hash", got)
+	}
+
+	// names differing only past the cut must not get the same user
+	tests := []struct {
+		name      string
+		namespace string
+		crName    string
+		suffix    string
+	}{
+		{name: "other suffix", namespace: namespace, crName: crName, suffix: IAM_USERNAME_SUFFIX_ACCESS},
+		{name: "other cr name", namespace: namespace, crName: crName + "x", suffix: IAM_USERNAME_SUFFIX_S3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if other := DefaultIAMUsername(tt.namespace, tt.crName, tt.suffix); other == got {
+				t.Errorf("DefaultIAMUsername() = %v for different inputs", other)
+			}
+		})
+	}
+}
+
+func TestLegacyIAMUsername(t *testing.T) {
+	tests := []struct {
+		name      string
+		namespace string
+		crName    string
+		want      string
+	}{
+		{name: "short", namespace: "team-a", crName: "logs", want: "team-a-logs"},
+		{
+			name:      "exactly 64 chars",
+			namespace: strings.Repeat("n", 31),
+			crName:    strings.Repeat("c", 32),
+			want:      strings.Repeat("n", 31) + "-" + strings.Repeat("c", 32),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := LegacyIAMUsername(tt.namespace, tt.crName); got != tt.want {
+				t.Errorf("LegacyIAMUsername() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	// the namespace / name split is hashed, a-b/c and a/b-c are different users
+	if LegacyIAMUsername(strings.Repeat("a", 40)+"-b", "c"+strings.Repeat("d", 40)) == LegacyIAMUsername(strings.Repeat("a", 40), "b-c"+strings.Repeat("d", 40)) {
+		t.Errorf("LegacyIAMUsername() is the same for a different namespace and name split")
+	}
+}
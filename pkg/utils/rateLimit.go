@@ -0,0 +1,109 @@
+package utils
+
+import (
+	"math/rand"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/agill17/s3-operator/pkg/metrics"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"golang.org/x/time/rate"
+)
+
+// AWS API families that get their own rate limiter per account
+const (
+	API_FAMILY_IAM = "iam"
+	API_FAMILY_S3  = "s3"
+)
+
+// RateLimit is a token bucket, QPS tokens are added every second up to Burst
+type RateLimit struct {
+	QPS   float64
+	Burst int
+}
+
+type limiterKey struct {
+	accountID string
+	apiFamily string
+}
+
+// rateLimiters are shared by every client of the same account, no matter the region,
+// because IAM and S3 throttling is enforced account wide.
+type rateLimiters struct {
+	limits map[string]RateLimit
+
+	mu       sync.Mutex
+	limiters map[limiterKey]*rate.Limiter
+}
+
+func newRateLimiters(limits map[string]RateLimit) *rateLimiters {
+	return &rateLimiters{limits: limits, limiters: map[limiterKey]*rate.Limiter{}}
+}
+
+func (r *rateLimiters) get(accountID, apiFamily string) *rate.Limiter {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	key := limiterKey{accountID: accountID, apiFamily: apiFamily}
+	if l, found := r.limiters[key]; found {
+		return l
+	}
+	limit, found := r.limits[apiFamily]
+	if !found || limit.QPS <= 0 {
+		r.limiters[key] = rate.NewLimiter(rate.Inf, 1)
+		return r.limiters[key]
+	}
+	if limit.Burst < 1 {
+		limit.Burst = 1
+	}
+	r.limiters[key] = rate.NewLimiter(rate.Limit(limit.QPS), limit.Burst)
+	return r.limiters[key]
+}
+
+// addRateLimiting makes every attempt ( including sdk retries ) of a request wait on the limiter
+// and counts throttled attempts.
+func (r *rateLimiters) addRateLimiting(handlers *request.Handlers, accountID, apiFamily string) {
+	limiter := r.get(accountID, apiFamily)
+	handlers.Send.PushFrontNamed(request.NamedHandler{
+		Name: "s3operator.RateLimit",
+		Fn: func(req *request.Request) {
+			start := time.Now()
+			if err := limiter.Wait(req.Context()); err != nil {
+				req.Error = awserr.New(request.CanceledErrorCode, "rate limiter wait canceled", err)
+				return
+			}
+			metrics.AWSRateLimiterWaitSeconds.WithLabelValues(accountID, apiFamily).Observe(time.Since(start).Seconds())
+		},
+	})
+	handlers.CompleteAttempt.PushBackNamed(request.NamedHandler{
+		Name: "s3operator.CountThrottles",
+		Fn: func(req *request.Request) {
+			if IsThrottlingError(req.Error) {
+				metrics.AWSThrottledRequestsTotal.WithLabelValues(accountID, apiFamily).Inc()
+			}
+		},
+	})
+}
+
+// IsThrottlingError reports whether AWS rejected the call because of rate limits
+func IsThrottlingError(err error) bool {
+	if err == nil {
+		return false
+	}
+	if request.IsErrorThrottle(err) {
+		return true
+	}
+	if reqErr, ok := err.(awserr.RequestFailure); ok {
+		// S3 signals throttling with 503 SlowDown
+		return reqErr.Code() == "SlowDown" || reqErr.StatusCode() == http.StatusTooManyRequests
+	}
+	return false
+}
+
+// ThrottledRequeueAfter spreads requeues of throttled CRs over [base, 2*base),
+// so hundreds of CRs do not come back at the same moment and get throttled again.
+func ThrottledRequeueAfter() time.Duration {
+	base := DEFAULT_THROTTLED_REQUEUE_AFTER
+	return base + time.Duration(rand.Int63n(int64(base)))
+}
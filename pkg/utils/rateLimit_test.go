@@ -0,0 +1,52 @@
+package utils
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+func TestIsThrottlingError(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{name: "nil", err: nil, want: false},
+		{name: "not an aws error", err: errors.New("Throttling"), want: false},
+		{name: "iam throttling", err: awserr.New("Throttling", "Rate exceeded", nil), want: true},
+		{name: "throttling exception", err: awserr.New("ThrottlingException", "Rate exceeded", nil), want: true},
+		{name: "request limit exceeded", err: awserr.New("RequestLimitExceeded", "Request limit exceeded", nil), want: true},
+		{
+			name: "s3 slow down",
+			err:  awserr.NewRequestFailure(awserr.New("SlowDown", "Please reduce your request rate", nil), http.StatusServiceUnavailable, "req"),
+			want: true,
+		},
+		{
+			name: "too many requests",
+			err:  awserr.NewRequestFailure(awserr.New("Unknown", "", nil), http.StatusTooManyRequests, "req"),
+			want: true,
+		},
+		{
+			name: "other service unavailable",
+			err:  awserr.NewRequestFailure(awserr.New("ServiceUnavailable", "", nil), http.StatusServiceUnavailable, "req"),
+			want: false,
+		},
+		{
+			name: "access denied",
+			err:  awserr.NewRequestFailure(awserr.New("AccessDenied", "Access Denied", nil), http.StatusForbidden, "req"),
+			want: false,
+		},
+		{name: "no such bucket", err: awserr.New(s3.ErrCodeNoSuchBucket, "", nil), want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IsThrottlingError(tt.err); got != tt.want {
+				t.Errorf("IsThrottlingError(%v) = %v, want %v", tt.err, got, tt.want)
+			}
+		})
+	}
+}
@@ -1,21 +1,91 @@
 package utils
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/awserr"
 	"github.com/aws/aws-sdk-go/service/s3"
 	"github.com/aws/aws-sdk-go/service/s3/s3iface"
 	"github.com/davecgh/go-spew/spew"
+	"net/http"
+	"regexp"
+	"strings"
 )
 
-func BucketExists(bucketName string, s3Client s3iface.S3API) (bool, error) {
+type BucketOwnership string
+
+const (
+	BUCKET_NOT_FOUND                BucketOwnership = "NotFound"
+	BUCKET_OWNED_BY_YOU             BucketOwnership = "OwnedByYou"
+	BUCKET_OWNED_BY_ANOTHER_ACCOUNT BucketOwnership = "OwnedByAnotherAccount"
+)
+
+// GetBucketOwnership tells apart buckets that do not exist, belong to the operator's account or to someone else.
+// A 403 alone does not tell whose bucket it is: missing permissions or a bucket policy Deny on our own bucket return it too.
+// So a 403 only means another account once the bucket is confirmed missing from the account's own buckets,
+// otherwise the 403 is returned as it is.
+func GetBucketOwnership(bucketName string, s3Client s3iface.S3API) (BucketOwnership, error) {
 	_, err := s3Client.GetBucketLocation(&s3.GetBucketLocationInput{Bucket: &bucketName})
-	if awserr, ok := err.(awserr.Error); ok && awserr.Code() == s3.ErrCodeNoSuchBucket {
-		return false, nil
-	} else if err != nil {
+	if err == nil {
+		return BUCKET_OWNED_BY_YOU, nil
+	}
+	reqErr, ok := err.(awserr.RequestFailure)
+	if !ok {
+		return "", err
+	}
+	if reqErr.Code() == s3.ErrCodeNoSuchBucket {
+		return BUCKET_NOT_FOUND, nil
+	}
+	if reqErr.StatusCode() != http.StatusForbidden {
+		return "", err
+	}
+
+	owned, errListing := isOwnBucket(bucketName, s3Client)
+	if errListing != nil {
+		return "", errListing
+	}
+	if owned {
+		return "", err
+	}
+	return BUCKET_OWNED_BY_ANOTHER_ACCOUNT, nil
+}
+
+// isOwnBucket looks the bucket up in the buckets of the operator's account, ListBuckets is not subject to bucket policies
+func isOwnBucket(bucketName string, s3Client s3iface.S3API) (bool, error) {
+	out, err := s3Client.ListBuckets(&s3.ListBucketsInput{})
+	if err != nil {
+		return false, err
+	}
+	for _, b := range out.Buckets {
+		if aws.StringValue(b.Name) == bucketName {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// BucketExists returns true only for buckets owned by the operator's account, a 403 on our own bucket is an error
+func BucketExists(bucketName string, s3Client s3iface.S3API) (bool, error) {
+	ownership, err := GetBucketOwnership(bucketName, s3Client)
+	if err != nil {
 		return false, err
 	}
-	return true, nil
+	return ownership == BUCKET_OWNED_BY_YOU, nil
+}
+
+// IsBucketAlreadyOwnedByYou is returned by CreateBucket outside us-east-1 when the bucket is ours already,
+// us-east-1 ( legacy global endpoint ) returns success instead. Either way the bucket exists and is ours.
+func IsBucketAlreadyOwnedByYou(err error) bool {
+	awsErr, ok := err.(awserr.Error)
+	return ok && awsErr.Code() == s3.ErrCodeBucketAlreadyOwnedByYou
+}
+
+// IsBucketAlreadyExists means the name is taken by another AWS account
+func IsBucketAlreadyExists(err error) bool {
+	awsErr, ok := err.(awserr.Error)
+	return ok && awsErr.Code() == s3.ErrCodeBucketAlreadyExists
 }
 
 func GetBucketACL(bucketName string, s3Client s3iface.S3API) (*s3.GetBucketAclOutput, error) {
@@ -26,6 +96,30 @@ func GetBucketACL(bucketName string, s3Client s3iface.S3API) (*s3.GetBucketAclOu
 	return bucketAcl, nil
 }
 
+// GetBucketPolicy returns the live bucket policy, empty when the bucket has none
+func GetBucketPolicy(bucketName string, s3Client s3iface.S3API) (string, error) {
+	out, err := s3Client.GetBucketPolicy(&s3.GetBucketPolicyInput{Bucket: aws.String(bucketName)})
+	if err != nil {
+		if awsErr, ok := err.(awserr.Error); ok && awsErr.Code() == "NoSuchBucketPolicy" {
+			return "", nil
+		}
+		return "", err
+	}
+	return aws.StringValue(out.Policy), nil
+}
+
+// HasDefaultEncryption is true when the bucket has a default server side encryption configuration
+func HasDefaultEncryption(bucketName string, s3Client s3iface.S3API) (bool, error) {
+	out, err := s3Client.GetBucketEncryption(&s3.GetBucketEncryptionInput{Bucket: aws.String(bucketName)})
+	if err != nil {
+		if awsErr, ok := err.(awserr.Error); ok && awsErr.Code() == "ServerSideEncryptionConfigurationNotFoundError" {
+			return false, nil
+		}
+		return false, err
+	}
+	return out.ServerSideEncryptionConfiguration != nil && len(out.ServerSideEncryptionConfiguration.Rules) > 0, nil
+}
+
 func CreateBucket(createIn *s3.CreateBucketInput, s3Client s3iface.S3API) error {
 	bucketExists, checkError := BucketExists(*createIn.Bucket, s3Client)
 	if checkError != nil {
@@ -33,10 +127,30 @@ func CreateBucket(createIn *s3.CreateBucketInput, s3Client s3iface.S3API) error
 	}
 	if !bucketExists {
 		out, errCreatingBucket := s3Client.CreateBucket(createIn)
-		if errCreatingBucket != nil {
+		if errCreatingBucket != nil && !IsBucketAlreadyOwnedByYou(errCreatingBucket) {
 			return errCreatingBucket
 		}
 		spew.Dump(out)
 	}
 	return nil
 }
+
+var invalidBucketNameChars = regexp.MustCompile(`[^a-z0-9-]+`)
+
+// GenerateBucketName derives a DNS compliant bucket name: <prefix>-<namespace>-<hash of seed>.
+// The hash keeps names unique across clusters and namespaces, prefix and namespace are truncated to fit 63 chars.
+func GenerateBucketName(prefix, namespace, seed string) string {
+	sum := sha256.Sum256([]byte(seed))
+	hash := hex.EncodeToString(sum[:])[:BUCKET_NAME_HASH_LENGTH]
+
+	readable := invalidBucketNameChars.ReplaceAllString(strings.ToLower(fmt.Sprintf("%v-%v", prefix, namespace)), "-")
+	maxReadable := BUCKET_NAME_MAX_LENGTH - BUCKET_NAME_HASH_LENGTH - 1
+	if len(readable) > maxReadable {
+		readable = readable[:maxReadable]
+	}
+	readable = strings.Trim(readable, "-")
+	if readable == "" {
+		return hash
+	}
+	return fmt.Sprintf("%v-%v", readable, hash)
+}
@@ -0,0 +1,132 @@
+package utils
+
+import (
+	"errors"
+	"net/http"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3iface"
+)
+
+// fakeS3 answers GetBucketLocation and ListBuckets, any other call panics
+type fakeS3 struct {
+	s3iface.S3API
+	locationErr error
+	buckets     []string
+	listErr     error
+}
+
+func (f fakeS3) GetBucketLocation(*s3.GetBucketLocationInput) (*s3.GetBucketLocationOutput, error) {
+	if f.locationErr != nil {
+		return nil, f.locationErr
+	}
+	return &s3.GetBucketLocationOutput{}, nil
+}
+
+func (f fakeS3) ListBuckets(*s3.ListBucketsInput) (*s3.ListBucketsOutput, error) {
+	if f.listErr != nil {
+		return nil, f.listErr
+	}
+	out := &s3.ListBucketsOutput{}
+	for _, b := range f.buckets {
+		out.Buckets = append(out.Buckets, &s3.Bucket{Name: aws.String(b)})
+	}
+	return out, nil
+}
+
+func TestGetBucketOwnership(t *testing.T) {
+	forbidden := awserr.NewRequestFailure(awserr.New("AccessDenied", "Access Denied", nil), http.StatusForbidden, "req")
+	notFound := awserr.NewRequestFailure(awserr.New(s3.ErrCodeNoSuchBucket, "", nil), http.StatusNotFound, "req")
+	internal := awserr.NewRequestFailure(awserr.New("InternalError", "", nil), http.StatusInternalServerError, "req")
+	listFailed := errors.New("list failed")
+
+	tests := []struct {
+		name    string
+		s3      fakeS3
+		want    BucketOwnership
+		wantErr error
+	}{
+		{name: "own bucket", s3: fakeS3{}, want: BUCKET_OWNED_BY_YOU},
+		{name: "no such bucket", s3: fakeS3{locationErr: notFound}, want: BUCKET_NOT_FOUND},
+		{name: "other error", s3: fakeS3{locationErr: internal}, wantErr: internal},
+		{name: "not a request failure", s3: fakeS3{locationErr: listFailed}, wantErr: listFailed},
+		{
+			name: "403 and not in our buckets",
+			s3:   fakeS3{locationErr: forbidden, buckets: []string{"other"}},
+			want: BUCKET_OWNED_BY_ANOTHER_ACCOUNT,
+		},
+		{
+			// a bucket policy Deny or missing permissions on our own bucket
+			name:    "403 on our own bucket",
+			s3:      fakeS3{locationErr: forbidden, buckets: []string{"other", "bucket"}},
+			wantErr: forbidden,
+		},
+		{name: "403 and listing fails", s3: fakeS3{locationErr: forbidden, listErr: listFailed}, wantErr: listFailed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := GetBucketOwnership("bucket", tt.s3)
+			if err != tt.wantErr {
+				t.Fatalf("GetBucketOwnership() error = %v, want %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("GetBucketOwnership() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGenerateBucketName(t *testing.T) {
+	validBucketName := regexp.MustCompile(`^[a-z0-9][a-z0-9-]*[a-z0-9]$`)
+	tests := []struct {
+		name       string
+		prefix     string
+		namespace  string
+		seed       string
+		wantPrefix string
+	}{
+		{name: "prefix and namespace", prefix: "logs", namespace: "team-a", seed: "uid", wantPrefix: "logs-team-a-"},
+		{name: "upper case and invalid chars", prefix: "My_Logs", namespace: "team.a", seed: "uid", wantPrefix: "my-logs-team-a-"},
+		{
+			name:       "truncated to 63 chars",
+			prefix:     strings.Repeat("p", 40),
+			namespace:  strings.Repeat("n", 40),
+			seed:       "uid",
+			wantPrefix: strings.Repeat("p", 40) + "-" + strings.Repeat("n", 11) + "-",
+		},
+		{
+			// truncating must not leave a trailing dash before the hash
+			name:       "truncated on a dash",
+			prefix:     strings.Repeat("p", 51),
+			namespace:  "team",
+			seed:       "uid",
+			wantPrefix: strings.Repeat("p", 51) + "-",
+		},
+		{name: "nothing readable", prefix: "_", namespace: "", seed: "uid", wantPrefix: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := GenerateBucketName(tt.prefix, tt.namespace, tt.seed)
+			if len(got) > BUCKET_NAME_MAX_LENGTH {
+				t.Errorf("GenerateBucketName() = %v, longer than %v chars", got, BUCKET_NAME_MAX_LENGTH)
+			}
+			if !validBucketName.MatchString(got) || strings.Contains(got, "--") {
+				t.Errorf("GenerateBucketName() = %v, not a valid bucket name", got)
+			}
+			if !strings.HasPrefix(got, tt.wantPrefix) || len(got) != len(tt.wantPrefix)+BUCKET_NAME_HASH_LENGTH {
+				t.Errorf("GenerateBucketName() = %v, want %v followed by the hash", got, tt.wantPrefix)
+			}
+			if again := GenerateBucketName(tt.prefix, tt.namespace, tt.seed); again != got {
+				t.Errorf("GenerateBucketName() is not stable, got %v and %v", got, again)
+			}
+			if other := GenerateBucketName(tt.prefix, tt.namespace, tt.seed+"-1"); other == got {
+				t.Errorf("GenerateBucketName() = %v for different seeds", got)
+			}
+		})
+	}
+}
@@ -0,0 +1,21 @@
+package webhook
+
+import (
+	"github.com/agill17/s3-operator/pkg/controller/options"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/conversion"
+)
+
+// must match spec.conversion.webhook in the CRDs
+const CONVERSION_PATH = "/convert"
+
+func init() {
+	AddToManagerFuncs = append(AddToManagerFuncs, addConversion)
+}
+
+// addConversion serves CRD version conversion for every kind with more than one version,
+// kinds are converted through their Hub version ( v1beta1 )
+func addConversion(mgr manager.Manager, opts options.Options) error {
+	mgr.GetWebhookServer().Register(CONVERSION_PATH, &conversion.Webhook{})
+	return nil
+}
@@ -0,0 +1,10 @@
+package webhook
+
+import (
+	"github.com/agill17/s3-operator/pkg/webhook/s3"
+)
+
+func init() {
+	// AddToManagerFuncs is a list of functions to create webhooks and add them to a manager.
+	AddToManagerFuncs = append(AddToManagerFuncs, s3.Add)
+}
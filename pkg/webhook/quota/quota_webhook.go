@@ -0,0 +1,36 @@
+package quota
+
+import (
+	agillv1beta1 "github.com/agill17/s3-operator/pkg/apis/agill/v1beta1"
+	"github.com/agill17/s3-operator/pkg/controller/options"
+	"github.com/agill17/s3-operator/pkg/controller/quotas"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+const (
+	// must match the paths in the ValidatingWebhookConfiguration
+	VALIDATE_IAMUSER_PATH       = "/validate-agill-apps-v1beta1-iamuser"
+	VALIDATE_BUCKET_ACCESS_PATH = "/validate-agill-apps-v1beta1-bucketaccessrequest"
+)
+
+var log = logf.Log.WithName("webhook_quota")
+
+// Add registers the quota admission webhooks of the kinds that only count IAM users against the namespace quota,
+// S3 CRs are checked by the S3 validating webhook
+func Add(mgr manager.Manager, opts options.Options) error {
+	mgr.GetWebhookServer().Register(VALIDATE_IAMUSER_PATH, &admission.Webhook{Handler: &validator{
+		resource: "iamusers",
+		newObject: func() quotas.Object {
+			return &agillv1beta1.IAMUser{}
+		},
+	}})
+	mgr.GetWebhookServer().Register(VALIDATE_BUCKET_ACCESS_PATH, &admission.Webhook{Handler: &validator{
+		resource: "bucketaccessrequests",
+		newObject: func() quotas.Object {
+			return &agillv1beta1.BucketAccessRequest{}
+		},
+	}})
+	return nil
+}
@@ -0,0 +1,64 @@
+package quota
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	agillv1beta1 "github.com/agill17/s3-operator/pkg/apis/agill/v1beta1"
+	"github.com/agill17/s3-operator/pkg/controller/quotas"
+	admissionv1beta1 "k8s.io/api/admission/v1beta1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+// validator rejects CRs that would exceed the quota of their namespace, so users get the error from kubectl
+// instead of a CR waiting in phase QuotaExceeded
+type validator struct {
+	// plural of the kind, for the Forbidden error
+	resource  string
+	newObject func() quotas.Object
+	client    client.Client
+	decoder   *admission.Decoder
+}
+
+func (v *validator) Handle(ctx context.Context, req admission.Request) admission.Response {
+	// what these kinds count against the quota is fixed when they are created
+	if req.Operation != admissionv1beta1.Create {
+		return admission.Allowed("")
+	}
+
+	cr := v.newObject()
+	if err := v.decoder.Decode(req, cr); err != nil {
+		return admission.Errored(http.StatusBadRequest, err)
+	}
+	// the namespace is only in the request when the object omits it
+	if cr.GetNamespace() == "" {
+		cr.SetNamespace(req.Namespace)
+	}
+
+	msg, err := quotas.Check(v.client, cr.GetNamespace(), cr.QuotaUsage(), quotas.Excluding(cr))
+	if err != nil {
+		return admission.Errored(http.StatusInternalServerError, err)
+	}
+	if msg != "" {
+		log.Info("Rejecting CR over quota", "Kind", req.Kind.Kind, "Namespace", req.Namespace, "Name", req.Name, "Reason", msg)
+		status := apierrors.NewForbidden(agillv1beta1.SchemeGroupVersion.WithResource(v.resource).GroupResource(), cr.GetName(),
+			errors.New(msg)).ErrStatus
+		return admission.Response{AdmissionResponse: admissionv1beta1.AdmissionResponse{Allowed: false, Result: &status}}
+	}
+	return admission.Allowed("")
+}
+
+// InjectClient is called by the webhook server before serving requests
+func (v *validator) InjectClient(c client.Client) error {
+	v.client = c
+	return nil
+}
+
+// InjectDecoder is called by the webhook server before serving requests
+func (v *validator) InjectDecoder(d *admission.Decoder) error {
+	v.decoder = d
+	return nil
+}
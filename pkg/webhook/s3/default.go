@@ -0,0 +1,197 @@
+package s3
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	agillv1alpha1 "github.com/agill17/s3-operator/pkg/apis/agill/v1alpha1"
+	agillv1beta1 "github.com/agill17/s3-operator/pkg/apis/agill/v1beta1"
+	"github.com/agill17/s3-operator/pkg/controller/options"
+	"github.com/agill17/s3-operator/pkg/utils"
+	admissionv1beta1 "k8s.io/api/admission/v1beta1"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+const (
+	DEFAULT_SOURCE_NAMESPACE = "namespace"
+	DEFAULT_SOURCE_OPERATOR  = "operator"
+	DEFAULT_SOURCE_GENERATED = "generated"
+)
+
+// spec field names that differ between versions, presence is checked on the raw object of the requested version
+type versionedFieldNames struct {
+	versioning           string
+	transferAcceleration string
+}
+
+var fieldNamesByVersion = map[string]versionedFieldNames{
+	agillv1alpha1.SchemeGroupVersion.Version: {versioning: "enableVersioning", transferAcceleration: "enableTransferAcceleration"},
+	agillv1beta1.SchemeGroupVersion.Version:  {versioning: "versioning", transferAcceleration: "transferAcceleration"},
+}
+
+// defaulter fills unset spec fields from namespace annotations first, then from operator wide defaults
+type defaulter struct {
+	client   client.Client
+	decoder  *admission.Decoder
+	defaults options.S3Defaults
+}
+
+func (d *defaulter) Handle(ctx context.Context, req admission.Request) admission.Response {
+	if req.Operation != admissionv1beta1.Create && req.Operation != admissionv1beta1.Update {
+		return admission.Allowed("")
+	}
+
+	// defaults are applied before any conversion, so omitted v1alpha1 booleans can still be told apart from false
+	cr := &agillv1beta1.S3{}
+	var v1alpha1CR *agillv1alpha1.S3
+	if req.Kind.Version == agillv1alpha1.SchemeGroupVersion.Version {
+		v1alpha1CR = &agillv1alpha1.S3{}
+		if err := d.decoder.Decode(req, v1alpha1CR); err != nil {
+			return admission.Errored(http.StatusBadRequest, err)
+		}
+		if err := v1alpha1CR.ConvertTo(cr); err != nil {
+			return admission.Errored(http.StatusBadRequest, err)
+		}
+	} else if err := d.decoder.Decode(req, cr); err != nil {
+		return admission.Errored(http.StatusBadRequest, err)
+	}
+	if cr.GetDeletionTimestamp() != nil {
+		return admission.Allowed("")
+	}
+
+	// omitempty fields cannot be told apart from their zero value without looking at the raw object
+	raw := struct {
+		Spec map[string]json.RawMessage `json:"spec"`
+	}{}
+	if err := json.Unmarshal(req.Object.Raw, &raw); err != nil {
+		return admission.Errored(http.StatusBadRequest, err)
+	}
+
+	ns := &v1.Namespace{}
+	if err := d.client.Get(ctx, types.NamespacedName{Name: req.Namespace}, ns); err != nil {
+		return admission.Errored(http.StatusInternalServerError, err)
+	}
+
+	defaulted, err := d.applyDefaults(cr, raw.Spec, fieldNamesByVersion[req.Kind.Version], ns.GetAnnotations())
+	if err != nil {
+		return admission.Denied(err.Error())
+	}
+	if len(defaulted) == 0 {
+		return admission.Allowed("")
+	}
+	recordDefaultedFields(cr, defaulted)
+	log.Info("Defaulted S3 CR", "Namespace", req.Namespace, "Name", req.Name, "Fields", cr.GetAnnotations()[utils.DEFAULTED_FIELDS_ANNOTATION])
+
+	var mutated interface{} = cr
+	if v1alpha1CR != nil {
+		if err := v1alpha1CR.ConvertFrom(cr); err != nil {
+			return admission.Errored(http.StatusInternalServerError, err)
+		}
+		mutated = v1alpha1CR
+	}
+	marshaled, err := json.Marshal(mutated)
+	if err != nil {
+		return admission.Errored(http.StatusInternalServerError, err)
+	}
+	return admission.PatchResponseFromRaw(req.Object.Raw, marshaled)
+}
+
+// applyDefaults returns the defaulted fields and where each value came from
+func (d *defaulter) applyDefaults(cr *agillv1beta1.S3, rawSpec map[string]json.RawMessage, names versionedFieldNames, nsAnnotations map[string]string) (map[string]string, error) {
+	defaulted := map[string]string{}
+
+	stringDefault := func(fieldPath, annotation, operatorDefault string, value *string) {
+		if *value != "" {
+			return
+		}
+		if v := nsAnnotations[utils.NAMESPACE_DEFAULT_ANNOTATION_PREFIX+annotation]; v != "" {
+			*value, defaulted[fieldPath] = v, DEFAULT_SOURCE_NAMESPACE
+		} else if operatorDefault != "" {
+			*value, defaulted[fieldPath] = operatorDefault, DEFAULT_SOURCE_OPERATOR
+		}
+	}
+	// features keep their boolean defaults: true is Enabled, false is Suspended
+	featureDefault := func(jsonName, annotation string, operatorDefault bool, value *agillv1beta1.FeatureState) error {
+		if _, set := rawSpec[jsonName]; set {
+			return nil
+		}
+		enabled, source := operatorDefault, DEFAULT_SOURCE_OPERATOR
+		if v, found := nsAnnotations[utils.NAMESPACE_DEFAULT_ANNOTATION_PREFIX+annotation]; found {
+			parsed, err := strconv.ParseBool(v)
+			if err != nil {
+				return fmt.Errorf("namespace annotation %v%v must be true or false, got %q", utils.NAMESPACE_DEFAULT_ANNOTATION_PREFIX, annotation, v)
+			}
+			enabled, source = parsed, DEFAULT_SOURCE_NAMESPACE
+		} else if !operatorDefault {
+			return nil
+		}
+		*value = agillv1beta1.FEATURE_SUSPENDED
+		if enabled {
+			*value = agillv1beta1.FEATURE_ENABLED
+		}
+		defaulted["spec."+jsonName] = source
+		return nil
+	}
+
+	stringDefault("spec.region", "region", d.defaults.Region, &cr.Spec.Region)
+	stringDefault("spec.bucketACL", "bucket-acl", d.defaults.BucketACL, &cr.Spec.BucketACL)
+	if err := featureDefault(names.versioning, "enable-versioning", d.defaults.EnableVersioning, &cr.Spec.Versioning); err != nil {
+		return nil, err
+	}
+	if err := featureDefault(names.transferAcceleration, "enable-transfer-acceleration",
+		d.defaults.EnableTransferAcceleration, &cr.Spec.TransferAcceleration); err != nil {
+		return nil, err
+	}
+
+	// a referenced IAMUser CR brings its own user, consumers get one each
+	if cr.Spec.IAMUser.Username == "" && !cr.UsesIAMUserRef() && len(cr.Spec.Consumers) == 0 && cr.GetName() != "" {
+		cr.Spec.IAMUser.Username = utils.DefaultIAMUsername(cr.GetNamespace(), cr.GetName(), utils.IAM_USERNAME_SUFFIX_S3)
+		defaulted["spec.iamUser.username"] = DEFAULT_SOURCE_GENERATED
+	}
+	return defaulted, nil
+}
+
+// recordDefaultedFields merges the defaulted fields into the annotation, e.g: spec.region=namespace,spec.iamUser.username=generated
+func recordDefaultedFields(cr *agillv1beta1.S3, defaulted map[string]string) {
+	annotations := cr.GetAnnotations()
+	if annotations == nil {
+		annotations = map[string]string{}
+	}
+	merged := map[string]string{}
+	for _, entry := range strings.Split(annotations[utils.DEFAULTED_FIELDS_ANNOTATION], ",") {
+		if kv := strings.SplitN(entry, "=", 2); len(kv) == 2 {
+			merged[kv[0]] = kv[1]
+		}
+	}
+	for fieldPath, source := range defaulted {
+		merged[fieldPath] = source
+	}
+
+	entries := make([]string, 0, len(merged))
+	for fieldPath, source := range merged {
+		entries = append(entries, fmt.Sprintf("%v=%v", fieldPath, source))
+	}
+	sort.Strings(entries)
+	annotations[utils.DEFAULTED_FIELDS_ANNOTATION] = strings.Join(entries, ",")
+	cr.SetAnnotations(annotations)
+}
+
+// InjectClient is called by the webhook server before serving requests
+func (d *defaulter) InjectClient(c client.Client) error {
+	d.client = c
+	return nil
+}
+
+// InjectDecoder is called by the webhook server before serving requests
+func (d *defaulter) InjectDecoder(decoder *admission.Decoder) error {
+	d.decoder = decoder
+	return nil
+}
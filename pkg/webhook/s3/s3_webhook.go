@@ -0,0 +1,23 @@
+package s3
+
+import (
+	"github.com/agill17/s3-operator/pkg/controller/options"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+const (
+	// must match the paths in the Mutating/ValidatingWebhookConfiguration
+	DEFAULT_PATH  = "/mutate-agill-apps-s3"
+	VALIDATE_PATH = "/validate-agill-apps-v1beta1-s3"
+)
+
+var log = logf.Log.WithName("webhook_s3")
+
+// Add registers the S3 admission webhooks, the webhook server injects the client and decoder when it starts
+func Add(mgr manager.Manager, opts options.Options) error {
+	mgr.GetWebhookServer().Register(DEFAULT_PATH, &admission.Webhook{Handler: &defaulter{defaults: opts.S3Defaults}})
+	mgr.GetWebhookServer().Register(VALIDATE_PATH, &admission.Webhook{Handler: &validator{}})
+	return nil
+}
@@ -0,0 +1,102 @@
+package s3
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	agillv1beta1 "github.com/agill17/s3-operator/pkg/apis/agill/v1beta1"
+	"github.com/agill17/s3-operator/pkg/controller/guardrails"
+	"github.com/agill17/s3-operator/pkg/controller/quotas"
+	admissionv1beta1 "k8s.io/api/admission/v1beta1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+// validator rejects S3 CRs that could never be reconciled, so users get the error from kubectl
+// instead of a stalled CR
+type validator struct {
+	client  client.Client
+	decoder *admission.Decoder
+}
+
+func (v *validator) Handle(ctx context.Context, req admission.Request) admission.Response {
+	if req.Operation != admissionv1beta1.Create && req.Operation != admissionv1beta1.Update {
+		return admission.Allowed("")
+	}
+
+	cr := &agillv1beta1.S3{}
+	if err := v.decoder.Decode(req, cr); err != nil {
+		return admission.Errored(http.StatusBadRequest, err)
+	}
+
+	// let deletes go through, the finalizer removal is an update too
+	if cr.GetDeletionTimestamp() != nil {
+		return admission.Allowed("")
+	}
+
+	errs := cr.ValidateS3()
+	specChanged := true
+	// what the CR adds to the namespace quota, creating it adds everything
+	requested := cr.QuotaUsage()
+	if req.Operation == admissionv1beta1.Update {
+		old := &agillv1beta1.S3{}
+		if err := v.decoder.DecodeRaw(req.OldObject, old); err != nil {
+			return admission.Errored(http.StatusBadRequest, err)
+		}
+		errs = cr.ValidateS3Update(*old)
+		specChanged = !equality.Semantic.DeepEqual(cr.Spec, old.Spec)
+		requested = cr.QuotaUsage().Sub(old.QuotaUsage())
+	}
+
+	// guardrails only block spec changes, metadata updates ( e.g: finalizers ) of CRs admitted before a guardrail go through
+	if specChanged {
+		// the namespace is only in the request when the object omits it
+		if cr.GetNamespace() == "" {
+			cr.SetNamespace(req.Namespace)
+		}
+		selecting, err := guardrails.Selecting(v.client, cr.GetNamespace())
+		if err != nil {
+			return admission.Errored(http.StatusInternalServerError, err)
+		}
+		// a ConfigMap policy is only known to the reconciler, which checks it against the guardrails again
+		errs = append(errs, guardrails.Violations(selecting, *cr, cr.RenderedBucketPolicyPreview())...)
+	}
+
+	// checked last, an invalid CR is rejected for being invalid rather than for its quota
+	if len(errs) == 0 && specChanged {
+		// bucket sizes only grow in status, only buckets and users are requested by a spec
+		requested.Bytes = 0
+		msg, err := quotas.Check(v.client, cr.GetNamespace(), requested, quotas.Excluding(cr))
+		if err != nil {
+			return admission.Errored(http.StatusInternalServerError, err)
+		}
+		if msg != "" {
+			log.Info("Rejecting S3 CR over quota", "Namespace", req.Namespace, "Name", req.Name, "Reason", msg)
+			status := apierrors.NewForbidden(agillv1beta1.SchemeGroupVersion.WithResource("s3s").GroupResource(), cr.GetName(),
+				errors.New(msg)).ErrStatus
+			return admission.Response{AdmissionResponse: admissionv1beta1.AdmissionResponse{Allowed: false, Result: &status}}
+		}
+	}
+
+	if len(errs) > 0 {
+		log.Info("Rejecting invalid S3 CR", "Namespace", req.Namespace, "Name", req.Name, "Errors", errs.ToAggregate().Error())
+		status := apierrors.NewInvalid(agillv1beta1.SchemeGroupVersion.WithKind("S3").GroupKind(), cr.GetName(), errs).ErrStatus
+		return admission.Response{AdmissionResponse: admissionv1beta1.AdmissionResponse{Allowed: false, Result: &status}}
+	}
+	return admission.Allowed("")
+}
+
+// InjectClient is called by the webhook server before serving requests
+func (v *validator) InjectClient(c client.Client) error {
+	v.client = c
+	return nil
+}
+
+// InjectDecoder is called by the webhook server before serving requests
+func (v *validator) InjectDecoder(d *admission.Decoder) error {
+	v.decoder = d
+	return nil
+}
@@ -0,0 +1,19 @@
+package webhook
+
+import (
+	"github.com/agill17/s3-operator/pkg/controller/options"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+)
+
+// AddToManagerFuncs is a list of functions to add all admission webhooks to the Manager
+var AddToManagerFuncs []func(manager.Manager, options.Options) error
+
+// AddToManager registers all admission webhooks with the Manager's webhook server
+func AddToManager(m manager.Manager, opts options.Options) error {
+	for _, f := range AddToManagerFuncs {
+		if err := f(m, opts); err != nil {
+			return err
+		}
+	}
+	return nil
+}